@@ -1,87 +1,277 @@
 package admin
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
+	"time"
+	"tuna/apierror"
+	"tuna/cors"
+	"tuna/health"
+	"tuna/logging"
+	"tuna/metrics"
 	"tuna/models"
+	"tuna/openapi"
+	"tuna/validation"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter() *gin.Engine {
-	router := gin.Default()
+// Options 管理端路由的依赖
+type Options struct {
+	Users  models.UserRepository
+	Admins models.AdminRepository
+	// SessionTTL 登录会话有效期，为 0 时使用 DefaultSessionTTL
+	SessionTTL time.Duration
+	// ExportColumns 导出接口未指定 columns 时的列，为空时使用 DefaultExportColumns
+	ExportColumns []string
+	// CORS 跨域策略，零值表示不允许跨域访问
+	CORS    cors.Policy
+	Metrics metrics.Options
+	// Health 为 nil 时 /readyz 只检查是否正在退出
+	Health *health.Checker
+}
 
-	// CORS middleware
-	router.Use(func(c *gin.Context) {
-		origin := c.Request.Header.Get("Origin")
-		if origin != "" {
-			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
-			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		} else {
-			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		}
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
-		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Type")
+type handler struct {
+	users         models.UserRepository
+	admins        models.AdminRepository
+	sessionTTL    time.Duration
+	exportColumns []string
+}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
-		}
+func SetupRouter(opts Options) *gin.Engine {
+	h := &handler{
+		users:         opts.Users,
+		admins:        opts.Admins,
+		sessionTTL:    opts.SessionTTL,
+		exportColumns: opts.ExportColumns,
+	}
+	if h.sessionTTL <= 0 {
+		h.sessionTTL = DefaultSessionTTL
+	}
+	if len(h.exportColumns) == 0 {
+		h.exportColumns = DefaultExportColumns
+	}
+	router := gin.New()
+	router.Use(logging.Middleware(), apierror.Recovery())
+	if opts.Metrics.Enabled {
+		router.Use(metrics.Middleware("admin"))
+	}
 
-		c.Next()
-	})
+	router.Use(cors.Middleware(opts.CORS))
+
+	checker := opts.Health
+	if checker == nil {
+		checker = health.New(health.Options{})
+	}
+	router.GET("/admin/health", checker.Live)
+	router.GET("/healthz", checker.Live)
+	router.GET("/readyz", checker.Ready)
+	router.POST("/admin/login", h.login)
+	if opts.Metrics.Public() {
+		router.GET(metrics.Path, gin.WrapH(metrics.Handler()))
+	}
+
+	// 除健康检查和登录外，所有 /admin 接口都需要登录
+	authorized := router.Group("/admin", h.authRequired)
+	authorized.POST("/logout", h.logout)
+	authorized.GET("/me", h.me)
+	authorized.GET("/users", h.getUsers)
+	authorized.GET("/users/export", h.exportUsers)
+	authorized.POST("/users/import", h.importUsers)
+	authorized.PUT("/users/status", h.bulkUpdateUserStatus)
+	authorized.PUT("/users/:id/status", h.updateUserStatus)
+	authorized.POST("/users/:id/reopen", h.reopenUser)
+	authorized.GET("/users/:id/history", h.getUserHistory)
+	openapi.Register(router, Spec(opts))
 
-	router.GET("/admin/users", getUsers)
-	router.PUT("/admin/users/:id/status", updateUserStatus)
-	router.GET("/admin/health", healthCheck)
+	router.NoRoute(apierror.NoRoute)
 
 	return router
 }
 
-func getUsers(c *gin.Context) {
-	users, err := models.GetAllUsers()
+func (h *handler) getUsers(c *gin.Context) {
+	var req models.ListUsersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		apierror.AbortInvalid(c, err)
+		return
+	}
+	opts, err := req.ToOptions()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		apierror.AbortInvalid(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"users": users})
+	users, total, err := h.users.ListUsers(c.Request.Context(), opts)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ListUsersResponse{
+		Users:      users,
+		Total:      total,
+		Page:       req.Page,
+		PageSize:   req.PageSize,
+		TotalPages: int((total + int64(req.PageSize) - 1) / int64(req.PageSize)),
+	})
 }
 
-func updateUserStatus(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+func (h *handler) updateUserStatus(c *gin.Context) {
+	id, ok := userID(c)
+	if !ok {
 		return
 	}
 
 	var req models.UpdateStatusRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.AbortInvalid(c, err)
 		return
 	}
 
-	// Check if user exists
-	user, err := models.GetUserByID(id)
+	h.changeStatus(c, id, models.StatusChange{
+		Status: req.Status,
+		Actor:  currentAccount(c).Username,
+		Reason: req.Reason,
+		Notes:  req.Notes,
+	})
+}
+
+func (h *handler) reopenUser(c *gin.Context) {
+	id, ok := userID(c)
+	if !ok {
+		return
+	}
+
+	var req models.ReopenRequest
+	// 请求体可以为空，分块传输的空请求体同样按空处理
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		apierror.AbortInvalid(c, err)
+		return
+	}
+
+	h.changeStatus(c, id, models.StatusChange{
+		Status: models.StatusPending,
+		Actor:  currentAccount(c).Username,
+		Reason: req.Reason,
+	})
+}
+
+func (h *handler) changeStatus(c *gin.Context, id int64, change models.StatusChange) {
+	err := h.users.UpdateUserStatus(c.Request.Context(), id, change)
+	if err == nil {
+		metrics.ReviewDecision(change.Status)
+		c.JSON(http.StatusOK, models.MessageResponse{Message: "User status updated successfully"})
+		return
+	}
+
+	apierror.Abort(c, statusError(err))
+}
+
+func (h *handler) bulkUpdateUserStatus(c *gin.Context) {
+	var req models.BulkUpdateStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.AbortInvalid(c, err)
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = models.BulkModeAllOrNothing
+	}
+
+	change := models.StatusChange{
+		Status: req.Status,
+		Actor:  currentAccount(c).Username,
+		Reason: req.Reason,
+		Notes:  req.Notes,
+	}
+	results, err := h.users.BulkUpdateUserStatus(c.Request.Context(), req.IDs, change, req.Mode == models.BulkModeAllOrNothing)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal(err))
+		return
+	}
+
+	lang := apierror.Language(c)
+	resp := models.BulkUpdateStatusResponse{
+		Mode:    req.Mode,
+		Results: make([]models.BulkStatusItemResult, len(results)),
+	}
+	for i, result := range results {
+		item := models.BulkStatusItemResult{ID: result.ID, Success: result.Err == nil}
+		if result.Err != nil {
+			e := statusError(result.Err)
+			item.Code = string(e.Code)
+			item.Error = e.Response(lang).Error
+			resp.Failed++
+		} else {
+			metrics.ReviewDecision(req.Status)
+			resp.Succeeded++
+		}
+		resp.Results[i] = item
+	}
+
+	// all_or_nothing 模式下有失败时整体未生效
+	if req.Mode == models.BulkModeAllOrNothing && resp.Failed > 0 {
+		c.JSON(http.StatusConflict, resp)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// statusError 把审核状态变更的业务错误映射为错误响应，非业务错误按 500 处理
+func statusError(err error) *apierror.Error {
+	var transitionErr *models.TransitionError
+	switch {
+	case errors.Is(err, models.ErrUserNotFound):
+		return apierror.New(http.StatusNotFound, apierror.CodeUserNotFound)
+	case errors.Is(err, models.ErrRejectReasonRequired):
+		return apierror.New(http.StatusBadRequest, apierror.CodeRejectReasonRequired)
+	case errors.Is(err, models.ErrRolledBack):
+		return apierror.New(http.StatusConflict, apierror.CodeRolledBack)
+	case errors.As(err, &transitionErr):
+		return apierror.New(http.StatusConflict, apierror.CodeInvalidStatusTransition, transitionErr.From, transitionErr.To).
+			WithDetail("from", transitionErr.From).
+			WithDetail("to", transitionErr.To)
+	default:
+		return apierror.Internal(err)
+	}
+}
+
+func (h *handler) getUserHistory(c *gin.Context) {
+	id, ok := userID(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.users.GetUserByID(c.Request.Context(), id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user"})
+		apierror.Abort(c, apierror.Internal(err))
 		return
 	}
 	if user == nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		apierror.Abort(c, apierror.New(http.StatusNotFound, apierror.CodeUserNotFound))
 		return
 	}
 
-	if err := models.UpdateUserStatus(id, req.Status); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user status"})
+	history, err := h.users.ListStatusHistory(c.Request.Context(), id)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal(err))
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "User status updated successfully"})
+	c.JSON(http.StatusOK, models.UserHistoryResponse{User: *user, History: history})
+}
+
+// userID 解析路径中的用户 ID，不合法时写入 400 并返回 false
+func userID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierror.AbortInvalid(c, &validation.FieldError{Field: "id", Rule: "integer"})
+		return 0, false
+	}
+	return id, true
 }
 
-func healthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+func (h *handler) me(c *gin.Context) {
+	c.JSON(http.StatusOK, models.MeResponse{Account: *currentAccount(c)})
 }
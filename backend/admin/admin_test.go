@@ -0,0 +1,322 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"tuna/apierror"
+	"tuna/apierror/apierrortest"
+	"tuna/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	logrus.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+type testServer struct {
+	t      *testing.T
+	users  models.UserRepository
+	router *gin.Engine
+	token  string
+}
+
+// newTestServer 创建已登录的测试服务，users 为预先提交的待审核记录
+func newTestServer(t *testing.T, users ...models.UserInfo) (*testServer, []int64) {
+	t.Helper()
+	ctx := context.Background()
+	repo := models.NewMemoryUserRepository()
+	admins := models.NewMemoryAdminRepository()
+	if _, err := BootstrapAccount(ctx, admins, "admin", "test-password"); err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for _, user := range users {
+		if _, err := repo.CreateUserInfo(ctx, &user, models.DuplicateReject); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, user.ID)
+	}
+
+	s := &testServer{t: t, users: repo, router: SetupRouter(Options{Users: repo, Admins: admins})}
+	w := s.json(http.MethodPost, "/admin/login", models.LoginRequest{Username: "admin", Password: "test-password"})
+	apierrortest.ExpectStatus(t, w, http.StatusOK)
+	s.token = apierrortest.Decode[models.LoginResponse](t, w).Token
+	return s, ids
+}
+
+func (s *testServer) do(req *http.Request) *httptest.ResponseRecorder {
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func (s *testServer) json(method, path string, body any) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		r = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, r)
+	req.Header.Set("Content-Type", "application/json")
+	return s.do(req)
+}
+
+func statusPath(id int64) string {
+	return "/admin/users/" + strconv.FormatInt(id, 10) + "/status"
+}
+
+func sampleUsers() []models.UserInfo {
+	return []models.UserInfo{
+		{Name: "张三", Email: "zhangsan@example.com", Phone: "13800138000", Hobby: "阅读", Age: 25},
+		{Name: "李四", Email: "lisi@example.com", Phone: "13900139000", Hobby: "跑步", Age: 32},
+		{Name: "王五", Email: "wangwu@example.com", Phone: "13700137000", Hobby: "游泳", Age: 41},
+	}
+}
+
+func TestLogin(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	apierrortest.ExpectCode(t, s.json(http.MethodPost, "/admin/login", models.LoginRequest{Username: "admin", Password: "wrong"}),
+		http.StatusUnauthorized, apierror.CodeInvalidCredentials)
+	apierrortest.ExpectCode(t, s.json(http.MethodPost, "/admin/login", models.LoginRequest{Username: "nobody", Password: "test-password"}),
+		http.StatusUnauthorized, apierror.CodeInvalidCredentials)
+
+	w := s.json(http.MethodGet, "/admin/me", nil)
+	apierrortest.ExpectStatus(t, w, http.StatusOK)
+	if me := apierrortest.Decode[models.MeResponse](t, w); me.Account.Username != "admin" {
+		t.Errorf("me = %+v", me)
+	}
+
+	apierrortest.ExpectStatus(t, s.json(http.MethodPost, "/admin/logout", nil), http.StatusOK)
+	apierrortest.ExpectStatus(t, s.json(http.MethodGet, "/admin/me", nil), http.StatusUnauthorized)
+
+	s.token = ""
+	apierrortest.ExpectStatus(t, s.json(http.MethodGet, "/admin/users", nil), http.StatusUnauthorized)
+}
+
+func TestListUsers(t *testing.T) {
+	s, ids := newTestServer(t, sampleUsers()...)
+	apierrortest.ExpectStatus(t, s.json(http.MethodPut, statusPath(ids[1]), models.UpdateStatusRequest{Status: models.StatusApproved}), http.StatusOK)
+
+	w := s.json(http.MethodGet, "/admin/users?status=pending&sort_by=age&order=desc&page=1&page_size=1", nil)
+	apierrortest.ExpectStatus(t, w, http.StatusOK)
+	list := apierrortest.Decode[models.ListUsersResponse](t, w)
+	if list.Total != 2 || list.TotalPages != 2 || len(list.Users) != 1 || list.Users[0].ID != ids[2] {
+		t.Errorf("list = %+v", list)
+	}
+
+	// 关键字按规范化后的手机号匹配
+	list = apierrortest.Decode[models.ListUsersResponse](t, s.json(http.MethodGet, "/admin/users?keyword=%2B86138", nil))
+	if list.Total != 1 || list.Users[0].ID != ids[0] {
+		t.Errorf("keyword search = %+v", list)
+	}
+
+	apierrortest.ExpectCode(t, s.json(http.MethodGet, "/admin/users?page_size=1000", nil), http.StatusBadRequest, apierror.CodeValidationFailed)
+}
+
+func TestUpdateStatus(t *testing.T) {
+	s, ids := newTestServer(t, sampleUsers()...)
+
+	resp := apierrortest.ExpectCode(t, s.json(http.MethodPut, statusPath(ids[0]), models.UpdateStatusRequest{Status: models.StatusRejected}),
+		http.StatusBadRequest, apierror.CodeValidationFailed)
+	if len(resp.Fields) != 1 || resp.Fields[0].Field != "reason" {
+		t.Errorf("fields = %+v, want reason", resp.Fields)
+	}
+	apierrortest.ExpectStatus(t, s.json(http.MethodPut, statusPath(ids[0]), models.UpdateStatusRequest{Status: models.StatusApproved, Notes: "资料齐全"}),
+		http.StatusOK)
+
+	resp = apierrortest.ExpectCode(t, s.json(http.MethodPut, statusPath(ids[0]), models.UpdateStatusRequest{Status: models.StatusRejected, Reason: "重复"}),
+		http.StatusConflict, apierror.CodeInvalidStatusTransition)
+	if resp.Details["from"] != models.StatusApproved || resp.Details["to"] != models.StatusRejected {
+		t.Errorf("details = %v", resp.Details)
+	}
+
+	apierrortest.ExpectCode(t, s.json(http.MethodPut, statusPath(999), models.UpdateStatusRequest{Status: models.StatusApproved}),
+		http.StatusNotFound, apierror.CodeUserNotFound)
+	apierrortest.ExpectCode(t, s.json(http.MethodPut, "/admin/users/abc/status", models.UpdateStatusRequest{Status: models.StatusApproved}),
+		http.StatusBadRequest, apierror.CodeValidationFailed)
+}
+
+func TestReopen(t *testing.T) {
+	s, ids := newTestServer(t, sampleUsers()...)
+	apierrortest.ExpectStatus(t, s.json(http.MethodPut, statusPath(ids[0]),
+		models.UpdateStatusRequest{Status: models.StatusRejected, Reason: "资料不全", Notes: "缺少证明"}), http.StatusOK)
+
+	// 分块传输的空请求体没有 Content-Length
+	req := httptest.NewRequest(http.MethodPost, "/admin/users/"+strconv.FormatInt(ids[0], 10)+"/reopen", strings.NewReader(""))
+	req.ContentLength = -1
+	req.Header.Set("Content-Type", "application/json")
+	apierrortest.ExpectStatus(t, s.do(req), http.StatusOK)
+
+	user, _ := s.users.GetUserByID(context.Background(), ids[0])
+	if user.Status != models.StatusPending || user.RejectReason != "" {
+		t.Errorf("user = %+v, want pending without reject reason", user)
+	}
+	if user.ReviewNotes != "缺少证明" {
+		t.Errorf("review notes = %q, want the notes kept", user.ReviewNotes)
+	}
+
+	apierrortest.ExpectCode(t, s.json(http.MethodPost, "/admin/users/"+strconv.FormatInt(ids[0], 10)+"/reopen", nil),
+		http.StatusConflict, apierror.CodeInvalidStatusTransition)
+}
+
+func TestHistory(t *testing.T) {
+	s, ids := newTestServer(t, sampleUsers()...)
+	path := "/admin/users/" + strconv.FormatInt(ids[0], 10)
+	apierrortest.ExpectStatus(t, s.json(http.MethodPut, path+"/status",
+		models.UpdateStatusRequest{Status: models.StatusRejected, Reason: "资料不全"}), http.StatusOK)
+	apierrortest.ExpectStatus(t, s.json(http.MethodPost, path+"/reopen", nil), http.StatusOK)
+
+	w := s.json(http.MethodGet, path+"/history", nil)
+	apierrortest.ExpectStatus(t, w, http.StatusOK)
+	resp := apierrortest.Decode[models.UserHistoryResponse](t, w)
+	if resp.User.ID != ids[0] {
+		t.Errorf("user = %+v", resp.User)
+	}
+	history := resp.History
+	if len(history) != 2 || history[0].NewStatus != models.StatusRejected || history[0].Reason != "资料不全" ||
+		history[1].OldStatus != models.StatusRejected || history[1].NewStatus != models.StatusPending || history[1].Actor != "admin" {
+		t.Errorf("history = %+v", history)
+	}
+
+	// 其他记录的历史不混入
+	w = s.json(http.MethodGet, "/admin/users/"+strconv.FormatInt(ids[1], 10)+"/history", nil)
+	if history := apierrortest.Decode[models.UserHistoryResponse](t, w).History; len(history) != 0 {
+		t.Errorf("history of an untouched user = %+v", history)
+	}
+	apierrortest.ExpectCode(t, s.json(http.MethodGet, "/admin/users/999/history", nil), http.StatusNotFound, apierror.CodeUserNotFound)
+}
+
+func TestBulkUpdateStatus(t *testing.T) {
+	s, ids := newTestServer(t, sampleUsers()...)
+	apierrortest.ExpectStatus(t, s.json(http.MethodPut, statusPath(ids[0]), models.UpdateStatusRequest{Status: models.StatusApproved}), http.StatusOK)
+
+	req := models.BulkUpdateStatusRequest{IDs: []int64{ids[0], ids[1], 999}, Status: models.StatusApproved}
+	w := s.json(http.MethodPut, "/admin/users/status", req)
+	apierrortest.ExpectStatus(t, w, http.StatusConflict)
+	resp := apierrortest.Decode[models.BulkUpdateStatusResponse](t, w)
+	codes := map[int64]string{}
+	for _, item := range resp.Results {
+		codes[item.ID] = item.Code
+	}
+	want := map[int64]string{
+		ids[0]: string(apierror.CodeInvalidStatusTransition),
+		ids[1]: string(apierror.CodeRolledBack),
+		999:    string(apierror.CodeUserNotFound),
+	}
+	for id, code := range want {
+		if codes[id] != code {
+			t.Errorf("item %d: code %q, want %q", id, codes[id], code)
+		}
+	}
+	if user, _ := s.users.GetUserByID(context.Background(), ids[1]); user.Status != models.StatusPending {
+		t.Errorf("all_or_nothing applied a change: %+v", user)
+	}
+
+	req.Mode = models.BulkModeBestEffort
+	w = s.json(http.MethodPut, "/admin/users/status", req)
+	apierrortest.ExpectStatus(t, w, http.StatusOK)
+	if resp := apierrortest.Decode[models.BulkUpdateStatusResponse](t, w); resp.Succeeded != 1 || resp.Failed != 2 {
+		t.Errorf("partial = %+v", resp)
+	}
+	if user, _ := s.users.GetUserByID(context.Background(), ids[1]); user.Status != models.StatusApproved {
+		t.Errorf("partial did not apply the valid change: %+v", user)
+	}
+}
+
+func TestExportUsers(t *testing.T) {
+	s, _ := newTestServer(t, sampleUsers()...)
+
+	w := s.json(http.MethodGet, "/admin/users/export?format=csv&columns=name,age&sort_by=age&order=asc&lang=en", nil)
+	apierrortest.ExpectStatus(t, w, http.StatusOK)
+	// 跳过 Excel 需要的 UTF-8 BOM
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(w.Body.String(), "\ufeff"))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || len(records[0]) != 2 || records[1][0] != "张三" || records[3][1] != "41" {
+		t.Errorf("csv = %v", records)
+	}
+
+	apierrortest.ExpectCode(t, s.json(http.MethodGet, "/admin/users/export?columns=password", nil), http.StatusBadRequest, apierror.CodeValidationFailed)
+}
+
+func (s *testServer) importFile(fields map[string]string, content string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	fw, err := mw.CreateFormFile("file", "users.csv")
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	io.WriteString(fw, content)
+	mw.Close()
+	req := httptest.NewRequest(http.MethodPost, "/admin/users/import", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return s.do(req)
+}
+
+func TestImportUsers(t *testing.T) {
+	s, _ := newTestServer(t, sampleUsers()[0])
+	content := "name,email,phone,hobby,age\n" +
+		"赵六,zhaoliu@example.com,13600136000,游泳,28\n" +
+		"张三,ZhangSan@example.com,13500135000,阅读,25\n" +
+		"错误,bad,1,x,0\n"
+
+	// 预览要报告与已有记录的重复，且不写入任何数据
+	w := s.importFile(map[string]string{"dry_run": "true"}, content)
+	apierrortest.ExpectStatus(t, w, http.StatusOK)
+	resp := apierrortest.Decode[models.ImportUsersResponse](t, w)
+	if !resp.DryRun || resp.Total != 3 || resp.Imported != 1 || resp.Failed != 2 {
+		t.Errorf("dry run = %+v", resp)
+	}
+	var codes []string
+	for _, row := range resp.Errors {
+		for _, e := range row.Errors {
+			codes = append(codes, e.Code)
+		}
+	}
+	if !strings.Contains(strings.Join(codes, ","), string(apierror.CodeDuplicateSubmission)) {
+		t.Errorf("dry run errors %v do not report the duplicate", codes)
+	}
+	if n, _ := s.users.CountUsers(context.Background(), models.UserFilter{}); n != 1 {
+		t.Fatalf("dry run stored %d users, want 1", n)
+	}
+
+	w = s.importFile(map[string]string{"status": "approved"}, content)
+	apierrortest.ExpectStatus(t, w, http.StatusOK)
+	if got := apierrortest.Decode[models.ImportUsersResponse](t, w); got.Imported != 2 || got.Failed != 1 {
+		t.Errorf("import = %+v", got)
+	}
+	if n, _ := s.users.CountUsers(context.Background(), models.UserFilter{Status: models.StatusApproved}); n != 2 {
+		t.Errorf("%d approved users, want 2", n)
+	}
+
+	apierrortest.ExpectCode(t, s.importFile(map[string]string{"duplicate": "merge", "status": "approved"}, content),
+		http.StatusBadRequest, apierror.CodeMergeRequiresPending)
+	apierrortest.ExpectCode(t, s.importFile(nil, "name,email\n张三,a@example.com\n"), http.StatusBadRequest, apierror.CodeMissingColumns)
+}
@@ -0,0 +1,172 @@
+package admin
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+	"tuna/apierror"
+	"tuna/logging"
+	"tuna/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// DefaultSessionTTL 未配置时登录会话的有效期
+	DefaultSessionTTL = 12 * time.Hour
+
+	accountContextKey = "admin_account"
+	tokenContextKey   = "admin_token_hash"
+)
+
+// dummyPasswordHash 用户名不存在时仍做一次 bcrypt 比较，避免通过响应时间枚举用户名
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("tuna-dummy-password"), bcrypt.DefaultCost)
+
+func (h *handler) login(c *gin.Context) {
+	var req models.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.AbortInvalid(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	account, err := h.admins.GetAccountByUsername(ctx, req.Username)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal(err))
+		return
+	}
+
+	hash := dummyPasswordHash
+	if account != nil {
+		hash = []byte(account.PasswordHash)
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(req.Password)); err != nil || account == nil {
+		apierror.Abort(c, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidCredentials))
+		return
+	}
+
+	token, err := newSessionToken()
+	if err != nil {
+		apierror.Abort(c, apierror.Internal(err))
+		return
+	}
+	session := &models.AdminSession{
+		TokenHash: hashToken(token),
+		AccountID: account.ID,
+		ExpiresAt: time.Now().Add(h.sessionTTL),
+	}
+	if err := h.admins.CreateSession(ctx, session); err != nil {
+		apierror.Abort(c, apierror.Internal(err))
+		return
+	}
+	// 顺带清理过期会话，失败不影响本次登录
+	_ = h.admins.DeleteExpiredSessions(ctx)
+
+	c.JSON(http.StatusOK, models.LoginResponse{
+		Token:     token,
+		ExpiresAt: session.ExpiresAt,
+		Account:   *account,
+	})
+}
+
+func (h *handler) logout(c *gin.Context) {
+	if err := h.admins.DeleteSession(c.Request.Context(), c.GetString(tokenContextKey)); err != nil {
+		apierror.Abort(c, apierror.Internal(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.MessageResponse{Message: "Logged out successfully"})
+}
+
+// authRequired 校验 Authorization: Bearer <token>，通过后把当前管理员放入 gin.Context
+func (h *handler) authRequired(c *gin.Context) {
+	token := bearerToken(c.GetHeader("Authorization"))
+	if token == "" {
+		apierror.Abort(c, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthorized))
+		return
+	}
+
+	ctx := c.Request.Context()
+	tokenHash := hashToken(token)
+	session, err := h.admins.GetSession(ctx, tokenHash)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal(err))
+		return
+	}
+	if session == nil {
+		apierror.Abort(c, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken))
+		return
+	}
+
+	account, err := h.admins.GetAccountByID(ctx, session.AccountID)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal(err))
+		return
+	}
+	if account == nil {
+		apierror.Abort(c, apierror.New(http.StatusUnauthorized, apierror.CodeInvalidToken))
+		return
+	}
+
+	logging.SetUserID(c, account.ID)
+	c.Set(accountContextKey, account)
+	c.Set(tokenContextKey, tokenHash)
+	c.Next()
+}
+
+// currentAccount 返回 authRequired 放入的当前管理员
+func currentAccount(c *gin.Context) *models.AdminAccount {
+	account, _ := c.Get(accountContextKey)
+	a, _ := account.(*models.AdminAccount)
+	return a
+}
+
+// BootstrapAccount 在指定用户名的账号不存在时创建它，用于初始化第一个管理员
+func BootstrapAccount(ctx context.Context, admins models.AdminRepository, username, password string) (bool, error) {
+	if username == "" || password == "" {
+		return false, nil
+	}
+
+	existing, err := admins.GetAccountByUsername(ctx, username)
+	if err != nil {
+		return false, err
+	}
+	if existing != nil {
+		return false, nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return false, err
+	}
+	if err := admins.CreateAccount(ctx, &models.AdminAccount{Username: username, PasswordHash: string(hash)}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func bearerToken(header string) string {
+	const prefix = "Bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(prefix):])
+}
+
+func newSessionToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,315 @@
+package admin
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"tuna/apierror"
+	"tuna/logging"
+	"tuna/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+const exportTimeLayout = "2006-01-02 15:04:05"
+
+// exportColumn 可导出的一列。text 为 true 的列是用户自由填写的内容，
+// 写入 CSV 时需要防止被表格软件当作公式执行
+type exportColumn struct {
+	key   string
+	zh    string
+	en    string
+	text  bool
+	value func(u *models.UserInfo) any
+}
+
+var exportColumns = []exportColumn{
+	{key: "id", zh: "ID", en: "ID", value: func(u *models.UserInfo) any { return u.ID }},
+	{key: "name", zh: "姓名", en: "Name", text: true, value: func(u *models.UserInfo) any { return u.Name }},
+	{key: "email", zh: "邮箱", en: "Email", text: true, value: func(u *models.UserInfo) any { return u.Email }},
+	{key: "phone", zh: "手机号", en: "Phone", value: func(u *models.UserInfo) any { return u.Phone }},
+	{key: "hobby", zh: "爱好", en: "Hobby", text: true, value: func(u *models.UserInfo) any { return u.Hobby }},
+	{key: "age", zh: "年龄", en: "Age", value: func(u *models.UserInfo) any { return u.Age }},
+	{key: "status", zh: "审核状态", en: "Status", value: func(u *models.UserInfo) any { return u.Status }},
+	{key: "reject_reason", zh: "拒绝原因", en: "Reject Reason", text: true, value: func(u *models.UserInfo) any { return u.RejectReason }},
+	{key: "review_notes", zh: "审核备注", en: "Review Notes", text: true, value: func(u *models.UserInfo) any { return u.ReviewNotes }},
+	{key: "created_at", zh: "提交时间", en: "Created At", value: func(u *models.UserInfo) any { return u.CreatedAt }},
+	{key: "updated_at", zh: "更新时间", en: "Updated At", value: func(u *models.UserInfo) any { return u.UpdatedAt }},
+}
+
+// DefaultExportColumns 未配置也未在请求中指定 columns 时导出的列
+var DefaultExportColumns = []string{"id", "name", "email", "phone", "hobby", "age", "status", "reject_reason", "created_at"}
+
+func findExportColumn(key string) (exportColumn, bool) {
+	for _, col := range exportColumns {
+		if col.key == key {
+			return col, true
+		}
+	}
+	return exportColumn{}, false
+}
+
+// IsExportColumn 判断列名是否可以导出，用于校验配置
+func IsExportColumn(key string) bool {
+	_, ok := findExportColumn(key)
+	return ok
+}
+
+// exportColumnNames 按导出顺序返回全部列名
+func exportColumnNames() []string {
+	names := make([]string, len(exportColumns))
+	for i, col := range exportColumns {
+		names[i] = col.key
+	}
+	return names
+}
+
+// rowWriter 各导出格式的写入器，header 只在 CSV/XLSX 中使用
+type rowWriter interface {
+	writeHeader(header []string) error
+	writeRow(row []any) error
+	close() error
+}
+
+func (h *handler) exportUsers(c *gin.Context) {
+	var req models.ExportUsersRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		apierror.AbortInvalid(c, err)
+		return
+	}
+	opts, err := req.ToOptions()
+	if err != nil {
+		apierror.AbortInvalid(c, err)
+		return
+	}
+	keys, err := req.ColumnList(IsExportColumn)
+	if err != nil {
+		apierror.AbortInvalid(c, err)
+		return
+	}
+	if len(keys) == 0 {
+		keys = h.exportColumns
+	}
+
+	columns := make([]exportColumn, len(keys))
+	header := make([]string, len(keys))
+	for i, key := range keys {
+		columns[i], _ = findExportColumn(key)
+		header[i] = columns[i].zh
+		if req.Lang == "en" {
+			header[i] = columns[i].en
+		}
+	}
+
+	filename := fmt.Sprintf("users-%s.%s", time.Now().Format("20060102-150405"), req.Format)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	texts := make([]bool, len(columns))
+	for i, col := range columns {
+		texts[i] = col.text
+	}
+
+	var w rowWriter
+	switch req.Format {
+	case models.ExportXLSX:
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		var xw *xlsxWriter
+		if xw, err = newXLSXWriter(c.Writer); err == nil {
+			defer xw.file.Close()
+			w = xw
+		}
+	case models.ExportJSONL:
+		c.Header("Content-Type", "application/x-ndjson; charset=utf-8")
+		w = newJSONLWriter(c.Writer, keys)
+	default:
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		w = newCSVWriter(c.Writer, texts)
+	}
+	if err == nil {
+		err = w.writeHeader(header)
+	}
+
+	row := make([]any, len(columns))
+	if err == nil {
+		err = h.users.StreamUsers(c.Request.Context(), opts, func(u *models.UserInfo) error {
+			for i, col := range columns {
+				row[i] = col.value(u)
+			}
+			return w.writeRow(row)
+		})
+	}
+	if err == nil {
+		err = w.close()
+	}
+	if err == nil {
+		return
+	}
+
+	if !c.Writer.Written() {
+		c.Header("Content-Disposition", "")
+		c.Header("Content-Type", "")
+		apierror.Abort(c, apierror.Internal(err))
+		return
+	}
+	logging.FromContext(c.Request.Context()).WithError(err).Error("Failed to export users")
+	// 已经开始输出时无法再修改状态码，直接断开连接，
+	// 避免分块传输正常结束让客户端把不完整的文件当成完整的
+	if conn, _, err := c.Writer.Hijack(); err == nil {
+		conn.Close()
+	}
+	c.Abort()
+}
+
+// formatCell 把值转换为单元格中的文本，text 为 true 时在 = + - @ 等开头的内容前加单引号
+func formatCell(v any, text bool) string {
+	var s string
+	switch v := v.(type) {
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		s = v.Format(exportTimeLayout)
+	case int64:
+		s = strconv.FormatInt(v, 10)
+	case int:
+		s = strconv.Itoa(v)
+	default:
+		s = fmt.Sprint(v)
+	}
+	if text && s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		s = "'" + s
+	}
+	return s
+}
+
+type csvWriter struct {
+	w     *csv.Writer
+	texts []bool
+}
+
+func newCSVWriter(w http.ResponseWriter, texts []bool) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w), texts: texts}
+}
+
+// writeHeader 在第一列前加 UTF-8 BOM，Excel 打开时才能正确识别中文
+func (w *csvWriter) writeHeader(header []string) error {
+	record := append([]string(nil), header...)
+	record[0] = "\uFEFF" + record[0]
+	return w.w.Write(record)
+}
+
+func (w *csvWriter) writeRow(row []any) error {
+	record := make([]string, len(row))
+	for i, v := range row {
+		record[i] = formatCell(v, w.texts[i])
+	}
+	return w.w.Write(record)
+}
+
+func (w *csvWriter) close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+// xlsxWriter 用 excelize 的 StreamWriter 逐行写入，行数据落在临时文件中而不是内存里，
+// 由于 xlsx 是 zip 格式，要在全部行写完后才能输出。单元格按字符串写入，不会被当作公式
+type xlsxWriter struct {
+	out   http.ResponseWriter
+	file  *excelize.File
+	sheet *excelize.StreamWriter
+	row   int
+}
+
+func newXLSXWriter(out http.ResponseWriter) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+	sheet, err := file.NewStreamWriter("Sheet1")
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &xlsxWriter{out: out, file: file, sheet: sheet}, nil
+}
+
+func (w *xlsxWriter) writeHeader(header []string) error {
+	cells := make([]any, len(header))
+	for i, name := range header {
+		cells[i] = name
+	}
+	return w.setRow(cells)
+}
+
+func (w *xlsxWriter) writeRow(row []any) error {
+	cells := make([]any, len(row))
+	for i, v := range row {
+		switch v.(type) {
+		case int, int64:
+			cells[i] = v
+		default:
+			cells[i] = formatCell(v, false)
+		}
+	}
+	return w.setRow(cells)
+}
+
+func (w *xlsxWriter) setRow(cells []any) error {
+	w.row++
+	cell, err := excelize.CoordinatesToCellName(1, w.row)
+	if err != nil {
+		return err
+	}
+	return w.sheet.SetRow(cell, cells)
+}
+
+func (w *xlsxWriter) close() error {
+	if err := w.sheet.Flush(); err != nil {
+		return err
+	}
+	_, err := w.file.WriteTo(w.out)
+	return err
+}
+
+// jsonlWriter 每行一个 JSON 对象，字段顺序与请求的列顺序一致，值保留原始类型
+type jsonlWriter struct {
+	w    *bufio.Writer
+	keys [][]byte
+}
+
+func newJSONLWriter(w http.ResponseWriter, keys []string) *jsonlWriter {
+	encoded := make([][]byte, len(keys))
+	for i, key := range keys {
+		encoded[i], _ = json.Marshal(key)
+	}
+	return &jsonlWriter{w: bufio.NewWriter(w), keys: encoded}
+}
+
+func (w *jsonlWriter) writeHeader([]string) error {
+	return nil
+}
+
+func (w *jsonlWriter) writeRow(row []any) error {
+	w.w.WriteByte('{')
+	for i, v := range row {
+		if i > 0 {
+			w.w.WriteByte(',')
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		w.w.Write(w.keys[i])
+		w.w.WriteByte(':')
+		w.w.Write(value)
+	}
+	_, err := w.w.WriteString("}\n")
+	return err
+}
+
+func (w *jsonlWriter) close() error {
+	return w.w.Flush()
+}
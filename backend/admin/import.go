@@ -0,0 +1,296 @@
+package admin
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"tuna/apierror"
+	"tuna/metrics"
+	"tuna/models"
+	"tuna/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	maxImportFileSize = 10 << 20
+	maxImportRows     = 5000
+	// importBatchSize 每个数据库事务写入的行数
+	importBatchSize = 200
+)
+
+// importColumns 导入文件必须包含的列，表头可以用列名或导出时的中英文表头
+var importColumns = []string{"name", "email", "phone", "hobby", "age"}
+
+func (h *handler) importUsers(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportFileSize)
+
+	lang := apierror.Language(c)
+	var req models.ImportUsersRequest
+	if err := c.ShouldBind(&req); err != nil {
+		apierror.Abort(c, apierror.Invalid(err, lang))
+		return
+	}
+	if req.Status == "" {
+		req.Status = models.StatusPending
+	}
+	policy, err := models.ParseDuplicatePolicy(req.Duplicate)
+	if err != nil {
+		apierror.Abort(c, apierror.Invalid(&validation.FieldError{Field: "duplicate", Rule: "oneof", Param: "reject merge allow"}, lang))
+		return
+	}
+	// 只有待审核记录参与查重，导入为其他状态时没有可以合并的对象
+	if policy == models.DuplicateMerge && req.Status != models.StatusPending {
+		apierror.Abort(c, apierror.New(http.StatusBadRequest, apierror.CodeMergeRequiresPending))
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		apierror.Abort(c, apierror.New(http.StatusBadRequest, apierror.CodeFileRequired))
+		return
+	}
+	records, err := readImportFile(file)
+	if err != nil {
+		apierror.Abort(c, apierror.New(http.StatusBadRequest, apierror.CodeInvalidFile).WithDetail("reason", err.Error()))
+		return
+	}
+	if len(records) == 0 {
+		apierror.Abort(c, apierror.New(http.StatusBadRequest, apierror.CodeFileEmpty))
+		return
+	}
+	index, missing := importColumnIndex(records[0])
+	if len(missing) > 0 {
+		apierror.Abort(c, apierror.New(http.StatusBadRequest, apierror.CodeMissingColumns, strings.Join(missing, ", ")).
+			WithDetail("columns", missing))
+		return
+	}
+	if len(records)-1 > maxImportRows {
+		apierror.Abort(c, apierror.New(http.StatusBadRequest, apierror.CodeTooManyRows, maxImportRows).
+			WithDetail("max_rows", maxImportRows))
+		return
+	}
+
+	resp := models.ImportUsersResponse{DryRun: req.DryRun, Errors: []models.ImportRowError{}}
+	var users []*models.UserInfo
+	var rows []int
+	// 文件内部的重复在 reject 时直接报错，merge 时交给仓库层按顺序合并
+	checkDuplicates := policy == models.DuplicateReject && req.Status == models.StatusPending
+	seen := map[string]int{}
+	for i, record := range records[1:] {
+		row := i + 2
+		if isBlankRecord(record) {
+			continue
+		}
+		resp.Total++
+
+		user, fieldErrors := parseImportRow(record, index, lang)
+		if len(fieldErrors) == 0 && checkDuplicates {
+			phone, _ := validation.NormalizePhone(user.Phone)
+			keys := []string{"email:" + validation.NormalizeEmail(user.Email), "phone:" + phone}
+			for _, key := range keys {
+				if prev, ok := seen[key]; ok {
+					fieldErrors = append(fieldErrors, models.ImportFieldError{
+						Field: key[:strings.Index(key, ":")],
+						Code:  "duplicate_row",
+						Error: apierror.FieldMessage(lang, "duplicate_row", strconv.Itoa(prev)),
+					})
+				}
+			}
+			if len(fieldErrors) == 0 {
+				for _, key := range keys {
+					seen[key] = row
+				}
+			}
+		}
+		if len(fieldErrors) > 0 {
+			resp.Errors = append(resp.Errors, models.ImportRowError{Row: row, Errors: fieldErrors})
+			continue
+		}
+
+		user.Status = req.Status
+		if req.Status == models.StatusRejected {
+			user.RejectReason = req.Reason
+		}
+		users = append(users, user)
+		rows = append(rows, row)
+	}
+	resp.Valid = len(users)
+
+	// dry run 同样交给仓库层查重，在一个回滚的事务中执行，文件内的行可以互相合并，结果与实际导入一致
+	actor := currentAccount(c).Username
+	batchSize := importBatchSize
+	if req.DryRun {
+		batchSize = max(len(users), 1)
+	}
+	for start := 0; start < len(users); start += batchSize {
+		end := min(start+batchSize, len(users))
+		results, err := h.users.ImportUsers(c.Request.Context(), users[start:end], policy, actor, req.DryRun)
+		if err != nil {
+			// 之前的批次已经提交，返回已导入的数量方便核对
+			apierror.Abort(c, apierror.Internal(err).
+				WithDetail("imported", resp.Imported).
+				WithDetail("merged", resp.Merged))
+			return
+		}
+		for i, result := range results {
+			switch {
+			case result.Err != nil:
+				resp.Errors = append(resp.Errors, models.ImportRowError{
+					Row:    rows[start+i],
+					Errors: []models.ImportFieldError{importResultError(result.Err, lang)},
+				})
+			case result.Merged:
+				resp.Merged++
+			default:
+				resp.Imported++
+			}
+			if result.Err == nil && !req.DryRun {
+				metrics.SubmissionCreated("import", result.Merged)
+			}
+		}
+	}
+	sort.Slice(resp.Errors, func(i, j int) bool { return resp.Errors[i].Row < resp.Errors[j].Row })
+
+	resp.Failed = len(resp.Errors)
+	c.JSON(http.StatusOK, resp)
+}
+
+// readImportFile 按扩展名解析 CSV 或 XLSX（第一个工作表），返回包括表头在内的所有行
+func readImportFile(file *multipart.FileHeader) ([][]string, error) {
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(file.Filename)) {
+	case ".csv":
+		r := csv.NewReader(f)
+		r.FieldsPerRecord = -1
+		records, err := r.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv file: %w", err)
+		}
+		// 去掉 Excel 另存为 CSV 时带上的 UTF-8 BOM
+		if len(records) > 0 && len(records[0]) > 0 {
+			records[0][0] = strings.TrimPrefix(records[0][0], "\uFEFF")
+		}
+		return records, nil
+	case ".xlsx":
+		book, err := excelize.OpenReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid xlsx file: %w", err)
+		}
+		defer book.Close()
+		return book.GetRows(book.GetSheetName(0))
+	default:
+		return nil, errors.New("only .csv and .xlsx files are supported")
+	}
+}
+
+// importColumnIndex 根据表头找到每个必需列所在的位置，表头可以是列名或导出使用的中英文表头，
+// 同时返回缺少的必需列
+func importColumnIndex(header []string) (map[string]int, []string) {
+	index := map[string]int{}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		for _, col := range exportColumns {
+			if strings.EqualFold(name, col.key) || strings.EqualFold(name, col.en) || name == col.zh {
+				if _, ok := index[col.key]; !ok {
+					index[col.key] = i
+				}
+				break
+			}
+		}
+	}
+
+	var missing []string
+	for _, key := range importColumns {
+		if _, ok := index[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+	return index, missing
+}
+
+// parseImportRow 用与 CreateUserRequest 相同的校验规则检查一行
+func parseImportRow(record []string, index map[string]int, lang apierror.Lang) (*models.UserInfo, []models.ImportFieldError) {
+	cell := func(key string) string {
+		if i := index[key]; i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var fieldErrors []models.ImportFieldError
+	req := models.CreateUserRequest{
+		Name:  cell("name"),
+		Email: cell("email"),
+		Phone: cell("phone"),
+		Hobby: cell("hobby"),
+	}
+	ageInvalid := false
+	if age := cell("age"); age != "" {
+		n, err := strconv.Atoi(age)
+		if err != nil {
+			ageInvalid = true
+			fieldErrors = append(fieldErrors, models.ImportFieldError{
+				Field: "age",
+				Code:  "integer",
+				Error: apierror.FieldMessage(lang, "integer", ""),
+			})
+		}
+		req.Age = n
+	}
+
+	if err := binding.Validator.ValidateStruct(&req); err != nil {
+		fields, ok := apierror.Fields(err, lang)
+		if !ok {
+			return nil, append(fieldErrors, models.ImportFieldError{Code: string(apierror.CodeInternal), Error: err.Error()})
+		}
+		for _, fe := range fields {
+			if fe.Field == "age" && ageInvalid {
+				continue
+			}
+			fieldErrors = append(fieldErrors, models.ImportFieldError{Field: fe.Field, Code: fe.Rule, Error: fe.Message})
+		}
+	}
+	if len(fieldErrors) > 0 {
+		return nil, fieldErrors
+	}
+
+	return &models.UserInfo{
+		Name:  req.Name,
+		Email: req.Email,
+		Phone: req.Phone,
+		Hobby: req.Hobby,
+		Age:   req.Age,
+	}, nil
+}
+
+// importResultError 仓库层返回的单行错误，重复提交之外的错误不返回原始内容
+func importResultError(err error, lang apierror.Lang) models.ImportFieldError {
+	code := apierror.CodeInternal
+	if errors.Is(err, models.ErrDuplicateSubmission) {
+		code = apierror.CodeDuplicateSubmission
+	}
+	return models.ImportFieldError{Code: string(code), Error: apierror.Message(lang, code)}
+}
+
+func isBlankRecord(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
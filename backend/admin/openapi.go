@@ -0,0 +1,172 @@
+package admin
+
+import (
+	"net/http"
+	"strings"
+	"tuna/metrics"
+	"tuna/models"
+	"tuna/openapi"
+)
+
+// Spec 管理端的 OpenAPI 文档，与 SetupRouter 按 opts 注册的路由一一对应
+func Spec(opts Options) *openapi.Document {
+	d := openapi.New(openapi.Info{
+		Title:       "Tuna 管理端 API",
+		Version:     "1.0",
+		Description: "管理员登录、查询和审核用户资料。出错时响应体为统一的错误格式，提示信息按 Accept-Language 返回中文或英文",
+	})
+	d.Tags = append([]openapi.Tag{
+		{Name: "auth", Description: "登录和会话"},
+		{Name: "users", Description: "用户资料的查询、导入导出和审核"},
+	}, openapi.CommonTags...)
+	security := d.UseBearerAuth("POST /admin/login 返回的 token")
+
+	// authorized 需要登录的接口，补上认证失败和服务端错误的响应
+	authorized := func(method, path string, op *openapi.Operation) {
+		op.Security = security
+		op.Responses[http.StatusUnauthorized] = d.Error("未登录或 token 已失效")
+		if op.Responses[http.StatusInternalServerError] == nil {
+			op.Responses[http.StatusInternalServerError] = d.Error("服务端错误")
+		}
+		d.Add(method, path, op)
+	}
+	id := openapi.PathParam("id", "用户记录 ID", &openapi.Schema{Type: "integer", Format: "int64"})
+	message := openapi.JSON("操作成功", d.Schema(models.MessageResponse{}))
+
+	d.Add(http.MethodPost, "/admin/login", &openapi.Operation{
+		OperationID: "login",
+		Summary:     "管理员登录",
+		Tags:        []string{"auth"},
+		RequestBody: openapi.JSONBody(d.Schema(models.LoginRequest{})),
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSON("登录成功，返回 token 和过期时间", d.Schema(models.LoginResponse{})),
+			http.StatusBadRequest:          d.Error("请求参数校验失败"),
+			http.StatusUnauthorized:        d.Error("用户名或密码错误"),
+			http.StatusInternalServerError: d.Error("服务端错误"),
+		},
+	})
+	authorized(http.MethodPost, "/admin/logout", &openapi.Operation{
+		OperationID: "logout",
+		Summary:     "退出登录，使当前 token 失效",
+		Tags:        []string{"auth"},
+		Responses:   map[int]*openapi.Response{http.StatusOK: message},
+	})
+	authorized(http.MethodGet, "/admin/me", &openapi.Operation{
+		OperationID: "me",
+		Summary:     "获取当前登录的管理员",
+		Tags:        []string{"auth"},
+		Responses:   map[int]*openapi.Response{http.StatusOK: openapi.JSON("当前管理员", d.Schema(models.MeResponse{}))},
+	})
+
+	authorized(http.MethodGet, "/admin/users", &openapi.Operation{
+		OperationID: "listUsers",
+		Summary:     "分页获取用户列表",
+		Description: "created_from、created_to 支持 2006-01-02 或 RFC3339 格式，只给日期时包含当天；keyword 在姓名、邮箱、手机号、爱好中模糊搜索",
+		Tags:        []string{"users"},
+		Parameters:  d.Parameters("query", models.ListUsersRequest{}),
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:         openapi.JSON("当前页的记录和总数", d.Schema(models.ListUsersResponse{})),
+			http.StatusBadRequest: d.Error("查询参数校验失败"),
+		},
+	})
+	authorized(http.MethodGet, "/admin/users/export", &openapi.Operation{
+		OperationID: "exportUsers",
+		Summary:     "按筛选条件导出全部匹配记录",
+		Description: "筛选和排序参数与列表接口相同，不分页。columns 为逗号分隔的列名：" + strings.Join(exportColumnNames(), "、"),
+		Tags:        []string{"users"},
+		Parameters:  d.Parameters("query", models.ExportUsersRequest{}),
+		Responses: map[int]*openapi.Response{
+			http.StatusOK: {
+				Description: "导出文件，格式由 format 决定",
+				Headers: map[string]*openapi.Header{
+					"Content-Disposition": {Description: "attachment 及文件名", Schema: &openapi.Schema{Type: "string"}},
+				},
+				Content: map[string]*openapi.MediaType{
+					"text/csv":             {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+					"application/x-ndjson": {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+					"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet": {Schema: &openapi.Schema{Type: "string", Format: "binary"}},
+				},
+			},
+			http.StatusBadRequest: d.Error("查询参数校验失败或列名无效"),
+		},
+	})
+
+	form := d.FormSchema(models.ImportUsersRequest{})
+	form.Properties["file"] = &openapi.Schema{Type: "string", Format: "binary", Description: "CSV 或 XLSX 文件，按扩展名识别格式"}
+	form.Required = append(form.Required, "file")
+	authorized(http.MethodPost, "/admin/users/import", &openapi.Operation{
+		OperationID: "importUsers",
+		Summary:     "从 CSV 或 XLSX 批量导入",
+		Description: "第一行为表头，需要包含 " + strings.Join(importColumns, "、") + " 五列。dry_run 为 true 时完整执行校验和查重后回滚，不写入",
+		Tags:        []string{"users"},
+		RequestBody: &openapi.RequestBody{
+			Required: true,
+			Content:  map[string]*openapi.MediaType{"multipart/form-data": {Schema: form}},
+		},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                    openapi.JSON("导入结果和每行的错误", d.Schema(models.ImportUsersResponse{})),
+			http.StatusBadRequest:            d.Error("表单参数校验失败或文件无效"),
+			http.StatusRequestEntityTooLarge: d.Error("文件超过大小限制"),
+		},
+	})
+
+	authorized(http.MethodPut, "/admin/users/status", &openapi.Operation{
+		OperationID: "bulkUpdateUserStatus",
+		Summary:     "批量审核",
+		Description: "all_or_nothing（默认）模式下任意一条失败则全部不生效并返回 409；best_effort 模式跳过失败的记录",
+		Tags:        []string{"users"},
+		RequestBody: openapi.JSONBody(d.Schema(models.BulkUpdateStatusRequest{})),
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:         openapi.JSON("每个 id 的结果", d.Schema(models.BulkUpdateStatusResponse{})),
+			http.StatusBadRequest: d.Error("请求参数校验失败"),
+			http.StatusConflict:   openapi.JSON("all_or_nothing 模式下有记录失败，全部未生效", d.Schema(models.BulkUpdateStatusResponse{})),
+		},
+	})
+	authorized(http.MethodPut, "/admin/users/:id/status", &openapi.Operation{
+		OperationID: "updateUserStatus",
+		Summary:     "审核通过或拒绝",
+		Description: "pending 只能变为 approved 或 rejected，已审核的记录需要先 reopen",
+		Tags:        []string{"users"},
+		Parameters:  []*openapi.Parameter{id},
+		RequestBody: openapi.JSONBody(d.Schema(models.UpdateStatusRequest{})),
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:         message,
+			http.StatusBadRequest: d.Error("请求参数校验失败或拒绝时没有填写原因"),
+			http.StatusNotFound:   d.Error("记录不存在"),
+			http.StatusConflict:   d.Error("不允许的状态变更，details 中为 from 和 to"),
+		},
+	})
+	reopenBody := openapi.JSONBody(d.Schema(models.ReopenRequest{}))
+	reopenBody.Required = false
+	authorized(http.MethodPost, "/admin/users/:id/reopen", &openapi.Operation{
+		OperationID: "reopenUser",
+		Summary:     "把已审核的记录重新改为待审核",
+		Tags:        []string{"users"},
+		Parameters:  []*openapi.Parameter{id},
+		RequestBody: reopenBody,
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:         message,
+			http.StatusBadRequest: d.Error("请求参数校验失败"),
+			http.StatusNotFound:   d.Error("记录不存在"),
+			http.StatusConflict:   d.Error("记录已是待审核状态"),
+		},
+	})
+	authorized(http.MethodGet, "/admin/users/:id/history", &openapi.Operation{
+		OperationID: "getUserHistory",
+		Summary:     "获取记录的审核历史",
+		Tags:        []string{"users"},
+		Parameters:  []*openapi.Parameter{id},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:         openapi.JSON("记录和按时间先后排列的审核历史", d.Schema(models.UserHistoryResponse{})),
+			http.StatusBadRequest: d.Error("id 不是整数"),
+			http.StatusNotFound:   d.Error("记录不存在"),
+		},
+	})
+
+	d.AddHealth("/admin/health")
+	if opts.Metrics.Public() {
+		d.AddMetrics(metrics.Path)
+	}
+	d.AddDocs()
+	return d
+}
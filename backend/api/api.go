@@ -1,46 +1,92 @@
 package api
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"time"
+	"tuna/apierror"
+	"tuna/cors"
+	"tuna/health"
+	"tuna/logging"
+	"tuna/metrics"
 	"tuna/models"
+	"tuna/openapi"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRouter() *gin.Engine {
-	router := gin.Default()
+// Options 用户端路由的依赖
+type Options struct {
+	Users       models.UserRepository
+	Idempotency models.IdempotencyRepository
+	// IdempotencyTTL 保存 Idempotency-Key 响应的时长，为 0 时使用 DefaultIdempotencyTTL
+	IdempotencyTTL  time.Duration
+	DuplicatePolicy models.DuplicatePolicy
+	// RateLimit 提交接口的防刷中间件，为 nil 时不限流
+	RateLimit *RateLimiter
+	// TrustedProxies 允许通过 X-Forwarded-For 传递客户端 IP 的代理地址，为空时直接使用连接的来源地址
+	TrustedProxies []string
+	// CORS 跨域策略，零值表示不允许跨域访问
+	CORS    cors.Policy
+	Metrics metrics.Options
+	// Health 为 nil 时 /readyz 只检查是否正在退出
+	Health *health.Checker
+}
 
-	// CORS middleware
-	router.Use(func(c *gin.Context) {
-		origin := c.Request.Header.Get("Origin")
-		if origin != "" {
-			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
-			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		} else {
-			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		}
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
-		c.Writer.Header().Set("Access-Control-Expose-Headers", "Content-Length, Content-Type")
+type handler struct {
+	users           models.UserRepository
+	duplicatePolicy models.DuplicatePolicy
+}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
-		}
+func SetupRouter(opts Options) *gin.Engine {
+	h := &handler{
+		users:           opts.Users,
+		duplicatePolicy: opts.DuplicatePolicy,
+	}
+	if h.duplicatePolicy == "" {
+		h.duplicatePolicy = models.DuplicateReject
+	}
+	idempotencyTTL := opts.IdempotencyTTL
+	if idempotencyTTL <= 0 {
+		idempotencyTTL = DefaultIdempotencyTTL
+	}
+	router := gin.New()
+	router.Use(logging.Middleware(), apierror.Recovery())
+	if opts.Metrics.Enabled {
+		router.Use(metrics.Middleware("api"))
+	}
+	if err := router.SetTrustedProxies(opts.TrustedProxies); err != nil {
+		log.Printf("Invalid trusted proxies, ignoring X-Forwarded-For: %v", err)
+		router.SetTrustedProxies(nil)
+	}
 
-		c.Next()
-	})
+	router.Use(cors.Middleware(opts.CORS))
 
-	router.POST("/api/submit", submitUserInfo)
-	router.GET("/api/health", healthCheck)
+	// 重放 Idempotency-Key 时直接返回保存的响应，不占用限流额度
+	router.POST("/api/submit", limitBody(maxSubmitBodySize), idempotency(opts.Idempotency, "submit", idempotencyTTL),
+		opts.RateLimit.Handler(), h.submitUserInfo)
+	router.GET("/api/submissions/:token", h.getSubmissionStatus)
+	checker := opts.Health
+	if checker == nil {
+		checker = health.New(health.Options{})
+	}
+	router.GET("/api/health", checker.Live)
+	router.GET("/healthz", checker.Live)
+	router.GET("/readyz", checker.Ready)
+	if opts.Metrics.Public() {
+		router.GET(metrics.Path, gin.WrapH(metrics.Handler()))
+	}
+	openapi.Register(router, Spec(opts))
+	router.NoRoute(apierror.NoRoute)
 
 	return router
 }
 
-func submitUserInfo(c *gin.Context) {
+func (h *handler) submitUserInfo(c *gin.Context) {
 	var req models.CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.AbortInvalid(c, err)
 		return
 	}
 
@@ -52,17 +98,45 @@ func submitUserInfo(c *gin.Context) {
 		Age:   req.Age,
 	}
 
-	if err := models.CreateUserInfo(user); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save user info"})
+	merged, err := h.users.CreateUserInfo(c.Request.Context(), user, h.duplicatePolicy)
+	if errors.Is(err, models.ErrDuplicateSubmission) {
+		apierror.Abort(c, apierror.New(http.StatusConflict, apierror.CodeDuplicateSubmission))
 		return
 	}
+	if err != nil {
+		apierror.Abort(c, apierror.Internal(err))
+		return
+	}
+	metrics.SubmissionCreated("api", merged)
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "User info submitted successfully",
-		"id":      user.ID,
-	})
+	resp := models.SubmitResponse{
+		Message:       "User info submitted successfully",
+		ID:            user.ID,
+		TrackingToken: user.TrackingToken,
+		Merged:        merged,
+	}
+	if merged {
+		resp.Message = "User info merged into the existing pending submission"
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
-func healthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+func (h *handler) getSubmissionStatus(c *gin.Context) {
+	user, err := h.users.GetUserByTrackingToken(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		apierror.Abort(c, apierror.Internal(err))
+		return
+	}
+	// 凭证无效时统一返回 404，不区分原因
+	if user == nil {
+		apierror.Abort(c, apierror.New(http.StatusNotFound, apierror.CodeSubmissionNotFound))
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SubmissionStatusResponse{
+		Status:       user.Status,
+		RejectReason: user.RejectReason,
+		SubmittedAt:  user.CreatedAt,
+		UpdatedAt:    user.UpdatedAt,
+	})
 }
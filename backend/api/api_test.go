@@ -0,0 +1,396 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	"tuna/apierror"
+	"tuna/apierror/apierrortest"
+	"tuna/models"
+	"tuna/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	logrus.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+type testServer struct {
+	t      *testing.T
+	users  models.UserRepository
+	router *gin.Engine
+}
+
+func newTestServer(t *testing.T, opts Options) *testServer {
+	t.Helper()
+	if opts.Users == nil {
+		opts.Users = models.NewMemoryUserRepository()
+	}
+	if opts.Idempotency == nil {
+		opts.Idempotency = models.NewMemoryIdempotencyRepository()
+	}
+	return &testServer{t: t, users: opts.Users, router: SetupRouter(opts)}
+}
+
+func (s *testServer) do(req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// submit 提交 body，header 为额外的请求头，如 Idempotency-Key
+func (s *testServer) submit(body any, header map[string]string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/api/submit", bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	return s.do(req)
+}
+
+func zhangsan() models.CreateUserRequest {
+	return models.CreateUserRequest{Name: "张三", Email: "zhangsan@example.com", Phone: "13800138000", Hobby: "阅读", Age: 25}
+}
+
+func TestSubmit(t *testing.T) {
+	s := newTestServer(t, Options{})
+
+	w := s.submit(zhangsan(), nil)
+	apierrortest.ExpectStatus(t, w, http.StatusOK)
+	created := apierrortest.Decode[models.SubmitResponse](t, w)
+	if created.ID == 0 || created.Merged {
+		t.Fatalf("response = %+v", created)
+	}
+	stored, err := s.users.GetUserByID(context.Background(), created.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("stored user = %v, %v", stored, err)
+	}
+	if stored.Name != "张三" || stored.Status != models.StatusPending {
+		t.Errorf("stored user = %+v", stored)
+	}
+}
+
+func TestTrackStatus(t *testing.T) {
+	s := newTestServer(t, Options{})
+
+	created := apierrortest.Decode[models.SubmitResponse](t, s.submit(zhangsan(), nil))
+	if created.TrackingToken == "" {
+		t.Fatal("submit returned no tracking token")
+	}
+
+	w := s.do(httptest.NewRequest(http.MethodGet, "/api/submissions/"+created.TrackingToken, nil))
+	apierrortest.ExpectStatus(t, w, http.StatusOK)
+	if status := apierrortest.Decode[models.SubmissionStatusResponse](t, w); status.Status != models.StatusPending {
+		t.Errorf("status = %+v", status)
+	}
+
+	apierrortest.ExpectCode(t, s.do(httptest.NewRequest(http.MethodGet, "/api/submissions/unknown", nil)),
+		http.StatusNotFound, apierror.CodeSubmissionNotFound)
+}
+
+func TestSubmitValidation(t *testing.T) {
+	s := newTestServer(t, Options{})
+
+	w := s.submit(map[string]any{"name": "李四", "email": "not-an-email", "phone": "123", "age": 200},
+		map[string]string{"Accept-Language": "zh-CN"})
+	resp := apierrortest.ExpectCode(t, w, http.StatusBadRequest, apierror.CodeValidationFailed)
+	rules := map[string]string{}
+	for _, f := range resp.Fields {
+		rules[f.Field] = f.Rule
+	}
+	for field, rule := range map[string]string{"email": "email", "phone": "phone", "age": "max", "hobby": "required"} {
+		if rules[field] != rule {
+			t.Errorf("field %s: rule %q, want %q (fields: %+v)", field, rules[field], rule, resp.Fields)
+		}
+	}
+	if w.Header().Get("Content-Language") != string(apierror.LangZH) {
+		t.Errorf("Content-Language = %q", w.Header().Get("Content-Language"))
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/submit", strings.NewReader("{"))
+	req.Header.Set("Content-Type", "application/json")
+	apierrortest.ExpectCode(t, s.do(req), http.StatusBadRequest, apierror.CodeInvalidRequest)
+}
+
+func TestSubmitBodyTooLarge(t *testing.T) {
+	s := newTestServer(t, Options{})
+	body := map[string]string{"hobby": strings.Repeat("x", maxSubmitBodySize)}
+	apierrortest.ExpectCode(t, s.submit(body, nil), http.StatusRequestEntityTooLarge, apierror.CodeRequestTooLarge)
+}
+
+func TestDuplicatePolicy(t *testing.T) {
+	t.Run("reject", func(t *testing.T) {
+		s := newTestServer(t, Options{})
+		apierrortest.ExpectStatus(t, s.submit(zhangsan(), nil), http.StatusOK)
+
+		// 手机号写法不同，规范化后相同
+		again := zhangsan()
+		again.Email = "other@example.com"
+		again.Phone = "+86 138-0013-8000"
+		apierrortest.ExpectCode(t, s.submit(again, nil), http.StatusConflict, apierror.CodeDuplicateSubmission)
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		s := newTestServer(t, Options{DuplicatePolicy: models.DuplicateMerge})
+		first := apierrortest.Decode[models.SubmitResponse](t, s.submit(zhangsan(), nil))
+
+		again := zhangsan()
+		again.Email = "ZhangSan@Example.com"
+		again.Hobby = "跑步"
+		w := s.submit(again, nil)
+		apierrortest.ExpectStatus(t, w, http.StatusOK)
+		merged := apierrortest.Decode[models.SubmitResponse](t, w)
+		if !merged.Merged || merged.ID != first.ID {
+			t.Fatalf("response = %+v, want merged into %d", merged, first.ID)
+		}
+		// 合并后换发凭证，原凭证失效
+		if merged.TrackingToken == first.TrackingToken {
+			t.Error("merge returned the original tracking token")
+		}
+		apierrortest.ExpectStatus(t, s.do(httptest.NewRequest(http.MethodGet, "/api/submissions/"+first.TrackingToken, nil)), http.StatusNotFound)
+		apierrortest.ExpectStatus(t, s.do(httptest.NewRequest(http.MethodGet, "/api/submissions/"+merged.TrackingToken, nil)), http.StatusOK)
+
+		stored, _ := s.users.GetUserByID(context.Background(), first.ID)
+		if stored.Hobby != "跑步" {
+			t.Errorf("hobby = %q, want the merged value", stored.Hobby)
+		}
+	})
+
+	t.Run("merge requires both email and phone", func(t *testing.T) {
+		s := newTestServer(t, Options{DuplicatePolicy: models.DuplicateMerge})
+		first := apierrortest.Decode[models.SubmitResponse](t, s.submit(zhangsan(), nil))
+
+		// 只知道对方邮箱的人不能覆盖对方的记录
+		attacker := zhangsan()
+		attacker.Name = "王五"
+		attacker.Phone = "13900139000"
+		apierrortest.ExpectCode(t, s.submit(attacker, nil), http.StatusConflict, apierror.CodeDuplicateSubmission)
+
+		stored, _ := s.users.GetUserByID(context.Background(), first.ID)
+		if stored.Name != "张三" || stored.TrackingToken != first.TrackingToken {
+			t.Errorf("stored user was modified: %+v", stored)
+		}
+	})
+
+	t.Run("allow", func(t *testing.T) {
+		s := newTestServer(t, Options{DuplicatePolicy: models.DuplicateAllow})
+		apierrortest.ExpectStatus(t, s.submit(zhangsan(), nil), http.StatusOK)
+		apierrortest.ExpectStatus(t, s.submit(zhangsan(), nil), http.StatusOK)
+	})
+}
+
+func TestIdempotency(t *testing.T) {
+	s := newTestServer(t, Options{})
+	key := map[string]string{idempotencyKeyHeader: "key-1"}
+
+	first := s.submit(zhangsan(), key)
+	apierrortest.ExpectStatus(t, first, http.StatusOK)
+	replay := s.submit(zhangsan(), key)
+	apierrortest.ExpectStatus(t, replay, http.StatusOK)
+	if replay.Header().Get("Idempotent-Replayed") != "true" || replay.Body.String() != first.Body.String() {
+		t.Errorf("replay = %s (replayed %q), want %s", replay.Body.String(), replay.Header().Get("Idempotent-Replayed"), first.Body.String())
+	}
+	if n, _ := s.users.CountUsers(context.Background(), models.UserFilter{}); n != 1 {
+		t.Errorf("%d users stored, want 1", n)
+	}
+
+	changed := zhangsan()
+	changed.Age++
+	apierrortest.ExpectCode(t, s.submit(changed, key), http.StatusUnprocessableEntity, apierror.CodeIdempotencyKeyMismatch)
+
+	long := map[string]string{idempotencyKeyHeader: strings.Repeat("k", maxIdempotencyKeyLen+1)}
+	apierrortest.ExpectCode(t, s.submit(zhangsan(), long), http.StatusBadRequest, apierror.CodeInvalidIdempotencyKey)
+}
+
+func TestIdempotencyLock(t *testing.T) {
+	store := models.NewMemoryIdempotencyRepository()
+	s := newTestServer(t, Options{Idempotency: store})
+	data, _ := json.Marshal(zhangsan())
+	sum := sha256.Sum256(data)
+
+	// 模拟首个请求在写回结果前进程退出，留下处理中的记录
+	reserve := func(key string, lockedUntil time.Time) {
+		t.Helper()
+		_, err := store.Reserve(context.Background(), &models.IdempotencyRecord{
+			Scope:       "submit",
+			Key:         key,
+			RequestHash: hex.EncodeToString(sum[:]),
+			LockedUntil: lockedUntil,
+			ExpiresAt:   time.Now().Add(time.Hour),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reserve("locked", time.Now().Add(time.Minute))
+	apierrortest.ExpectCode(t, s.submit(zhangsan(), map[string]string{idempotencyKeyHeader: "locked"}),
+		http.StatusConflict, apierror.CodeIdempotencyRequestInProgress)
+
+	reserve("stale", time.Now().Add(-time.Second))
+	taken := s.submit(zhangsan(), map[string]string{idempotencyKeyHeader: "stale"})
+	apierrortest.ExpectStatus(t, taken, http.StatusOK)
+	if taken.Header().Get("Idempotent-Replayed") == "true" {
+		t.Error("stale lock was replayed instead of taken over")
+	}
+	replay := s.submit(zhangsan(), map[string]string{idempotencyKeyHeader: "stale"})
+	if replay.Header().Get("Idempotent-Replayed") != "true" || replay.Body.String() != taken.Body.String() {
+		t.Errorf("replay = %s, want %s", replay.Body.String(), taken.Body.String())
+	}
+}
+
+// purgeRecorder 记录每次 DeleteExpired 删除的条数
+type purgeRecorder struct {
+	models.IdempotencyRepository
+	deleted chan int64
+}
+
+func (r purgeRecorder) DeleteExpired(ctx context.Context) (int64, error) {
+	n, err := r.IdempotencyRepository.DeleteExpired(ctx)
+	r.deleted <- n
+	return n, err
+}
+
+func TestPurgeIdempotencyKeys(t *testing.T) {
+	store := purgeRecorder{models.NewMemoryIdempotencyRepository(), make(chan int64)}
+	now := time.Now()
+	for key, expiresAt := range map[string]time.Time{"expired": now.Add(-time.Second), "live": now.Add(time.Hour)} {
+		record := &models.IdempotencyRecord{Scope: "submit", Key: key, LockedUntil: now.Add(time.Minute), ExpiresAt: expiresAt}
+		if _, err := store.Reserve(context.Background(), record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		PurgeIdempotencyKeys(ctx, store, time.Millisecond)
+	}()
+	if n := <-store.deleted; n != 1 {
+		t.Errorf("first purge deleted %d keys, want 1", n)
+	}
+	if n := <-store.deleted; n != 0 {
+		t.Errorf("second purge deleted %d keys, want 0", n)
+	}
+	cancel()
+	// 退出前可能还有一次清理在等待发送结果
+	for stopped := false; !stopped; {
+		select {
+		case <-store.deleted:
+		case <-done:
+			stopped = true
+		}
+	}
+
+	existing, err := store.Reserve(context.Background(), &models.IdempotencyRecord{
+		Scope: "submit", Key: "live", LockedUntil: now.Add(time.Minute), ExpiresAt: now.Add(time.Hour),
+	})
+	if err != nil || existing == nil {
+		t.Errorf("live key = %v, %v, want kept", existing, err)
+	}
+}
+
+// cancelAwareIdempotency 与 MySQL 实现一样，ctx 已取消时 Complete 和 Release 返回错误
+type cancelAwareIdempotency struct {
+	models.IdempotencyRepository
+}
+
+func (r cancelAwareIdempotency) Complete(ctx context.Context, scope, key string, statusCode int, body []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.IdempotencyRepository.Complete(ctx, scope, key, statusCode, body)
+}
+
+func (r cancelAwareIdempotency) Release(ctx context.Context, scope, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return r.IdempotencyRepository.Release(ctx, scope, key)
+}
+
+func TestIdempotencyAfterClientDisconnect(t *testing.T) {
+	s := newTestServer(t, Options{Idempotency: cancelAwareIdempotency{models.NewMemoryIdempotencyRepository()}})
+
+	// 客户端在响应返回前断开，响应仍然要保存，重试时重放而不是一直返回 409
+	data, _ := json.Marshal(zhangsan())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodPost, "/api/submit", bytes.NewReader(data)).WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(idempotencyKeyHeader, "key-1")
+	apierrortest.ExpectStatus(t, s.do(req), http.StatusOK)
+
+	retry := s.submit(zhangsan(), map[string]string{idempotencyKeyHeader: "key-1"})
+	apierrortest.ExpectStatus(t, retry, http.StatusOK)
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("retry was not replayed: %s", retry.Body.String())
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	limiter := NewRateLimiter(RateLimitOptions{
+		PerContact:    ratelimit.Limit{Requests: 1, Per: time.Hour},
+		HoneypotField: "website",
+	})
+	s := newTestServer(t, Options{RateLimit: limiter, DuplicatePolicy: models.DuplicateAllow})
+	key := map[string]string{idempotencyKeyHeader: "key-1"}
+
+	apierrortest.ExpectStatus(t, s.submit(zhangsan(), key), http.StatusOK)
+	// 重放不占用限流额度
+	for i := 0; i < 3; i++ {
+		apierrortest.ExpectStatus(t, s.submit(zhangsan(), key), http.StatusOK)
+	}
+
+	// 同一手机号换一种写法仍然计入同一个额度
+	again := zhangsan()
+	again.Email = "other@example.com"
+	again.Phone = "+86 138 0013 8000"
+	w := s.submit(again, map[string]string{idempotencyKeyHeader: "key-2"})
+	apierrortest.ExpectCode(t, w, http.StatusTooManyRequests, apierror.CodeRateLimited)
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("429 without Retry-After")
+	}
+	// 被限流的请求不保存响应，key 可以在额度恢复后重试
+	limiter.Update(RateLimitOptions{})
+	apierrortest.ExpectStatus(t, s.submit(again, map[string]string{idempotencyKeyHeader: "key-2"}), http.StatusOK)
+
+	// 蜜罐字段有值时假装成功，但不保存
+	limiter.Update(RateLimitOptions{HoneypotField: "website"})
+	bot := map[string]any{"name": "bot", "email": "bot@example.com", "phone": "13600136000", "hobby": "x", "age": 20, "website": "http://spam.example"}
+	apierrortest.ExpectStatus(t, s.submit(bot, nil), http.StatusOK)
+	if n, _ := s.users.CountUsers(context.Background(), models.UserFilter{Keyword: "bot"}); n != 0 {
+		t.Errorf("honeypot submission was stored")
+	}
+}
+
+func TestMinFillTime(t *testing.T) {
+	limiter := NewRateLimiter(RateLimitOptions{MinFillTime: time.Minute})
+	s := newTestServer(t, Options{RateLimit: limiter})
+
+	body := map[string]any{"name": "张三", "email": "zhangsan@example.com", "phone": "13800138000", "hobby": "阅读", "age": 25,
+		formStartedAtField: time.Now().UnixMilli()}
+	apierrortest.ExpectCode(t, s.submit(body, nil), http.StatusBadRequest, apierror.CodeFormSubmittedTooFast)
+
+	body[formStartedAtField] = time.Now().Add(-2 * time.Minute).UnixMilli()
+	apierrortest.ExpectStatus(t, s.submit(body, nil), http.StatusOK)
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"tuna/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSubmitBodySize 提交接口请求体的上限
+const maxSubmitBodySize = 64 << 10
+
+// limitBody 放在最前面，一次读入整个请求体并放回，后面的防刷、幂等中间件和 handler 都从内存中读取。
+// 超过 max 时返回 413
+func limitBody(max int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, max))
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			apierror.Abort(c, apierror.New(http.StatusRequestEntityTooLarge, apierror.CodeRequestTooLarge, max>>10))
+			return
+		}
+		if err != nil {
+			apierror.AbortInvalid(c, err)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}
+}
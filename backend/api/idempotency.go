@@ -0,0 +1,151 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"time"
+	"tuna/apierror"
+	"tuna/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	maxIdempotencyKeyLen = 128
+
+	// DefaultIdempotencyTTL 未配置时保存响应的时长
+	DefaultIdempotencyTTL = 24 * time.Hour
+	// idempotencyWriteTimeout 请求结束后保存或释放 key 的超时时间
+	idempotencyWriteTimeout = 5 * time.Second
+	// idempotencyLockTimeout 处理中的 key 的锁定时长。进程在写回结果前退出时，
+	// 锁过期后同一个 key 的请求可以接管，不用等到 TTL 过期
+	idempotencyLockTimeout = 2 * time.Minute
+	// DefaultIdempotencyPurgeInterval 清理过期 key 的间隔
+	DefaultIdempotencyPurgeInterval = time.Hour
+)
+
+// bodyRecorder 在写出响应的同时保留一份副本
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotency 处理 Idempotency-Key 请求头：同一个 key 的重复请求直接重放首次的响应，
+// key 相同但请求体不同返回 422，首次请求尚未完成时返回 409，首次请求中断且锁已过期时由重试的请求接管。
+// 不带该请求头的请求不受影响
+func idempotency(store models.IdempotencyRepository, scope string, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			apierror.Abort(c, apierror.New(http.StatusBadRequest, apierror.CodeInvalidIdempotencyKey))
+			return
+		}
+
+		// 请求体已由 limitBody 限制大小并读入内存
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			apierror.AbortInvalid(c, err)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+
+		ctx := c.Request.Context()
+		now := time.Now()
+		record := &models.IdempotencyRecord{
+			Scope:       scope,
+			Key:         key,
+			RequestHash: hex.EncodeToString(sum[:]),
+			LockedUntil: now.Add(idempotencyLockTimeout),
+			ExpiresAt:   now.Add(ttl),
+		}
+		existing, err := store.Reserve(ctx, record)
+		if err != nil {
+			apierror.Abort(c, apierror.Internal(err))
+			return
+		}
+		if existing != nil {
+			switch {
+			case existing.RequestHash != record.RequestHash:
+				apierror.Abort(c, apierror.New(http.StatusUnprocessableEntity, apierror.CodeIdempotencyKeyMismatch))
+			case existing.StatusCode == 0:
+				apierror.Abort(c, apierror.New(http.StatusConflict, apierror.CodeIdempotencyRequestInProgress))
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(existing.StatusCode, "application/json; charset=utf-8", existing.ResponseBody)
+				c.Abort()
+			}
+			return
+		}
+
+		// 客户端断开或超时后请求的 ctx 已取消，正是需要重试的场景，结果仍然要写回，
+		// 否则 key 会一直处于处理中，直到过期
+		writeCtx := func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.WithoutCancel(ctx), idempotencyWriteTimeout)
+		}
+
+		// 服务端错误、限流或 panic 时不保存响应，释放 key 让客户端可以重试
+		completed := false
+		defer func() {
+			if !completed {
+				releaseCtx, cancel := writeCtx()
+				defer cancel()
+				_ = store.Release(releaseCtx, scope, key)
+			}
+		}()
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		if status := c.Writer.Status(); status < http.StatusInternalServerError && status != http.StatusTooManyRequests {
+			completeCtx, cancel := writeCtx()
+			defer cancel()
+			completed = store.Complete(completeCtx, scope, key, status, recorder.body.Bytes()) == nil
+		}
+	}
+}
+
+// PurgeIdempotencyKeys 每隔 interval 删除过期的 key，直到 ctx 取消。Reserve 只清理当前请求的 key，
+// 不再出现的 key 靠这里删除
+func PurgeIdempotencyKeys(ctx context.Context, store models.IdempotencyRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		n, err := store.DeleteExpired(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				logrus.WithError(err).Error("Failed to purge expired idempotency keys")
+			}
+			continue
+		}
+		if n > 0 {
+			logrus.WithField("count", n).Info("Purged expired idempotency keys")
+		}
+	}
+}
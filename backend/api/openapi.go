@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"tuna/metrics"
+	"tuna/models"
+	"tuna/openapi"
+)
+
+// Spec 用户端的 OpenAPI 文档，与 SetupRouter 按 opts 注册的路由一一对应
+func Spec(opts Options) *openapi.Document {
+	d := openapi.New(openapi.Info{
+		Title:       "Tuna 用户端 API",
+		Version:     "1.0",
+		Description: "提交用户资料和查询审核进度。出错时响应体为统一的错误格式，提示信息按 Accept-Language 返回中文或英文",
+	})
+	d.Tags = append([]openapi.Tag{{Name: "submissions", Description: "用户资料提交"}}, openapi.CommonTags...)
+
+	d.Add(http.MethodPost, "/api/submit", &openapi.Operation{
+		OperationID: "submitUserInfo",
+		Summary:     "提交用户资料",
+		Description: "同一邮箱或手机号已有待审核记录时按 submission.duplicate_policy 处理。开启防刷时，请求体还可以带蜜罐字段和 " +
+			"form_started_at（表单加载时间，Unix 毫秒）；蜜罐字段有值时返回 200 但不保存",
+		Tags: []string{"submissions"},
+		Parameters: []*openapi.Parameter{
+			openapi.HeaderParam(idempotencyKeyHeader, "同一个 key 的重复请求直接返回首次请求的响应，最长 128 个字符",
+				&openapi.Schema{Type: "string", MaxLength: ptr(maxIdempotencyKeyLen)}),
+		},
+		RequestBody: openapi.JSONBody(d.Schema(models.CreateUserRequest{})),
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                    submitted(d),
+			http.StatusBadRequest:            d.Error("请求参数校验失败、Idempotency-Key 不合法或填写时间过短"),
+			http.StatusConflict:              d.Error("已有待审核的重复提交，或同一个 Idempotency-Key 的首次请求尚未完成"),
+			http.StatusRequestEntityTooLarge: d.Error("请求体超过 64KB"),
+			http.StatusUnprocessableEntity:   d.Error("同一个 Idempotency-Key 配合了不同的请求体"),
+			http.StatusTooManyRequests:       rateLimited(d),
+			http.StatusInternalServerError:   d.Error("服务端错误"),
+		},
+	})
+	d.Add(http.MethodGet, "/api/submissions/:token", &openapi.Operation{
+		OperationID: "getSubmissionStatus",
+		Summary:     "凭查询凭证获取审核进度",
+		Tags:        []string{"submissions"},
+		Parameters: []*openapi.Parameter{
+			openapi.PathParam("token", "提交时返回的 tracking_token", &openapi.Schema{Type: "string"}),
+		},
+		Responses: map[int]*openapi.Response{
+			http.StatusOK:                  openapi.JSON("审核状态、拒绝原因和时间", d.Schema(models.SubmissionStatusResponse{})),
+			http.StatusNotFound:            d.Error("凭证无效"),
+			http.StatusInternalServerError: d.Error("服务端错误"),
+		},
+	})
+
+	d.AddHealth("/api/health")
+	if opts.Metrics.Public() {
+		d.AddMetrics(metrics.Path)
+	}
+	d.AddDocs()
+	return d
+}
+
+func submitted(d *openapi.Document) *openapi.Response {
+	resp := openapi.JSON("提交成功，返回记录 ID 和查询凭证；merged 为 true 表示合并到了已有的待审核记录", d.Schema(models.SubmitResponse{}))
+	resp.Headers = map[string]*openapi.Header{
+		"Idempotent-Replayed": {Description: "为 true 时表示重放了首次请求的响应", Schema: &openapi.Schema{Type: "string"}},
+	}
+	return resp
+}
+
+func rateLimited(d *openapi.Document) *openapi.Response {
+	resp := d.Error("超出限流")
+	resp.Headers = map[string]*openapi.Header{
+		"Retry-After": {Description: "需要等待的秒数", Schema: &openapi.Schema{Type: "integer"}},
+	}
+	return resp
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"tuna/apierror"
+	"tuna/logging"
+	"tuna/models"
+	"tuna/ratelimit"
+	"tuna/validation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// formStartedAtField 前端在表单加载时记录的时间（Unix 毫秒），用于判断填写耗时
+const formStartedAtField = "form_started_at"
+
+// RateLimitOptions 提交接口的防刷配置，零值表示全部关闭
+type RateLimitOptions struct {
+	// Store 为 nil 时使用进程内的 ratelimit.MemoryStore
+	Store ratelimit.Store
+	// PerIP 按客户端 IP 限流
+	PerIP ratelimit.Limit
+	// PerContact 按邮箱和手机号分别限流
+	PerContact ratelimit.Limit
+	// HoneypotField 隐藏的表单字段名，正常用户不会填写，有值时假装提交成功但不保存
+	HoneypotField string
+	// MinFillTime 从表单加载到提交的最短时间，大于 0 时请求体必须带 form_started_at
+	MinFillTime time.Duration
+}
+
+func (o RateLimitOptions) enabled() bool {
+	return o.PerIP.Enabled() || o.PerContact.Enabled() || o.HoneypotField != "" || o.MinFillTime > 0
+}
+
+// RateLimiter 提交接口的防刷中间件，运行中可以通过 Update 替换配置，已有的限流计数保留
+type RateLimiter struct {
+	store ratelimit.Store
+	opts  atomic.Pointer[RateLimitOptions]
+}
+
+// NewRateLimiter opts.Store 为 nil 时使用进程内的 ratelimit.MemoryStore
+func NewRateLimiter(opts RateLimitOptions) *RateLimiter {
+	store := opts.Store
+	if store == nil {
+		store = ratelimit.NewMemoryStore()
+	}
+	l := &RateLimiter{store: store}
+	l.Update(opts)
+	return l
+}
+
+// Update 替换限流配置，之后的请求按新配置检查。opts.Store 被忽略
+func (l *RateLimiter) Update(opts RateLimitOptions) {
+	opts.Store = l.store
+	l.opts.Store(&opts)
+}
+
+// Handler 放在 idempotency 之后，重放的请求不经过限流。l 为 nil 时不限流
+func (l *RateLimiter) Handler() gin.HandlerFunc {
+	if l == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return l.handle
+}
+
+func (l *RateLimiter) handle(c *gin.Context) {
+	opts := l.opts.Load()
+	if !opts.enabled() {
+		c.Next()
+		return
+	}
+	store := l.store
+
+	if !allow(c, store, "ip:"+c.ClientIP(), opts.PerIP) {
+		return
+	}
+
+	// 请求体已由 limitBody 限制大小并读入内存
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		apierror.AbortInvalid(c, err)
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	// 请求体不是 JSON 对象时交给后面的参数校验处理
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) != nil {
+		c.Next()
+		return
+	}
+
+	if opts.HoneypotField != "" && stringField(fields, opts.HoneypotField) != "" {
+		logging.FromContext(c.Request.Context()).WithField("client_ip", c.ClientIP()).Warn("Honeypot field filled, dropping submission")
+		// 响应结构与正常提交相同，不让对方看出被识别
+		c.AbortWithStatusJSON(http.StatusOK, models.SubmitResponse{Message: "User info submitted successfully"})
+		return
+	}
+
+	if opts.MinFillTime > 0 {
+		startedAt, err := strconv.ParseInt(stringField(fields, formStartedAtField), 10, 64)
+		if err != nil || time.Since(time.UnixMilli(startedAt)) < opts.MinFillTime {
+			apierror.Abort(c, apierror.New(http.StatusBadRequest, apierror.CodeFormSubmittedTooFast))
+			return
+		}
+	}
+
+	// 按规范化后的值计数，换一种写法不能绕过限制
+	if email := validation.NormalizeEmail(stringField(fields, "email")); email != "" {
+		if !allow(c, store, "email:"+email, opts.PerContact) {
+			return
+		}
+	}
+	if phone := stringField(fields, "phone"); phone != "" {
+		if e164, err := validation.NormalizePhone(phone); err == nil {
+			phone = e164
+		}
+		if !allow(c, store, "phone:"+phone, opts.PerContact) {
+			return
+		}
+	}
+
+	c.Next()
+}
+
+// allow 超出限额时写入 429 并返回 false。限流存储出错时放行，不影响正常提交
+func allow(c *gin.Context, store ratelimit.Store, key string, limit ratelimit.Limit) bool {
+	if !limit.Enabled() {
+		return true
+	}
+	ok, retryAfter, err := store.Allow(c.Request.Context(), key, limit)
+	if err != nil {
+		logging.FromContext(c.Request.Context()).WithError(err).Error("Rate limit store error")
+		return true
+	}
+	if ok {
+		return true
+	}
+
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	apierror.Abort(c, apierror.New(http.StatusTooManyRequests, apierror.CodeRateLimited))
+	return false
+}
+
+// stringField 读取字符串或数字字段，其他类型返回空字符串
+func stringField(fields map[string]json.RawMessage, name string) string {
+	raw, ok := fields[name]
+	if !ok {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return strings.TrimSpace(s)
+	}
+	var n json.Number
+	if json.Unmarshal(raw, &n) == nil {
+		return n.String()
+	}
+	return ""
+}
@@ -0,0 +1,277 @@
+// Package apierror api 和 admin 共用的错误响应：稳定的错误码、按 Accept-Language
+// 本地化的提示信息，以及参数校验失败时逐个字段的错误
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"tuna/logging"
+	"tuna/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+// Code 错误码，客户端按错误码判断错误类型，取值发布后不再修改
+type Code string
+
+const (
+	CodeInvalidRequest   Code = "invalid_request"
+	CodeValidationFailed Code = "validation_failed"
+	CodeNotFound         Code = "not_found"
+	CodeInternal         Code = "internal_error"
+
+	CodeUnauthorized       Code = "unauthorized"
+	CodeInvalidToken       Code = "invalid_token"
+	CodeInvalidCredentials Code = "invalid_credentials"
+
+	CodeUserNotFound            Code = "user_not_found"
+	CodeSubmissionNotFound      Code = "submission_not_found"
+	CodeDuplicateSubmission     Code = "duplicate_submission"
+	CodeInvalidStatusTransition Code = "invalid_status_transition"
+	CodeRejectReasonRequired    Code = "reject_reason_required"
+	CodeRolledBack              Code = "rolled_back"
+
+	CodeInvalidIdempotencyKey        Code = "invalid_idempotency_key"
+	CodeIdempotencyKeyMismatch       Code = "idempotency_key_mismatch"
+	CodeIdempotencyRequestInProgress Code = "idempotency_request_in_progress"
+	CodeRateLimited                  Code = "rate_limited"
+	CodeFormSubmittedTooFast         Code = "form_submitted_too_fast"
+	CodeRequestTooLarge              Code = "request_too_large"
+
+	CodeFileRequired         Code = "file_required"
+	CodeFileTooLarge         Code = "file_too_large"
+	CodeFileEmpty            Code = "file_empty"
+	CodeInvalidFile          Code = "invalid_file"
+	CodeTooManyRows          Code = "too_many_rows"
+	CodeMissingColumns       Code = "missing_columns"
+	CodeMergeRequiresPending Code = "merge_requires_pending"
+)
+
+// Error 处理请求失败时返回给客户端的错误
+type Error struct {
+	Status int
+	Code   Code
+	// Args 填入提示信息模板的参数
+	Args []any
+	// Fields 参数校验失败的字段
+	Fields []FieldError
+	// Details 附加在响应中的结构化信息，如状态变更的 from/to
+	Details map[string]any
+	// Err 原始错误，只写入日志，不返回给客户端
+	Err error
+}
+
+// FieldError 单个字段的校验错误，Rule 为校验规则名，如 required、email
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Response 错误响应体。error 为本地化后的提示，可以直接展示给用户
+type Response struct {
+	Code    Code           `json:"code"`
+	Error   string         `json:"error"`
+	Fields  []FieldError   `json:"fields,omitempty"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+func New(status int, code Code, args ...any) *Error {
+	return &Error{Status: status, Code: code, Args: args}
+}
+
+// Internal 500 错误，err 只写入日志
+func Internal(err error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Err: err}
+}
+
+func (e *Error) Error() string {
+	msg := Message(LangEN, e.Code, e.Args...)
+	if e.Err != nil {
+		return msg + ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithDetail 在响应的 details 中加入一项
+func (e *Error) WithDetail(key string, value any) *Error {
+	if e.Details == nil {
+		e.Details = map[string]any{}
+	}
+	e.Details[key] = value
+	return e
+}
+
+// WithErr 记录原始错误，用于日志
+func (e *Error) WithErr(err error) *Error {
+	e.Err = err
+	return e
+}
+
+// Response 按语言生成响应体
+func (e *Error) Response(lang Lang) Response {
+	return Response{
+		Code:    e.Code,
+		Error:   Message(lang, e.Code, e.Args...),
+		Fields:  e.Fields,
+		Details: e.Details,
+	}
+}
+
+// Abort 按请求的语言写入错误响应并中止后续处理，Err 不为空时写入日志
+func Abort(c *gin.Context, e *Error) {
+	lang := Language(c)
+	if e.Err != nil {
+		logging.FromContext(c.Request.Context()).WithFields(logrus.Fields{
+			"method": c.Request.Method,
+			"route":  c.FullPath(),
+			"code":   e.Code,
+		}).WithError(e.Err).Error("request failed")
+	}
+	c.Header("Content-Language", string(lang))
+	c.AbortWithStatusJSON(e.Status, e.Response(lang))
+}
+
+// Invalid 把参数绑定或校验的错误转换为 400，字段提示按 lang 生成。
+// 上传的文件超过大小限制时返回 413
+func Invalid(err error, lang Lang) *Error {
+	if fields, ok := Fields(err, lang); ok {
+		return &Error{Status: http.StatusBadRequest, Code: CodeValidationFailed, Fields: fields}
+	}
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return New(http.StatusRequestEntityTooLarge, CodeFileTooLarge, maxBytesErr.Limit>>20)
+	}
+	return &Error{Status: http.StatusBadRequest, Code: CodeInvalidRequest}
+}
+
+// AbortInvalid 等同于 Abort(c, Invalid(err, Language(c)))
+func AbortInvalid(c *gin.Context, err error) {
+	Abort(c, Invalid(err, Language(c)))
+}
+
+// Fields 把校验错误转换为字段错误列表，err 不是校验错误时返回 false。
+// 支持 validator 的校验结果、*validation.FieldError 和 JSON 字段类型错误
+func Fields(err error, lang Lang) ([]FieldError, bool) {
+	var validationErrors validator.ValidationErrors
+	var fieldErr *validation.FieldError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &validationErrors):
+		fields := make([]FieldError, len(validationErrors))
+		for i, fe := range validationErrors {
+			fields[i] = FieldError{
+				Field:   fe.Field(),
+				Rule:    fe.Tag(),
+				Message: validatorMessage(lang, fe),
+			}
+		}
+		return fields, true
+	case errors.As(err, &fieldErr):
+		return []FieldError{{
+			Field:   fieldErr.Field,
+			Rule:    fieldErr.Rule,
+			Message: FieldMessage(lang, fieldErr.Rule, fieldErr.Param),
+		}}, true
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return []FieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: FieldMessage(lang, "type", jsonType(typeErr.Type)),
+		}}, true
+	default:
+		return nil, false
+	}
+}
+
+// Recovery 处理 panic，返回 500 错误响应并把调用栈写入日志
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		Abort(c, Internal(fmt.Errorf("panic: %v\n%s", recovered, debug.Stack())))
+	})
+}
+
+// NoRoute 未匹配到路由时返回 404
+func NoRoute(c *gin.Context) {
+	Abort(c, New(http.StatusNotFound, CodeNotFound))
+}
+
+// jsonType 字段期望的 JSON 类型
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	default:
+		return t.Kind().String()
+	}
+}
+
+func validatorMessage(lang Lang, fe validator.FieldError) string {
+	rule := fe.Tag()
+	if rule == "min" || rule == "max" {
+		switch fe.Kind() {
+		case reflect.String:
+			rule += "_len"
+		case reflect.Slice, reflect.Array, reflect.Map:
+			rule += "_items"
+		}
+	}
+	return FieldMessage(lang, rule, fe.Param())
+}
+
+// FieldMessage 返回字段校验规则对应的提示，param 为规则的参数，如 min=1 中的 1
+func FieldMessage(lang Lang, rule, param string) string {
+	tmpl, ok := fieldMessages[rule]
+	if !ok {
+		return fmt.Sprintf(translate(lang, fieldMessages["default"]), rule)
+	}
+	msg := translate(lang, tmpl)
+	if param != "" && strings.Contains(msg, "%s") {
+		return fmt.Sprintf(msg, param)
+	}
+	return msg
+}
+
+// Message 返回错误码对应的提示，未知错误码返回错误码本身
+func Message(lang Lang, code Code, args ...any) string {
+	tmpl, ok := messages[code]
+	if !ok {
+		return string(code)
+	}
+	msg := translate(lang, tmpl)
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// Codes 按字母顺序返回全部错误码
+func Codes() []Code {
+	codes := make([]Code, 0, len(messages))
+	for code := range messages {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	return codes
+}
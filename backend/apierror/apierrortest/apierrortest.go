@@ -0,0 +1,38 @@
+// Package apierrortest handler 测试中检查响应状态码和错误码的辅助函数，供 api、admin 的测试共用
+package apierrortest
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"tuna/apierror"
+)
+
+// Decode 把响应体解码为 T，失败时终止测试
+func Decode[T any](t testing.TB, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decode %s: %v", w.Body.String(), err)
+	}
+	return v
+}
+
+// ExpectStatus 状态码不是 want 时终止测试
+func ExpectStatus(t testing.TB, w *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if w.Code != want {
+		t.Fatalf("status %d, want %d: %s", w.Code, want, w.Body.String())
+	}
+}
+
+// ExpectCode 检查状态码和错误码，返回解码后的错误响应
+func ExpectCode(t testing.TB, w *httptest.ResponseRecorder, status int, code apierror.Code) apierror.Response {
+	t.Helper()
+	ExpectStatus(t, w, status)
+	resp := Decode[apierror.Response](t, w)
+	if resp.Code != code {
+		t.Fatalf("code %q, want %q", resp.Code, code)
+	}
+	return resp
+}
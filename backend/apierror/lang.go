@@ -0,0 +1,70 @@
+package apierror
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Lang 提示信息的语言
+type Lang string
+
+const (
+	LangZH Lang = "zh-CN"
+	LangEN Lang = "en"
+
+	// DefaultLang 请求没有带 Accept-Language 或其中没有支持的语言时使用
+	DefaultLang = LangEN
+)
+
+// Language 按请求的 Accept-Language 选择语言
+func Language(c *gin.Context) Lang {
+	return ParseAcceptLanguage(c.GetHeader("Accept-Language"))
+}
+
+// ParseAcceptLanguage 按 q 值从高到低找到第一个支持的语言，zh 开头的都视为 zh-CN
+func ParseAcceptLanguage(header string) Lang {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if tag != "" && q > 0 {
+			candidates = append(candidates, candidate{strings.ToLower(tag), q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		switch {
+		case c.tag == "zh" || strings.HasPrefix(c.tag, "zh-"):
+			return LangZH
+		case c.tag == "en" || strings.HasPrefix(c.tag, "en-"):
+			return LangEN
+		}
+	}
+	return DefaultLang
+}
+
+// translation 同一条提示的各语言版本
+type translation struct {
+	zh string
+	en string
+}
+
+func translate(lang Lang, t translation) string {
+	if lang == LangZH {
+		return t.zh
+	}
+	return t.en
+}
@@ -0,0 +1,61 @@
+package apierror
+
+// messages 错误码对应的提示，%v 等占位符由 Error.Args 填充
+var messages = map[Code]translation{
+	CodeInvalidRequest:   {"请求格式不正确", "The request is malformed"},
+	CodeValidationFailed: {"请求参数校验失败", "Some fields are invalid"},
+	CodeNotFound:         {"请求的资源不存在", "The requested resource was not found"},
+	CodeInternal:         {"服务器内部错误，请稍后重试", "Internal server error, please try again later"},
+
+	CodeUnauthorized:       {"请先登录", "Missing authorization token"},
+	CodeInvalidToken:       {"登录已失效，请重新登录", "Invalid or expired token"},
+	CodeInvalidCredentials: {"用户名或密码错误", "Invalid username or password"},
+
+	CodeUserNotFound:            {"用户不存在", "User not found"},
+	CodeSubmissionNotFound:      {"未找到该提交记录", "Submission not found"},
+	CodeDuplicateSubmission:     {"已存在相同邮箱或手机号的待审核记录", "A pending submission with the same email or phone already exists"},
+	CodeInvalidStatusTransition: {"不能从 %s 状态变更为 %s", "Status transition from %s to %s is not allowed"},
+	CodeRejectReasonRequired:    {"拒绝时必须填写原因", "A reason is required when rejecting"},
+	CodeRolledBack:              {"其他记录处理失败，本条已回滚", "Rolled back because another item failed"},
+
+	CodeInvalidIdempotencyKey:        {"Idempotency-Key 过长", "Idempotency-Key is too long"},
+	CodeIdempotencyKeyMismatch:       {"Idempotency-Key 已用于另一个请求", "Idempotency-Key was already used with a different request"},
+	CodeIdempotencyRequestInProgress: {"相同 Idempotency-Key 的请求仍在处理中", "A request with the same Idempotency-Key is still in progress"},
+	CodeRateLimited:                  {"请求过于频繁，请稍后再试", "Too many requests, please try again later"},
+	CodeFormSubmittedTooFast:         {"表单提交过快，请稍后重试", "The form was submitted too quickly, please try again"},
+	CodeRequestTooLarge:              {"请求体不能超过 %dKB", "Request body must not be larger than %dKB"},
+
+	CodeFileRequired:         {"请上传文件", "File is required"},
+	CodeFileTooLarge:         {"文件不能超过 %dMB", "File must not be larger than %dMB"},
+	CodeFileEmpty:            {"文件内容为空", "File is empty"},
+	CodeInvalidFile:          {"文件无法解析，仅支持 .csv 和 .xlsx", "File cannot be parsed, only .csv and .xlsx are supported"},
+	CodeTooManyRows:          {"文件不能超过 %d 行", "File must not have more than %d rows"},
+	CodeMissingColumns:       {"缺少必需的列: %s", "Missing required columns: %s"},
+	CodeMergeRequiresPending: {"合并重复记录只能导入为待审核状态", "duplicate=merge requires status=pending"},
+}
+
+// fieldMessages 字段校验规则对应的提示，%s 为规则的参数。
+// min/max 按字段类型区分为 _len（字符串长度）和 _items（列表项数）
+var fieldMessages = map[string]translation{
+	"required":    {"不能为空", "is required"},
+	"required_if": {"不能为空", "is required"},
+	"email":       {"不是有效的邮箱地址", "must be a valid email address"},
+	"phone":       {"不是有效的手机号码", "must be a valid phone number"},
+	"min":         {"不能小于 %s", "must be at least %s"},
+	"max":         {"不能大于 %s", "must be at most %s"},
+	"min_len":     {"长度不能少于 %s 个字符", "must be at least %s characters long"},
+	"max_len":     {"长度不能超过 %s 个字符", "must be at most %s characters long"},
+	"min_items":   {"至少需要 %s 项", "must contain at least %s items"},
+	"max_items":   {"不能超过 %s 项", "must contain at most %s items"},
+	"oneof":       {"必须是以下值之一: %s", "must be one of: %s"},
+	"type":        {"类型不正确，应为 %s", "must be of type %s"},
+	"integer":     {"必须是整数", "must be an integer"},
+	"datetime":    {"时间格式不正确，支持 RFC3339 或 2006-01-02", "must be an RFC3339 time or a 2006-01-02 date"},
+	"ltefield":    {"不能大于 %s", "must not be greater than %s"},
+	"ltfield":     {"必须早于 %s", "must be before %s"},
+
+	"export_column": {"不支持的导出列: %s", "unknown export column: %s"},
+	"duplicate_row": {"与第 %s 行重复", "duplicates row %s"},
+
+	"default": {"不符合 %s 规则", "failed on the '%s' rule"},
+}
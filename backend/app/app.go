@@ -0,0 +1,205 @@
+// Package app api 和 admin 共用的启动流程：加载依赖、构建服务、监听信号并优雅退出。
+// api、admin 可以分别运行，也可以在同一进程中共用一个数据库连接池运行
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"tuna/config"
+	"tuna/database"
+	"tuna/eventbus"
+	"tuna/health"
+	"tuna/logging"
+	"tuna/metrics"
+	"tuna/migrate"
+	"tuna/models"
+	"tuna/notify"
+	"tuna/outbox"
+	"tuna/validation"
+	"tuna/webhook"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// App 进程内各服务共用的依赖
+type App struct {
+	Config   *config.Config
+	DB       *sql.DB
+	Migrator *migrate.Migrator
+	Health   *health.Checker
+	Users    models.UserRepository
+	Outbox   models.OutboxRepository
+	// Events 发布事件的消息队列，未开启时为 nil
+	Events eventbus.Broker
+
+	reloaders []func(cfg *config.Config)
+	// workers 随服务一起运行的后台任务，ctx 在服务关闭后取消
+	workers []func(ctx context.Context)
+}
+
+// New 初始化日志和数据库，检查数据库结构并注册指标。返回错误时已打开的连接会被关闭
+func New(cfg *config.Config) (*App, error) {
+	// Set gin to release mode to avoid debug output issues with goc wrapper
+	gin.SetMode(gin.ReleaseMode)
+
+	if err := logging.Setup(logging.Options{
+		Level:       cfg.LogLevel,
+		Output:      cfg.LogOutput,
+		AccessLevel: cfg.LogAccessLevel,
+	}); err != nil {
+		return nil, fmt.Errorf("invalid logging config: %w", err)
+	}
+	if err := validation.SetDefaultRegion(cfg.PhoneDefaultRegion); err != nil {
+		return nil, fmt.Errorf("invalid phone default region: %w", err)
+	}
+
+	if err := database.InitDB(cfg); err != nil {
+		return nil, fmt.Errorf("failed to initialize database: %w", err)
+	}
+	a := &App{
+		Config: cfg,
+		DB:     database.DB,
+		Users:  models.NewMySQLUserRepository(database.DB),
+		Outbox: models.NewMySQLOutboxRepository(database.DB),
+	}
+
+	migrator, err := migrate.New(a.DB)
+	if err != nil {
+		a.Close()
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	a.Migrator = migrator
+	if cfg.RequireSchemaCurrent {
+		if err := a.checkSchema(); err != nil {
+			a.Close()
+			return nil, fmt.Errorf("database schema check failed, run `tuna migrate up` first: %w", err)
+		}
+	}
+	a.Health = health.New(health.Options{
+		DB:                   a.DB,
+		Migrator:             migrator,
+		RequireSchemaCurrent: cfg.RequireSchemaCurrent,
+	})
+
+	relay, err := a.newRelay()
+	if err != nil {
+		a.Close()
+		return nil, err
+	}
+	a.workers = append(a.workers, relay.Run)
+
+	// 指标注册在默认注册表中，同一进程只能注册一次，所以放在这里而不是各服务中
+	if cfg.MetricsEnabled {
+		if err := a.registerMetrics(); err != nil {
+			a.Close()
+			return nil, fmt.Errorf("failed to register metrics: %w", err)
+		}
+	}
+	return a, nil
+}
+
+// Reload 重新读取配置，应用日志级别和提交接口的防刷配置。新配置不合法时保持原配置，
+// 其他字段的变化需要重启才能生效
+func (a *App) Reload() error {
+	next, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	if err := logging.SetLevel(next.LogLevel); err != nil {
+		return err
+	}
+	if err := logging.SetAccessLevel(next.LogAccessLevel); err != nil {
+		return err
+	}
+	restartRequired := a.Config.ApplyReloadable(next)
+	for _, fn := range a.reloaders {
+		fn(a.Config)
+	}
+
+	entry := logrus.WithFields(logrus.Fields{
+		"log_level":          a.Config.LogLevel,
+		"access_level":       a.Config.LogAccessLevel,
+		"rate_limit_ip":      a.Config.RateLimitPerIP.String(),
+		"rate_limit_contact": a.Config.RateLimitPerContact.String(),
+		"min_fill_time":      a.Config.MinFillTime.String(),
+	})
+	entry.Info("Config reloaded")
+	if restartRequired {
+		entry.Warn("Config has changes that only take effect after a restart")
+	}
+	return nil
+}
+
+// onReload 注册 Reload 成功后的回调，fn 收到的是已经应用了新值的配置
+func (a *App) onReload(fn func(cfg *config.Config)) {
+	a.reloaders = append(a.reloaders, fn)
+}
+
+// newRelay 投递 outbox 事件的 relay，每个服务进程各运行一个，由投递记录的租约保证不重复投递
+func (a *App) newRelay() (*outbox.Relay, error) {
+	cfg := a.Config
+	// 超时由 outbox 按单次投递控制
+	client := &http.Client{}
+	sinks := make([]outbox.Sink, 0, len(cfg.Webhooks))
+	for _, sub := range cfg.Webhooks {
+		sinks = append(sinks, webhook.NewSink(sub, client))
+	}
+	if cfg.EmailEnabled {
+		templates, err := notify.LoadTemplates(cfg.EmailTemplateDir, cfg.EmailLanguages)
+		if err != nil {
+			return nil, err
+		}
+		// 修改模板文件后发送 SIGHUP 即可生效
+		a.onReload(func(*config.Config) {
+			if err := templates.Reload(); err != nil {
+				logrus.WithError(err).Error("Failed to reload email templates, keeping the current ones")
+			}
+		})
+		sinks = append(sinks, notify.NewEmailSink(notify.NewSMTPSender(cfg.SMTP), templates, cfg.EmailFrom))
+	}
+	if cfg.RabbitMQEnabled {
+		a.Events = eventbus.NewRabbitMQ(cfg.RabbitMQURL, cfg.RabbitMQExchange)
+		sinks = append(sinks, eventbus.NewSink(a.Events, cfg.RabbitMQEvents))
+	}
+	return outbox.NewRelay(outbox.Options{
+		Repo:         a.Outbox,
+		Sinks:        sinks,
+		PollInterval: cfg.OutboxPollInterval,
+		BatchSize:    cfg.OutboxBatchSize,
+		MaxAttempts:  cfg.OutboxMaxAttempts,
+		BaseBackoff:  cfg.OutboxBaseBackoff,
+		MaxBackoff:   cfg.OutboxMaxBackoff,
+		Timeout:      cfg.OutboxTimeout,
+	})
+}
+
+// Close 关闭消息队列和数据库连接
+func (a *App) Close() error {
+	var errs []error
+	if a.Events != nil {
+		errs = append(errs, a.Events.Close())
+	}
+	errs = append(errs, database.CloseDB())
+	return errors.Join(errs...)
+}
+
+// registerMetrics 注册连接池和待审核数量指标
+func (a *App) registerMetrics() error {
+	if err := metrics.RegisterDB(a.DB, a.Config.DBName); err != nil {
+		return err
+	}
+	return metrics.RegisterPendingBacklog(func(ctx context.Context) (int64, error) {
+		return a.Users.CountUsers(ctx, models.UserFilter{Status: models.StatusPending})
+	})
+}
+
+func (a *App) checkSchema() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return a.Migrator.Check(ctx)
+}
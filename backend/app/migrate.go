@@ -0,0 +1,126 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"text/tabwriter"
+	"time"
+	"tuna/config"
+	"tuna/database"
+	"tuna/migrate"
+)
+
+// MigrateUsage migrate 子命令的用法
+const MigrateUsage = `命令:
+  up              执行全部未执行的迁移
+  down [n]        回滚最近的 n 个迁移（默认 1）
+  to <version>    迁移到指定版本，0 表示回滚全部
+  status          查看每个迁移的执行情况
+`
+
+// ErrUsage 命令行参数不正确，调用方应输出用法并以状态码 2 退出
+var ErrUsage = errors.New("invalid usage")
+
+// Migrate 执行数据库迁移命令，args 为 migrate 之后的参数，status 的结果写入 out
+func Migrate(cfg *config.Config, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing migrate command", ErrUsage)
+	}
+
+	// 先校验参数，避免参数错误时也去连接数据库
+	var run func(ctx context.Context, m *migrate.Migrator) ([]migrate.Migration, error)
+	switch cmd := args[0]; cmd {
+	case "up":
+		run = func(ctx context.Context, m *migrate.Migrator) ([]migrate.Migration, error) {
+			return m.Up(ctx)
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("%w: invalid number of steps: %s", ErrUsage, args[1])
+			}
+			steps = n
+		}
+		run = func(ctx context.Context, m *migrate.Migrator) ([]migrate.Migration, error) {
+			return m.Down(ctx, steps)
+		}
+	case "to":
+		if len(args) < 2 {
+			return fmt.Errorf("%w: missing target version", ErrUsage)
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("%w: invalid version: %s", ErrUsage, args[1])
+		}
+		run = func(ctx context.Context, m *migrate.Migrator) ([]migrate.Migration, error) {
+			return m.To(ctx, version)
+		}
+	case "status":
+	default:
+		return fmt.Errorf("%w: unknown migrate command: %s", ErrUsage, cmd)
+	}
+
+	if err := database.InitDB(cfg); err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer database.CloseDB()
+
+	m, err := migrate.New(database.DB)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	ctx := context.Background()
+	if run == nil {
+		return printMigrateStatus(ctx, m, out)
+	}
+
+	applied, err := run(ctx, m)
+	for _, mig := range applied {
+		log.Printf("Migrated %04d_%s", mig.Version, mig.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+	if len(applied) == 0 {
+		log.Println("No migrations to run")
+	}
+	return nil
+}
+
+func printMigrateStatus(ctx context.Context, m *migrate.Migrator, out io.Writer) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read migration status: %w", err)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range statuses {
+		state := "pending"
+		appliedAt := ""
+		if s.Applied {
+			state = "applied"
+			appliedAt = s.AppliedAt.Format(time.DateTime)
+		}
+		if s.Dirty {
+			state = "dirty"
+		}
+		if s.ChecksumMismatch {
+			state += " (checksum mismatch)"
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+	w.Flush()
+
+	if err := m.Check(ctx); err != nil {
+		fmt.Fprintf(out, "\n%v\n", err)
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+	"tuna/admin"
+	"tuna/api"
+	"tuna/config"
+	"tuna/metrics"
+	"tuna/models"
+)
+
+// shutdownTimeout 关闭监听后等待进行中请求完成的最长时间
+const shutdownTimeout = 5 * time.Second
+
+// Service 一个对外监听的 HTTP 服务
+type Service struct {
+	// Name 用于日志，如 API、Admin
+	Name   string
+	Server *http.Server
+	// MetricsPort 单独的指标监听端口，为空时不单独监听
+	MetricsPort string
+}
+
+// APIService 构建用户端服务
+func (a *App) APIService() (*Service, error) {
+	cfg := a.Config
+	limiter := api.NewRateLimiter(rateLimitOptions(cfg))
+	a.onReload(func(cfg *config.Config) {
+		limiter.Update(rateLimitOptions(cfg))
+	})
+
+	idempotency := models.NewMySQLIdempotencyRepository(a.DB)
+	a.workers = append(a.workers, func(ctx context.Context) {
+		api.PurgeIdempotencyKeys(ctx, idempotency, api.DefaultIdempotencyPurgeInterval)
+	})
+
+	metricsOpts := metrics.Options{Enabled: cfg.MetricsEnabled, Port: cfg.MetricsAPIPort}
+	router := api.SetupRouter(api.Options{
+		Users:           a.Users,
+		Idempotency:     idempotency,
+		IdempotencyTTL:  cfg.IdempotencyTTL,
+		DuplicatePolicy: cfg.DuplicatePolicy,
+		RateLimit:       limiter,
+		TrustedProxies:  cfg.TrustedProxies,
+		CORS:            cfg.APICORS,
+		Health:          a.Health,
+		Metrics:         metricsOpts,
+	})
+	return &Service{
+		Name:        "API",
+		Server:      &http.Server{Addr: ":" + cfg.APIPort, Handler: router},
+		MetricsPort: metricsPort(metricsOpts),
+	}, nil
+}
+
+// AdminService 构建管理端服务，需要时创建初始管理员账号
+func (a *App) AdminService(ctx context.Context) (*Service, error) {
+	cfg := a.Config
+	for _, column := range cfg.ExportColumns {
+		if !admin.IsExportColumn(column) {
+			return nil, fmt.Errorf("invalid export column: %s", column)
+		}
+	}
+
+	admins := models.NewMySQLAdminRepository(a.DB)
+	created, err := admin.BootstrapAccount(ctx, admins, cfg.AdminBootstrapUser, cfg.AdminBootstrapPassword)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bootstrap admin account: %w", err)
+	}
+	if created {
+		log.Printf("Admin account %q created", cfg.AdminBootstrapUser)
+	}
+
+	metricsOpts := metrics.Options{Enabled: cfg.MetricsEnabled, Port: cfg.MetricsAdminPort}
+	router := admin.SetupRouter(admin.Options{
+		Users:         a.Users,
+		Admins:        admins,
+		SessionTTL:    cfg.AdminSessionTTL,
+		ExportColumns: cfg.ExportColumns,
+		CORS:          cfg.AdminCORS,
+		Health:        a.Health,
+		Metrics:       metricsOpts,
+	})
+	return &Service{
+		Name:        "Admin",
+		Server:      &http.Server{Addr: ":" + cfg.AdminPort, Handler: router},
+		MetricsPort: metricsPort(metricsOpts),
+	}, nil
+}
+
+func rateLimitOptions(cfg *config.Config) api.RateLimitOptions {
+	return api.RateLimitOptions{
+		PerIP:         cfg.RateLimitPerIP,
+		PerContact:    cfg.RateLimitPerContact,
+		HoneypotField: cfg.HoneypotField,
+		MinFillTime:   cfg.MinFillTime,
+	}
+}
+
+func metricsPort(opts metrics.Options) string {
+	if !opts.Enabled {
+		return ""
+	}
+	return opts.Port
+}
+
+// Run 启动各服务和后台任务并阻塞到收到 SIGINT/SIGTERM 或任一服务监听失败，然后一起优雅退出。
+// 期间收到 SIGHUP 时调用 Reload。退出时先让 /readyz 返回 503 并等待 ShutdownDrainDelay，再关闭全部监听，
+// 等待进行中的请求完成，最后停止后台任务
+func (a *App) Run(services ...*Service) error {
+	servers := make([]*http.Server, 0, len(services))
+	names := make([]string, 0, len(services))
+	for _, s := range services {
+		servers = append(servers, s.Server)
+		names = append(names, s.Name)
+	}
+	// 多个服务配置了同一个指标端口时只监听一次
+	metricsPorts := map[string]bool{}
+	for _, s := range services {
+		if s.MetricsPort == "" || metricsPorts[s.MetricsPort] {
+			continue
+		}
+		metricsPorts[s.MetricsPort] = true
+		servers = append(servers, metrics.NewServer(s.MetricsPort))
+		names = append(names, s.Name+" metrics")
+	}
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers sync.WaitGroup
+	for _, fn := range a.workers {
+		workers.Add(1)
+		go func(fn func(ctx context.Context)) {
+			defer workers.Done()
+			fn(workerCtx)
+		}(fn)
+	}
+
+	errCh := make(chan error, len(servers))
+	for i, srv := range servers {
+		name := names[i]
+		log.Printf("%s server starting on %s", name, srv.Addr)
+		go func(srv *http.Server) {
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errCh <- fmt.Errorf("%s server failed: %w", name, err)
+			}
+		}(srv)
+	}
+
+	// Wait for interrupt signal to gracefully shutdown the server，SIGHUP 重新加载配置
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(quit)
+
+	var runErr error
+wait:
+	for {
+		select {
+		case sig := <-quit:
+			if sig == syscall.SIGHUP {
+				if err := a.Reload(); err != nil {
+					log.Printf("Failed to reload config, keeping the current one: %v", err)
+				}
+				continue
+			}
+			log.Printf("Received %s, shutting down...", sig)
+			// 先让 /readyz 返回 503，等负载均衡摘掉实例后再关闭监听
+			a.Health.StartDraining()
+			if a.Config.ShutdownDrainDelay > 0 {
+				time.Sleep(a.Config.ShutdownDrainDelay)
+			}
+			break wait
+		case runErr = <-errCh:
+			log.Printf("%v, shutting down...", runErr)
+			a.Health.StartDraining()
+			break wait
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// 各服务同时关闭，共用同一个超时时间
+	errs := make([]error, len(servers)+1)
+	errs[0] = runErr
+	var wg sync.WaitGroup
+	for i, srv := range servers {
+		wg.Add(1)
+		go func(i int, srv *http.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				errs[i+1] = fmt.Errorf("%s server forced to shutdown: %w", names[i], err)
+			}
+		}(i, srv)
+	}
+	wg.Wait()
+	// 请求都处理完后再停止，关闭期间写入的事件会在下次启动时投递
+	stopWorkers()
+	workers.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	log.Println("Servers exited")
+	return nil
+}
+
+// 可以通过 Serve 启动的服务
+const (
+	ServiceAPI   = "api"
+	ServiceAdmin = "admin"
+)
+
+// Serve 初始化依赖，启动指定的服务直到收到退出信号。同时启动多个服务时共用数据库连接池和就绪状态
+func Serve(cfg *config.Config, names ...string) error {
+	a, err := New(cfg)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	services := make([]*Service, 0, len(names))
+	for _, name := range names {
+		var s *Service
+		switch name {
+		case ServiceAPI:
+			s, err = a.APIService()
+		case ServiceAdmin:
+			s, err = a.AdminService(context.Background())
+		default:
+			err = fmt.Errorf("unknown service: %s", name)
+		}
+		if err != nil {
+			return err
+		}
+		services = append(services, s)
+	}
+	return a.Run(services...)
+}
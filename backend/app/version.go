@@ -0,0 +1,35 @@
+package app
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Version 发布版本号，构建时通过 -ldflags "-X tuna/app.Version=v1.2.3" 写入
+var Version = "dev"
+
+// VersionString 版本号、提交和 Go 版本，提交信息取自构建时嵌入的 VCS 信息
+func VersionString() string {
+	commit, built, dirty := "unknown", "", false
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				commit = s.Value
+			case "vcs.time":
+				built = s.Value
+			case "vcs.modified":
+				dirty = s.Value == "true"
+			}
+		}
+	}
+	if dirty {
+		commit += "-dirty"
+	}
+	v := fmt.Sprintf("tuna %s (commit %s", Version, commit)
+	if built != "" {
+		v += ", " + built
+	}
+	return v + ", " + runtime.Version() + ")"
+}
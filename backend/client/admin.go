@@ -0,0 +1,180 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"tuna/apierror"
+	"tuna/models"
+)
+
+// Login 管理员登录，成功后把 token 保存在客户端中，之后的管理端请求自动带上
+func (c *Client) Login(ctx context.Context, username, password string) (*models.LoginResponse, error) {
+	var resp models.LoginResponse
+	err := c.call(ctx, request{
+		service: ServiceAdmin,
+		method:  http.MethodPost,
+		path:    "/admin/login",
+		body:    models.LoginRequest{Username: username, Password: password},
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	c.SetToken(resp.Token)
+	return &resp, nil
+}
+
+// Logout 使当前 token 失效并清空客户端中的 token
+func (c *Client) Logout(ctx context.Context) error {
+	err := c.call(ctx, request{service: ServiceAdmin, method: http.MethodPost, path: "/admin/logout", auth: true}, nil)
+	if err != nil {
+		return err
+	}
+	c.SetToken("")
+	return nil
+}
+
+// Me 当前登录的管理员
+func (c *Client) Me(ctx context.Context) (*models.AdminAccount, error) {
+	var resp models.MeResponse
+	err := c.call(ctx, request{service: ServiceAdmin, method: http.MethodGet, path: "/admin/me", auth: true, retry: true}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp.Account, nil
+}
+
+// ListUsers 按筛选条件查询一页用户，Page、PageSize 为 0 时由服务端使用默认值
+func (c *Client) ListUsers(ctx context.Context, req models.ListUsersRequest) (*models.ListUsersResponse, error) {
+	var resp models.ListUsersResponse
+	err := c.call(ctx, request{
+		service: ServiceAdmin,
+		method:  http.MethodGet,
+		path:    "/admin/users",
+		query:   queryValues(req),
+		auth:    true,
+		retry:   true,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// EachUser 从 req.Page（为 0 时从第 1 页）开始逐页查询，对每条记录调用 fn，fn 返回错误时停止。
+// 翻页期间有记录新增或状态变化时可能漏掉或重复记录，需要完整快照时使用 ExportUsers
+func (c *Client) EachUser(ctx context.Context, req models.ListUsersRequest, fn func(models.UserInfo) error) error {
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	for {
+		page, err := c.ListUsers(ctx, req)
+		if err != nil {
+			return err
+		}
+		for _, user := range page.Users {
+			if err := fn(user); err != nil {
+				return err
+			}
+		}
+		if page.Page >= page.TotalPages || len(page.Users) == 0 {
+			return nil
+		}
+		req.Page = page.Page + 1
+	}
+}
+
+// ExportUsers 导出全部匹配的记录，返回文件内容，调用方负责关闭
+func (c *Client) ExportUsers(ctx context.Context, req models.ExportUsersRequest) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, request{
+		service: ServiceAdmin,
+		method:  http.MethodGet,
+		path:    "/admin/users/export",
+		query:   queryValues(req),
+		auth:    true,
+		retry:   true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp.Body, nil
+}
+
+// UpdateStatus 审核通过或拒绝。不自动重试：首次请求已生效时重试会返回 ErrInvalidStatusTransition，
+// 调用方可以据此判断状态已经变更
+func (c *Client) UpdateStatus(ctx context.Context, id int64, req models.UpdateStatusRequest) error {
+	return c.call(ctx, request{
+		service: ServiceAdmin,
+		method:  http.MethodPut,
+		path:    fmt.Sprintf("/admin/users/%d/status", id),
+		body:    req,
+		auth:    true,
+	}, nil)
+}
+
+// BulkUpdateStatus 批量审核。all_or_nothing 模式下有记录失败时全部不生效，
+// 此时同时返回每个 id 的结果和 Code 为 rolled_back 的 *Error
+func (c *Client) BulkUpdateStatus(ctx context.Context, req models.BulkUpdateStatusRequest) (*models.BulkUpdateStatusResponse, error) {
+	resp, err := c.send(ctx, request{
+		service: ServiceAdmin,
+		method:  http.MethodPut,
+		path:    "/admin/users/status",
+		body:    req,
+		auth:    true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// 409 的响应体是批量结果而不是错误格式
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusConflict {
+		return nil, responseError(resp)
+	}
+
+	var result models.BulkUpdateStatusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("client: decode bulk status response: %w", err)
+	}
+	if resp.StatusCode == http.StatusConflict {
+		return &result, &Error{
+			StatusCode: resp.StatusCode,
+			Code:       apierror.CodeRolledBack,
+			Message:    fmt.Sprintf("%d of %d items failed, no change was applied", result.Failed, len(result.Results)),
+			RequestID:  resp.Header.Get("X-Request-ID"),
+		}
+	}
+	return &result, nil
+}
+
+// Reopen 把已审核的记录改回待审核，reason 可以为空
+func (c *Client) Reopen(ctx context.Context, id int64, reason string) error {
+	return c.call(ctx, request{
+		service: ServiceAdmin,
+		method:  http.MethodPost,
+		path:    fmt.Sprintf("/admin/users/%d/reopen", id),
+		body:    models.ReopenRequest{Reason: reason},
+		auth:    true,
+	}, nil)
+}
+
+// History 记录和它的审核历史，记录不存在时返回 ErrUserNotFound
+func (c *Client) History(ctx context.Context, id int64) (*models.UserHistoryResponse, error) {
+	var resp models.UserHistoryResponse
+	err := c.call(ctx, request{
+		service: ServiceAdmin,
+		method:  http.MethodGet,
+		path:    fmt.Sprintf("/admin/users/%d/history", id),
+		auth:    true,
+		retry:   true,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
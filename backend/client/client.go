@@ -0,0 +1,321 @@
+// Package client 用户端和管理端 API 的 Go 客户端，请求和响应直接使用 models 中的类型。
+//
+// 只有幂等的请求会自动重试：GET 请求，以及带 Idempotency-Key 的提交（SubmitUserInfo 每次调用生成一个 key，
+// 重试时沿用）。网络错误、429 和 5xx 响应会按退避重试，429 的 Retry-After 超过 MaxBackoff 时直接返回错误。
+// 接口返回的错误统一为 *Error，可以用 errors.Is 与 ErrUserNotFound 等按错误码比较
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"tuna/apierror"
+)
+
+const (
+	// DefaultAPIURL、DefaultAdminURL 未配置时用户端和管理端的地址
+	DefaultAPIURL   = "http://localhost:8812"
+	DefaultAdminURL = "http://localhost:8813"
+
+	// DefaultMaxRetries 未配置时幂等请求的最大重试次数
+	DefaultMaxRetries = 2
+	// DefaultBaseBackoff、DefaultMaxBackoff 未配置时第一次重试前的等待时间和等待时间的上限
+	DefaultBaseBackoff = 200 * time.Millisecond
+	DefaultMaxBackoff  = 5 * time.Second
+	// DefaultTimeout 未配置 HTTPClient 时单次请求的超时时间
+	DefaultTimeout = 30 * time.Second
+
+	// maxErrorBody 读取错误响应体的上限
+	maxErrorBody = 1 << 20
+)
+
+// Options 客户端配置，零值可用
+type Options struct {
+	// APIURL 用户端地址，如 http://localhost:8812，为空时使用 DefaultAPIURL
+	APIURL string
+	// AdminURL 管理端地址，为空时使用 DefaultAdminURL
+	AdminURL string
+	// Token 管理端的登录 token，也可以调用 Login 获取或用 SetToken 替换
+	Token string
+	// Language 请求头 Accept-Language，决定错误提示的语言，为空时由服务端决定（英文）
+	Language apierror.Lang
+	// HTTPClient 为 nil 时使用超时为 DefaultTimeout 的 http.Client
+	HTTPClient *http.Client
+	// MaxRetries 幂等请求的最大重试次数，为 0 时使用 DefaultMaxRetries，小于 0 时不重试
+	MaxRetries int
+	// BaseBackoff、MaxBackoff 重试的等待时间，每次翻倍，为 0 时使用默认值
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+// Client 可以在多个 goroutine 中同时使用
+type Client struct {
+	apiURL   *url.URL
+	adminURL *url.URL
+	opts     Options
+	http     *http.Client
+	token    atomic.Pointer[string]
+}
+
+// New 检查地址并填充默认值
+func New(opts Options) (*Client, error) {
+	if opts.APIURL == "" {
+		opts.APIURL = DefaultAPIURL
+	}
+	if opts.AdminURL == "" {
+		opts.AdminURL = DefaultAdminURL
+	}
+	apiURL, err := baseURL(opts.APIURL)
+	if err != nil {
+		return nil, fmt.Errorf("client: api url: %w", err)
+	}
+	adminURL, err := baseURL(opts.AdminURL)
+	if err != nil {
+		return nil, fmt.Errorf("client: admin url: %w", err)
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = DefaultMaxRetries
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = DefaultBaseBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: DefaultTimeout}
+	}
+
+	c := &Client{apiURL: apiURL, adminURL: adminURL, opts: opts, http: httpClient}
+	c.SetToken(opts.Token)
+	return c, nil
+}
+
+func baseURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimRight(raw, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an http(s) URL", raw)
+	}
+	return u, nil
+}
+
+// SetToken 替换管理端请求使用的 token，为空表示未登录
+func (c *Client) SetToken(token string) {
+	c.token.Store(&token)
+}
+
+// Token 当前的管理端 token
+func (c *Client) Token() string {
+	return *c.token.Load()
+}
+
+// Service 请求发往的服务
+type Service string
+
+const (
+	ServiceAPI   Service = "api"
+	ServiceAdmin Service = "admin"
+)
+
+// request 一次接口调用
+type request struct {
+	service Service
+	method  string
+	path    string
+	query   url.Values
+	header  http.Header
+	// body 不为 nil 时编码为 JSON 请求体
+	body any
+	// auth 为 true 时带上管理端 token
+	auth bool
+	// retry 请求是否幂等，可以重试
+	retry bool
+}
+
+// call 发出请求，2xx 时把响应体解码到 out（可以为 nil），否则返回 *Error
+func (c *Client) call(ctx context.Context, r request, out any) error {
+	resp, err := c.send(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return responseError(resp)
+	}
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("client: decode %s %s response: %w", r.method, r.path, err)
+	}
+	return nil
+}
+
+// send 发出请求并按需重试，返回最后一次的响应，由调用方关闭响应体
+func (c *Client) send(ctx context.Context, r request) (*http.Response, error) {
+	var body []byte
+	if r.body != nil {
+		var err error
+		if body, err = json.Marshal(r.body); err != nil {
+			return nil, err
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		req, err := c.newRequest(ctx, r, body)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.http.Do(req)
+		if !r.retry || attempt > c.opts.MaxRetries {
+			return resp, err
+		}
+		wait, ok := c.retryAfter(ctx, attempt, resp, err)
+		if !ok {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBody))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) newRequest(ctx context.Context, r request, body []byte) (*http.Request, error) {
+	base := c.apiURL
+	if r.service == ServiceAdmin {
+		base = c.adminURL
+	}
+	u := *base
+	u.Path += r.path
+	u.RawQuery = r.query.Encode()
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.opts.Language != "" {
+		req.Header.Set("Accept-Language", string(c.opts.Language))
+	}
+	if token := c.Token(); r.auth && token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// retryAfter 判断失败的请求是否需要重试，返回等待时间。
+// 网络错误、429、500、502、503、504 以及提交仍在处理中的 409 会重试，ctx 已取消时不重试
+func (c *Client) retryAfter(ctx context.Context, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if ctx.Err() != nil {
+		return 0, false
+	}
+	wait := backoff(attempt, c.opts.BaseBackoff, c.opts.MaxBackoff)
+	if err != nil {
+		return wait, true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			after := time.Duration(seconds) * time.Second
+			if after > c.opts.MaxBackoff {
+				return 0, false
+			}
+			wait = max(wait, after)
+		}
+		return wait, true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return wait, true
+	case http.StatusConflict:
+		// 需要读取响应体判断错误码，读取后放回，不重试时调用方仍能读到
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+		var body apierror.Response
+		if json.Unmarshal(data, &body) == nil && body.Code == apierror.CodeIdempotencyRequestInProgress {
+			return wait, true
+		}
+	}
+	return 0, false
+}
+
+// backoff 第 attempt 次失败后的等待时间：base 每次翻倍，不超过 max，并随机减少至多一半。
+// 与 outbox.Backoff 相同，复制一份避免客户端依赖服务端的包
+func backoff(attempt int, base, max time.Duration) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d/2 + time.Duration(mathrand.Int63n(int64(d/2)+1))
+}
+
+// newIdempotencyKey 随机生成的 Idempotency-Key
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// queryValues 按 form 标签把请求参数编码为查询字符串，匿名嵌入的结构体展开，零值字段省略
+func queryValues(v any) url.Values {
+	values := url.Values{}
+	var walk func(rv reflect.Value)
+	walk = func(rv reflect.Value) {
+		rt := rv.Type()
+		for i := 0; i < rt.NumField(); i++ {
+			f, fv := rt.Field(i), rv.Field(i)
+			name := f.Tag.Get("form")
+			if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+				walk(fv)
+				continue
+			}
+			if name == "" || name == "-" || !f.IsExported() || fv.IsZero() {
+				continue
+			}
+			values.Set(name, fmt.Sprint(fv.Interface()))
+		}
+	}
+	walk(reflect.Indirect(reflect.ValueOf(v)))
+	return values
+}
@@ -0,0 +1,307 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+	"tuna/apierror"
+	"tuna/models"
+)
+
+// fakeServer 按顺序返回预设的响应，并记录收到的请求
+type fakeServer struct {
+	t         *testing.T
+	mu        sync.Mutex
+	responses []fakeResponse
+	requests  []*http.Request
+}
+
+type fakeResponse struct {
+	status int
+	header http.Header
+	body   any
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r)
+	if len(s.responses) == 0 {
+		s.t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusTeapot)
+		return
+	}
+	resp := s.responses[0]
+	s.responses = s.responses[1:]
+	for k, v := range resp.header {
+		w.Header()[k] = v
+	}
+	if s, ok := resp.body.(string); ok {
+		w.WriteHeader(resp.status)
+		w.Write([]byte(s))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(resp.status)
+	json.NewEncoder(w).Encode(resp.body)
+}
+
+func (s *fakeServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+func newTestClient(t *testing.T, responses ...fakeResponse) (*Client, *fakeServer) {
+	t.Helper()
+	fake := &fakeServer{t: t, responses: responses}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	c, err := New(Options{
+		APIURL:      server.URL,
+		AdminURL:    server.URL,
+		Token:       "test-token",
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, fake
+}
+
+func errorBody(code apierror.Code) apierror.Response {
+	return apierror.Response{Code: code, Error: string(code)}
+}
+
+func TestSubmitRetriesWithTheSameIdempotencyKey(t *testing.T) {
+	c, fake := newTestClient(t,
+		fakeResponse{status: http.StatusServiceUnavailable, body: errorBody(apierror.CodeInternal)},
+		fakeResponse{status: http.StatusConflict, body: errorBody(apierror.CodeIdempotencyRequestInProgress)},
+		fakeResponse{status: http.StatusOK, body: models.SubmitResponse{ID: 7, TrackingToken: "token"}},
+	)
+
+	resp, err := c.SubmitUserInfo(context.Background(), models.CreateUserRequest{Name: "张三"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != 7 || resp.TrackingToken != "token" {
+		t.Errorf("response = %+v", resp)
+	}
+	if fake.count() != 3 {
+		t.Fatalf("%d requests, want 3", fake.count())
+	}
+	key := fake.requests[0].Header.Get("Idempotency-Key")
+	if key == "" {
+		t.Fatal("request has no Idempotency-Key")
+	}
+	for _, r := range fake.requests[1:] {
+		if got := r.Header.Get("Idempotency-Key"); got != key {
+			t.Errorf("retry used Idempotency-Key %q, want %q", got, key)
+		}
+	}
+}
+
+func TestSubmitGivesUpAfterMaxRetries(t *testing.T) {
+	c, fake := newTestClient(t,
+		fakeResponse{status: http.StatusBadGateway, body: "bad gateway"},
+		fakeResponse{status: http.StatusBadGateway, body: "bad gateway"},
+		fakeResponse{status: http.StatusBadGateway, body: "bad gateway"},
+	)
+
+	_, err := c.SubmitUserInfo(context.Background(), models.CreateUserRequest{})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadGateway || apiErr.Code != "" {
+		t.Fatalf("err = %v, want a 502 *Error without code", err)
+	}
+	if fake.count() != DefaultMaxRetries+1 {
+		t.Errorf("%d requests, want %d", fake.count(), DefaultMaxRetries+1)
+	}
+}
+
+func TestConflictIsNotRetried(t *testing.T) {
+	c, fake := newTestClient(t,
+		fakeResponse{status: http.StatusConflict, body: errorBody(apierror.CodeDuplicateSubmission)},
+	)
+
+	_, err := c.SubmitUserInfo(context.Background(), models.CreateUserRequest{})
+	if !errors.Is(err, ErrDuplicateSubmission) {
+		t.Fatalf("err = %v, want ErrDuplicateSubmission", err)
+	}
+	if errors.Is(err, ErrIdempotencyRequestInProgress) {
+		t.Error("err matches a different code")
+	}
+	if fake.count() != 1 {
+		t.Errorf("%d requests, want 1", fake.count())
+	}
+}
+
+func TestRetryAfterLongerThanMaxBackoff(t *testing.T) {
+	c, fake := newTestClient(t,
+		fakeResponse{status: http.StatusTooManyRequests, header: http.Header{"Retry-After": {"30"}}, body: errorBody(apierror.CodeRateLimited)},
+	)
+
+	_, err := c.SubmitUserInfo(context.Background(), models.CreateUserRequest{})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+	if apiErr.RetryAfter != 30*time.Second {
+		t.Errorf("RetryAfter = %v, want 30s", apiErr.RetryAfter)
+	}
+	if fake.count() != 1 {
+		t.Errorf("%d requests, want 1", fake.count())
+	}
+}
+
+func TestUpdateStatusIsNotRetried(t *testing.T) {
+	c, fake := newTestClient(t,
+		fakeResponse{status: http.StatusServiceUnavailable, body: errorBody(apierror.CodeInternal)},
+	)
+
+	err := c.UpdateStatus(context.Background(), 1, models.UpdateStatusRequest{Status: models.StatusApproved})
+	if !errors.Is(err, ErrInternal) {
+		t.Fatalf("err = %v, want ErrInternal", err)
+	}
+	if fake.count() != 1 {
+		t.Errorf("%d requests, want 1", fake.count())
+	}
+	if got := fake.requests[0].Header.Get("Authorization"); got != "Bearer test-token" {
+		t.Errorf("Authorization = %q", got)
+	}
+}
+
+func TestErrorDetails(t *testing.T) {
+	c, _ := newTestClient(t,
+		fakeResponse{
+			status: http.StatusConflict,
+			header: http.Header{"X-Request-Id": {"req-1"}},
+			body: apierror.Response{
+				Code:    apierror.CodeInvalidStatusTransition,
+				Error:   "Status transition from approved to rejected is not allowed",
+				Details: map[string]any{"from": "approved", "to": "rejected"},
+			},
+		},
+		fakeResponse{
+			status: http.StatusBadRequest,
+			body: apierror.Response{
+				Code:   apierror.CodeValidationFailed,
+				Fields: []apierror.FieldError{{Field: "reason", Rule: "required"}},
+			},
+		},
+	)
+
+	err := c.UpdateStatus(context.Background(), 1, models.UpdateStatusRequest{Status: models.StatusRejected})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *Error", err)
+	}
+	from, to, ok := apiErr.Transition()
+	if !ok || from != "approved" || to != "rejected" {
+		t.Errorf("Transition() = %q, %q, %v", from, to, ok)
+	}
+	if apiErr.RequestID != "req-1" {
+		t.Errorf("RequestID = %q", apiErr.RequestID)
+	}
+
+	err = c.UpdateStatus(context.Background(), 1, models.UpdateStatusRequest{Status: models.StatusRejected})
+	if !errors.As(err, &apiErr) || !errors.Is(err, ErrValidationFailed) {
+		t.Fatalf("err = %v, want ErrValidationFailed", err)
+	}
+	if len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "reason" {
+		t.Errorf("Fields = %+v", apiErr.Fields)
+	}
+	if _, _, ok := apiErr.Transition(); ok {
+		t.Error("Transition() ok for a validation error")
+	}
+}
+
+func TestBulkUpdateStatusRolledBack(t *testing.T) {
+	c, _ := newTestClient(t,
+		fakeResponse{status: http.StatusConflict, body: models.BulkUpdateStatusResponse{
+			Mode:      models.BulkModeAllOrNothing,
+			Succeeded: 0,
+			Failed:    1,
+			Results: []models.BulkStatusItemResult{
+				{ID: 1, Code: string(apierror.CodeRolledBack)},
+				{ID: 2, Code: string(apierror.CodeInvalidStatusTransition)},
+			},
+		}},
+	)
+
+	result, err := c.BulkUpdateStatus(context.Background(), models.BulkUpdateStatusRequest{IDs: []int64{1, 2}})
+	if !errors.Is(err, ErrRolledBack) {
+		t.Fatalf("err = %v, want ErrRolledBack", err)
+	}
+	if result == nil || len(result.Results) != 2 {
+		t.Fatalf("result = %+v, want both items", result)
+	}
+}
+
+func TestContextCanceledDuringBackoff(t *testing.T) {
+	fake := &fakeServer{t: t, responses: []fakeResponse{
+		{status: http.StatusServiceUnavailable, body: errorBody(apierror.CodeInternal)},
+	}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	c, err := New(Options{APIURL: server.URL, BaseBackoff: time.Hour, MaxBackoff: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.SubmissionStatus(ctx, "token"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if fake.count() != 1 {
+		t.Errorf("%d requests, want 1", fake.count())
+	}
+}
+
+func TestLoginStoresToken(t *testing.T) {
+	c, fake := newTestClient(t,
+		fakeResponse{status: http.StatusOK, body: models.LoginResponse{Token: "new-token"}},
+		fakeResponse{status: http.StatusOK, body: models.MeResponse{}},
+		fakeResponse{status: http.StatusOK, body: models.MessageResponse{Message: "ok"}},
+	)
+	ctx := context.Background()
+
+	if _, err := c.Login(ctx, "admin", "password"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Me(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.requests[1].Header.Get("Authorization"); got != "Bearer new-token" {
+		t.Errorf("Authorization = %q, want the token from Login", got)
+	}
+	if err := c.Logout(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if c.Token() != "" {
+		t.Errorf("token %q was kept after Logout", c.Token())
+	}
+}
+
+func TestNewRejectsInvalidURL(t *testing.T) {
+	if _, err := New(Options{APIURL: "localhost:8812"}); err == nil {
+		t.Error("New accepted a URL without scheme")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base, max := 100*time.Millisecond, time.Second
+	for attempt, want := range map[int]time.Duration{1: base, 2: 2 * base, 3: 4 * base, 10: max} {
+		for i := 0; i < 20; i++ {
+			if got := backoff(attempt, base, max); got < want/2 || got > want {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", attempt, got, want/2, want)
+			}
+		}
+	}
+}
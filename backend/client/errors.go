@@ -0,0 +1,108 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"tuna/apierror"
+)
+
+// Error 接口返回的错误响应
+type Error struct {
+	// StatusCode HTTP 状态码
+	StatusCode int
+	// Code 错误码，响应体不是错误格式时为空
+	Code apierror.Code
+	// Message 服务端按 Accept-Language 本地化后的提示
+	Message string
+	// Fields 参数校验失败的字段
+	Fields []apierror.FieldError
+	// Details 附加信息，如状态变更的 from/to
+	Details map[string]any
+	// RetryAfter 429 响应的 Retry-After
+	RetryAfter time.Duration
+	// RequestID 响应头 X-Request-ID，用于在服务端日志中查找这次请求
+	RequestID string
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("tuna: %d %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("tuna: %d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// Is 按错误码比较，使 errors.Is(err, ErrUserNotFound) 这样的判断成立
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code != "" && t.Code == e.Code
+}
+
+// 与 apierror 中的错误码一一对应，用于 errors.Is
+var (
+	ErrInvalidRequest   = &Error{Code: apierror.CodeInvalidRequest}
+	ErrValidationFailed = &Error{Code: apierror.CodeValidationFailed}
+	ErrNotFound         = &Error{Code: apierror.CodeNotFound}
+	ErrInternal         = &Error{Code: apierror.CodeInternal}
+
+	ErrUnauthorized       = &Error{Code: apierror.CodeUnauthorized}
+	ErrInvalidToken       = &Error{Code: apierror.CodeInvalidToken}
+	ErrInvalidCredentials = &Error{Code: apierror.CodeInvalidCredentials}
+
+	ErrUserNotFound            = &Error{Code: apierror.CodeUserNotFound}
+	ErrSubmissionNotFound      = &Error{Code: apierror.CodeSubmissionNotFound}
+	ErrDuplicateSubmission     = &Error{Code: apierror.CodeDuplicateSubmission}
+	ErrInvalidStatusTransition = &Error{Code: apierror.CodeInvalidStatusTransition}
+	ErrRejectReasonRequired    = &Error{Code: apierror.CodeRejectReasonRequired}
+	ErrRolledBack              = &Error{Code: apierror.CodeRolledBack}
+
+	ErrInvalidIdempotencyKey        = &Error{Code: apierror.CodeInvalidIdempotencyKey}
+	ErrIdempotencyKeyMismatch       = &Error{Code: apierror.CodeIdempotencyKeyMismatch}
+	ErrIdempotencyRequestInProgress = &Error{Code: apierror.CodeIdempotencyRequestInProgress}
+	ErrRateLimited                  = &Error{Code: apierror.CodeRateLimited}
+	ErrFormSubmittedTooFast         = &Error{Code: apierror.CodeFormSubmittedTooFast}
+	ErrRequestTooLarge              = &Error{Code: apierror.CodeRequestTooLarge}
+
+	ErrFileRequired         = &Error{Code: apierror.CodeFileRequired}
+	ErrFileTooLarge         = &Error{Code: apierror.CodeFileTooLarge}
+	ErrFileEmpty            = &Error{Code: apierror.CodeFileEmpty}
+	ErrInvalidFile          = &Error{Code: apierror.CodeInvalidFile}
+	ErrTooManyRows          = &Error{Code: apierror.CodeTooManyRows}
+	ErrMissingColumns       = &Error{Code: apierror.CodeMissingColumns}
+	ErrMergeRequiresPending = &Error{Code: apierror.CodeMergeRequiresPending}
+)
+
+// Transition invalid_status_transition 错误中的变更前后状态
+func (e *Error) Transition() (from, to string, ok bool) {
+	if e.Code != apierror.CodeInvalidStatusTransition {
+		return "", "", false
+	}
+	from, _ = e.Details["from"].(string)
+	to, _ = e.Details["to"].(string)
+	return from, to, true
+}
+
+// responseError 读取非 2xx 响应，响应体不是错误格式时以状态码的文字作为提示
+func responseError(resp *http.Response) *Error {
+	e := &Error{
+		StatusCode: resp.StatusCode,
+		Message:    http.StatusText(resp.StatusCode),
+		RequestID:  resp.Header.Get("X-Request-ID"),
+	}
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		e.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	var body apierror.Response
+	if json.Unmarshal(data, &body) == nil && body.Code != "" {
+		e.Code = body.Code
+		e.Message = body.Error
+		e.Fields = body.Fields
+		e.Details = body.Details
+	}
+	return e
+}
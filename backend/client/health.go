@@ -0,0 +1,41 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"tuna/health"
+)
+
+// Healthz 存活检查，服务可以处理请求时返回 nil
+func (c *Client) Healthz(ctx context.Context, service Service) error {
+	var resp health.LiveResponse
+	return c.call(ctx, request{service: service, method: http.MethodGet, path: "/healthz", retry: true}, &resp)
+}
+
+// Readyz 就绪检查，不重试。服务未就绪（503）时同时返回检查结果和 StatusCode 为 503 的 *Error，
+// 可以从结果中看到是哪一项检查失败
+func (c *Client) Readyz(ctx context.Context, service Service) (*health.Report, error) {
+	resp, err := c.send(ctx, request{service: service, method: http.MethodGet, path: "/readyz"})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil, responseError(resp)
+	}
+
+	var report health.Report
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return nil, fmt.Errorf("client: decode readyz response: %w", err)
+	}
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		return &report, &Error{
+			StatusCode: resp.StatusCode,
+			Message:    "service is not ready: " + report.Status,
+			RequestID:  resp.Header.Get("X-Request-ID"),
+		}
+	}
+	return &report, nil
+}
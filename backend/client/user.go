@@ -0,0 +1,48 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"tuna/models"
+)
+
+// SubmitUserInfo 提交用户资料。每次调用生成一个 Idempotency-Key，重试时沿用，
+// 所以即使首次请求已经保存但响应丢失，重试也不会产生重复的记录。
+// 已有待审核的重复提交时返回 ErrDuplicateSubmission
+func (c *Client) SubmitUserInfo(ctx context.Context, req models.CreateUserRequest) (*models.SubmitResponse, error) {
+	return c.SubmitUserInfoWithKey(ctx, newIdempotencyKey(), req)
+}
+
+// SubmitUserInfoWithKey 使用调用方指定的 Idempotency-Key 提交，用于跨进程重试同一次提交。
+// 同一个 key 配合不同的请求体时返回 ErrIdempotencyKeyMismatch
+func (c *Client) SubmitUserInfoWithKey(ctx context.Context, key string, req models.CreateUserRequest) (*models.SubmitResponse, error) {
+	var resp models.SubmitResponse
+	err := c.call(ctx, request{
+		service: ServiceAPI,
+		method:  http.MethodPost,
+		path:    "/api/submit",
+		header:  http.Header{"Idempotency-Key": {key}},
+		body:    req,
+		retry:   true,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SubmissionStatus 凭提交时返回的 tracking_token 查询审核进度，凭证无效时返回 ErrSubmissionNotFound
+func (c *Client) SubmissionStatus(ctx context.Context, token string) (*models.SubmissionStatusResponse, error) {
+	var resp models.SubmissionStatusResponse
+	err := c.call(ctx, request{
+		service: ServiceAPI,
+		method:  http.MethodGet,
+		path:    "/api/submissions/" + url.PathEscape(token),
+		retry:   true,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"tuna/app"
+	"tuna/config"
+)
+
+const usage = `用法: tuna <command> [args]
+
+命令:
+  serve api       启动用户端服务
+  serve admin     启动管理端服务
+  serve all       在同一进程中启动两个服务，共用数据库连接池
+  migrate <cmd>   执行数据库迁移，见 tuna migrate help
+  version         查看版本
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "serve":
+		err = serve(args)
+	case "migrate":
+		if len(args) > 0 && args[0] == "help" {
+			fmt.Print("用法: tuna migrate <command>\n\n" + app.MigrateUsage)
+			return
+		}
+		err = app.Migrate(loadConfig(), args, os.Stdout)
+		if errors.Is(err, app.ErrUsage) {
+			fmt.Fprintf(os.Stderr, "%v\n\n用法: tuna migrate <command>\n\n%s", err, app.MigrateUsage)
+			os.Exit(2)
+		}
+	case "version":
+		fmt.Println(app.VersionString())
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "未知命令: %s\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func serve(args []string) error {
+	if len(args) != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var services []string
+	switch args[0] {
+	case app.ServiceAPI, app.ServiceAdmin:
+		services = []string{args[0]}
+	case "all":
+		services = []string{app.ServiceAPI, app.ServiceAdmin}
+	default:
+		fmt.Fprintf(os.Stderr, "未知服务: %s\n\n%s", args[0], usage)
+		os.Exit(2)
+	}
+	return app.Serve(loadConfig(), services...)
+}
+
+// loadConfig 配置不合法时直接退出，列出全部错误
+func loadConfig() *config.Config {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return cfg
+}
@@ -1,9 +1,20 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+	"tuna/cors"
+	"tuna/models"
+	"tuna/notify"
+	"tuna/ratelimit"
+	"tuna/webhook"
 
 	"gopkg.in/yaml.v3"
 )
@@ -14,72 +25,423 @@ type Config struct {
 	DBUser     string
 	DBPassword string
 	DBName     string
-	APIPort    string
-	AdminPort  string
+
+	// DBMaxOpenConns 为 0 表示不限制；DBConnMaxLifetime、DBConnMaxIdleTime 为 0 表示不过期
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+	DBConnMaxIdleTime time.Duration
+	// DBConnectTimeout、DBReadTimeout、DBWriteTimeout 写入 DSN，为 0 表示使用驱动的默认值
+	DBConnectTimeout time.Duration
+	DBReadTimeout    time.Duration
+	DBWriteTimeout   time.Duration
+
+	APIPort   string
+	AdminPort string
+
+	// ShutdownDrainDelay 收到退出信号后，/readyz 返回 503 到关闭监听之间的等待时间
+	ShutdownDrainDelay time.Duration
+
+	// RequireSchemaCurrent 为 true 时 api/admin 在数据库结构落后于代码时拒绝启动
+	RequireSchemaCurrent bool
+
+	DuplicatePolicy models.DuplicatePolicy
+	IdempotencyTTL  time.Duration
+	// PhoneDefaultRegion 不带国际区号的手机号所属的地区，如 CN
+	PhoneDefaultRegion string
+
+	AdminSessionTTL        time.Duration
+	AdminBootstrapUser     string
+	AdminBootstrapPassword string
+
+	// ExportColumns 导出接口默认导出的列，为空时使用代码中的默认列
+	ExportColumns []string
+
+	// RateLimitPerIP、RateLimitPerContact 零值表示不限流
+	RateLimitPerIP      ratelimit.Limit
+	RateLimitPerContact ratelimit.Limit
+	HoneypotField       string
+	MinFillTime         time.Duration
+	TrustedProxies      []string
+
+	// APICORS、AdminCORS 两个服务各自的跨域策略
+	APICORS   cors.Policy
+	AdminCORS cors.Policy
+
+	// Outbox* 事件投递的轮询间隔、批大小、最多尝试次数、重试退避和单次投递的超时时间，见 outbox.Options
+	OutboxPollInterval time.Duration
+	OutboxBatchSize    int
+	OutboxMaxAttempts  int
+	OutboxBaseBackoff  time.Duration
+	OutboxMaxBackoff   time.Duration
+	OutboxTimeout      time.Duration
+	// Webhooks 接收领域事件的 webhook 订阅
+	Webhooks []webhook.Subscription
+
+	// EmailEnabled 是否在审核通过或拒绝后给提交者发送邮件；EmailLanguages 为邮件中依次包含的语言，
+	// EmailTemplateDir 为空时使用内置模板
+	EmailEnabled     bool
+	EmailFrom        string
+	EmailLanguages   []string
+	EmailTemplateDir string
+	SMTP             notify.SMTPOptions
+
+	// RabbitMQEnabled 是否把事件发布到 RabbitMQ 的 topic 交换机 RabbitMQExchange，路由键为事件类型；
+	// RabbitMQURL 未配置时使用 GOCRabbitMQURL
+	RabbitMQEnabled  bool
+	RabbitMQURL      string
+	RabbitMQExchange string
+	RabbitMQEvents   []string
+
+	// LogLevel 日志级别，LogOutput 为 stdout、stderr 或文件路径，LogAccessLevel 为访问日志的级别
+	LogLevel       string
+	LogOutput      string
+	LogAccessLevel string
+
+	// MetricsEnabled 是否统计并暴露 Prometheus 指标；MetricsAPIPort/MetricsAdminPort 不为空时
+	// 指标单独监听该端口（默认），为空时挂在服务本身端口的 /metrics 上，不需要登录即可访问
+	MetricsEnabled   bool
+	MetricsAPIPort   string
+	MetricsAdminPort string
+
+	// GOCWrapperPort、GOCRabbitMQURL、GOCSourceDir 供 run.sh 构建和上报覆盖率使用
+	GOCWrapperPort string
+	GOCRabbitMQURL string
+	GOCSourceDir   string
 }
 
+// ConfigFile config.yaml 的结构。env 标签为覆盖该项的环境变量，环境变量为空时使用文件中的值，
+// 字符串列表类型的环境变量按逗号分隔，其他列表类型为 JSON 数组。结构体字段上的 env 标签是其中各项环境变量的前缀
 type ConfigFile struct {
 	Database struct {
-		Host     string `yaml:"host"`
-		Port     string `yaml:"port"`
-		User     string `yaml:"user"`
-		Password string `yaml:"password"`
-		Name     string `yaml:"name"`
+		Host     string `yaml:"host" env:"DB_HOST"`
+		Port     string `yaml:"port" env:"DB_PORT"`
+		User     string `yaml:"user" env:"DB_USER"`
+		Password string `yaml:"password" env:"DB_PASSWORD"`
+		Name     string `yaml:"name" env:"DB_NAME"`
+
+		RequireSchemaCurrent bool `yaml:"require_schema_current" env:"DB_REQUIRE_SCHEMA_CURRENT"`
+
+		MaxOpenConns    int    `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+		MaxIdleConns    int    `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+		ConnMaxLifetime string `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+		ConnMaxIdleTime string `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
+		ConnectTimeout  string `yaml:"connect_timeout" env:"DB_CONNECT_TIMEOUT"`
+		ReadTimeout     string `yaml:"read_timeout" env:"DB_READ_TIMEOUT"`
+		WriteTimeout    string `yaml:"write_timeout" env:"DB_WRITE_TIMEOUT"`
 	} `yaml:"database"`
 	Ports struct {
-		API   string `yaml:"api"`
-		Admin string `yaml:"admin"`
+		API   string `yaml:"api" env:"API_PORT"`
+		Admin string `yaml:"admin" env:"ADMIN_PORT"`
 	} `yaml:"ports"`
+	Shutdown struct {
+		DrainDelay string `yaml:"drain_delay" env:"SHUTDOWN_DRAIN_DELAY"`
+	} `yaml:"shutdown"`
+	Submission struct {
+		DuplicatePolicy    string `yaml:"duplicate_policy" env:"DUPLICATE_POLICY"`
+		IdempotencyTTL     string `yaml:"idempotency_ttl" env:"IDEMPOTENCY_TTL"`
+		PhoneDefaultRegion string `yaml:"phone_default_region" env:"PHONE_DEFAULT_REGION"`
+	} `yaml:"submission"`
+	AdminAuth struct {
+		SessionTTL        string `yaml:"session_ttl" env:"ADMIN_SESSION_TTL"`
+		BootstrapUsername string `yaml:"bootstrap_username" env:"ADMIN_BOOTSTRAP_USERNAME"`
+		BootstrapPassword string `yaml:"bootstrap_password" env:"ADMIN_BOOTSTRAP_PASSWORD"`
+	} `yaml:"admin_auth"`
+	RateLimit struct {
+		PerIP          string   `yaml:"per_ip" env:"RATE_LIMIT_PER_IP"`
+		PerContact     string   `yaml:"per_contact" env:"RATE_LIMIT_PER_CONTACT"`
+		HoneypotField  string   `yaml:"honeypot_field" env:"RATE_LIMIT_HONEYPOT_FIELD"`
+		MinFillTime    string   `yaml:"min_fill_time" env:"RATE_LIMIT_MIN_FILL_TIME"`
+		TrustedProxies []string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+	} `yaml:"rate_limit"`
+	CORS struct {
+		API   CORSFile `yaml:"api" env:"CORS_API_"`
+		Admin CORSFile `yaml:"admin" env:"CORS_ADMIN_"`
+	} `yaml:"cors"`
+	Outbox struct {
+		PollInterval string `yaml:"poll_interval" env:"OUTBOX_POLL_INTERVAL"`
+		BatchSize    int    `yaml:"batch_size" env:"OUTBOX_BATCH_SIZE"`
+		MaxAttempts  int    `yaml:"max_attempts" env:"OUTBOX_MAX_ATTEMPTS"`
+		BaseBackoff  string `yaml:"base_backoff" env:"OUTBOX_BASE_BACKOFF"`
+		MaxBackoff   string `yaml:"max_backoff" env:"OUTBOX_MAX_BACKOFF"`
+		Timeout      string `yaml:"timeout" env:"OUTBOX_TIMEOUT"`
+	} `yaml:"outbox"`
+	Webhooks []WebhookFile `yaml:"webhooks" env:"WEBHOOKS"`
+	Notify   struct {
+		Email struct {
+			Enabled     bool     `yaml:"enabled" env:"NOTIFY_EMAIL_ENABLED"`
+			From        string   `yaml:"from" env:"NOTIFY_EMAIL_FROM"`
+			Languages   []string `yaml:"languages" env:"NOTIFY_EMAIL_LANGUAGES"`
+			TemplateDir string   `yaml:"template_dir" env:"NOTIFY_EMAIL_TEMPLATE_DIR"`
+			SMTP        struct {
+				Host     string `yaml:"host" env:"SMTP_HOST"`
+				Port     string `yaml:"port" env:"SMTP_PORT"`
+				Username string `yaml:"username" env:"SMTP_USERNAME"`
+				Password string `yaml:"password" env:"SMTP_PASSWORD"`
+				TLS      string `yaml:"tls" env:"SMTP_TLS"`
+			} `yaml:"smtp"`
+		} `yaml:"email"`
+	} `yaml:"notify"`
+	Events struct {
+		RabbitMQ struct {
+			Enabled  bool     `yaml:"enabled" env:"EVENTS_RABBITMQ_ENABLED"`
+			URL      string   `yaml:"url" env:"EVENTS_RABBITMQ_URL"`
+			Exchange string   `yaml:"exchange" env:"EVENTS_RABBITMQ_EXCHANGE"`
+			Events   []string `yaml:"events" env:"EVENTS_RABBITMQ_EVENTS"`
+		} `yaml:"rabbitmq"`
+	} `yaml:"events"`
+	Export struct {
+		Columns []string `yaml:"columns" env:"EXPORT_COLUMNS"`
+	} `yaml:"export"`
+	Logging struct {
+		Level       string `yaml:"level" env:"LOG_LEVEL"`
+		Output      string `yaml:"output" env:"LOG_OUTPUT"`
+		AccessLevel string `yaml:"access_level" env:"LOG_ACCESS_LEVEL"`
+	} `yaml:"logging"`
+	Metrics struct {
+		Enabled   bool   `yaml:"enabled" env:"METRICS_ENABLED"`
+		APIPort   string `yaml:"api_port" env:"METRICS_API_PORT"`
+		AdminPort string `yaml:"admin_port" env:"METRICS_ADMIN_PORT"`
+	} `yaml:"metrics"`
 	GOC struct {
-		WrapperPort string `yaml:"wrapper_port"`
-		RabbitMQURL string `yaml:"rabbitmq_url"`
+		WrapperPort string `yaml:"wrapper_port" env:"GOC_WRAPPER_PORT"`
+		RabbitMQURL string `yaml:"rabbitmq_url" env:"RABBITMQ_URL"`
 	} `yaml:"goc"`
 	GOCBuild struct {
-		SourceDir string `yaml:"source_dir"`
+		SourceDir string `yaml:"source_dir" env:"GOC_SOURCE_DIR"`
 	} `yaml:"goc_build"`
 }
 
-func LoadConfig() *Config {
-	cfg := &Config{}
-
-	// 尝试从配置文件加载
-	configPath := getConfigPath()
-	if configPath != "" {
-		if fileCfg, err := loadFromFile(configPath); err == nil {
-			cfg.DBHost = getEnv("DB_HOST", fileCfg.Database.Host)
-			cfg.DBPort = getEnv("DB_PORT", fileCfg.Database.Port)
-			cfg.DBUser = getEnv("DB_USER", fileCfg.Database.User)
-			cfg.DBPassword = getEnv("DB_PASSWORD", fileCfg.Database.Password)
-			cfg.DBName = getEnv("DB_NAME", fileCfg.Database.Name)
-			cfg.APIPort = getEnv("API_PORT", fileCfg.Ports.API)
-			cfg.AdminPort = getEnv("ADMIN_PORT", fileCfg.Ports.Admin)
-			return cfg
+// CORSFile 一个服务的跨域策略，见 cors.Policy
+type CORSFile struct {
+	AllowedOrigins   []string `yaml:"allowed_origins" env:"ALLOWED_ORIGINS"`
+	AllowedMethods   []string `yaml:"allowed_methods" env:"ALLOWED_METHODS"`
+	AllowedHeaders   []string `yaml:"allowed_headers" env:"ALLOWED_HEADERS"`
+	ExposedHeaders   []string `yaml:"exposed_headers" env:"EXPOSED_HEADERS"`
+	AllowCredentials bool     `yaml:"allow_credentials" env:"ALLOW_CREDENTIALS"`
+	MaxAge           string   `yaml:"max_age" env:"MAX_AGE"`
+}
+
+func (f CORSFile) build(key string, errs *errorList) cors.Policy {
+	return cors.Policy{
+		AllowedOrigins:   f.AllowedOrigins,
+		AllowedMethods:   f.AllowedMethods,
+		AllowedHeaders:   f.AllowedHeaders,
+		ExposedHeaders:   f.ExposedHeaders,
+		AllowCredentials: f.AllowCredentials,
+		MaxAge:           errs.duration(key+".max_age", f.MaxAge),
+	}
+}
+
+// WebhookFile 一个 webhook 订阅，见 webhook.Subscription
+type WebhookFile struct {
+	Name   string   `yaml:"name" json:"name"`
+	URL    string   `yaml:"url" json:"url"`
+	Secret string   `yaml:"secret" json:"secret"`
+	Events []string `yaml:"events" json:"events"`
+}
+
+// defaultFile 配置文件中没有的项使用的默认值
+func defaultFile() ConfigFile {
+	var f ConfigFile
+	f.Database.Host = "127.0.0.1"
+	f.Database.Port = "6666"
+	f.Database.User = "agile"
+	f.Database.Password = "agile"
+	f.Database.Name = "tuna"
+	f.Database.MaxOpenConns = 25
+	f.Database.MaxIdleConns = 5
+	f.Database.ConnMaxLifetime = "30m"
+	f.Database.ConnMaxIdleTime = "5m"
+	f.Database.ConnectTimeout = "5s"
+	f.Database.ReadTimeout = "30s"
+	f.Database.WriteTimeout = "30s"
+	f.Ports.API = "8812"
+	f.Ports.Admin = "8813"
+	f.Shutdown.DrainDelay = "0s"
+	f.Submission.DuplicatePolicy = "reject"
+	f.Submission.IdempotencyTTL = "24h"
+	f.Submission.PhoneDefaultRegion = "CN"
+	f.AdminAuth.SessionTTL = "12h"
+	f.RateLimit.PerIP = "30/1m"
+	f.RateLimit.PerContact = "5/1h"
+	f.RateLimit.HoneypotField = "website"
+	f.RateLimit.MinFillTime = "0s"
+	// 用户端是公开的提交页面，允许任意来源；管理端只允许本地调试用的页面地址
+	f.CORS.API = CORSFile{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET", "POST", "OPTIONS"},
+		AllowedHeaders: []string{"Content-Type", "Accept-Language", "Idempotency-Key", "X-Request-ID"},
+		ExposedHeaders: []string{"Retry-After", "X-Request-ID", "Idempotent-Replayed"},
+		MaxAge:         "10m",
+	}
+	f.CORS.Admin = CORSFile{
+		AllowedOrigins: []string{"http://localhost:3001", "http://127.0.0.1:3001"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "OPTIONS"},
+		AllowedHeaders: []string{"Content-Type", "Accept-Language", "Authorization", "X-Request-ID"},
+		ExposedHeaders: []string{"Content-Disposition", "X-Request-ID"},
+		MaxAge:         "10m",
+	}
+	f.Outbox.PollInterval = "1s"
+	f.Outbox.BatchSize = 100
+	f.Outbox.MaxAttempts = 10
+	f.Outbox.BaseBackoff = "5s"
+	f.Outbox.MaxBackoff = "1h"
+	f.Outbox.Timeout = "10s"
+	f.Notify.Email.Languages = []string{notify.LangZH, notify.LangEN}
+	f.Notify.Email.SMTP.Port = "587"
+	f.Notify.Email.SMTP.TLS = notify.TLSStartTLS
+	f.Events.RabbitMQ.Exchange = "tuna.events"
+	f.Events.RabbitMQ.Events = append([]string(nil), models.EventTypes...)
+	f.Logging.Level = "info"
+	f.Logging.Output = "stdout"
+	f.Logging.AccessLevel = "info"
+	f.Metrics.Enabled = true
+	// /metrics 不需要登录，默认不挂在对外的服务端口上
+	f.Metrics.APIPort = "9812"
+	f.Metrics.AdminPort = "9813"
+	f.GOC.WrapperPort = "7777"
+	return f
+}
+
+// LoadConfig 依次使用默认值、配置文件和环境变量，然后校验。配置文件格式错误、有未知的项
+// 或任何值不合法时返回错误，错误中列出全部不合法的项
+func LoadConfig() (*Config, error) {
+	file := defaultFile()
+
+	path, explicit := getConfigPath()
+	if path != "" {
+		if err := loadFromFile(path, &file); err != nil {
+			if explicit || !errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("config file %s: %w", path, err)
+			}
 		}
 	}
 
-	// 如果配置文件不存在或读取失败，使用默认值
-	cfg.DBHost = getEnv("DB_HOST", "127.0.0.1")
-	cfg.DBPort = getEnv("DB_PORT", "6666")
-	cfg.DBUser = getEnv("DB_USER", "agile")
-	cfg.DBPassword = getEnv("DB_PASSWORD", "agile")
-	cfg.DBName = getEnv("DB_NAME", "tuna")
-	cfg.APIPort = getEnv("API_PORT", "8812")
-	cfg.AdminPort = getEnv("ADMIN_PORT", "8813")
+	if err := applyEnv(reflect.ValueOf(&file).Elem()); err != nil {
+		return nil, err
+	}
+
+	cfg, err := file.build()
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// build 解析时长和限流等字符串，集中返回全部解析错误
+func (f *ConfigFile) build() (*Config, error) {
+	var errs errorList
+	cfg := &Config{
+		DBHost:                 f.Database.Host,
+		DBPort:                 f.Database.Port,
+		DBUser:                 f.Database.User,
+		DBPassword:             f.Database.Password,
+		DBName:                 f.Database.Name,
+		DBMaxOpenConns:         f.Database.MaxOpenConns,
+		DBMaxIdleConns:         f.Database.MaxIdleConns,
+		DBConnMaxLifetime:      errs.duration("database.conn_max_lifetime", f.Database.ConnMaxLifetime),
+		DBConnMaxIdleTime:      errs.duration("database.conn_max_idle_time", f.Database.ConnMaxIdleTime),
+		DBConnectTimeout:       errs.duration("database.connect_timeout", f.Database.ConnectTimeout),
+		DBReadTimeout:          errs.duration("database.read_timeout", f.Database.ReadTimeout),
+		DBWriteTimeout:         errs.duration("database.write_timeout", f.Database.WriteTimeout),
+		RequireSchemaCurrent:   f.Database.RequireSchemaCurrent,
+		APIPort:                f.Ports.API,
+		AdminPort:              f.Ports.Admin,
+		ShutdownDrainDelay:     errs.duration("shutdown.drain_delay", f.Shutdown.DrainDelay),
+		IdempotencyTTL:         errs.duration("submission.idempotency_ttl", f.Submission.IdempotencyTTL),
+		PhoneDefaultRegion:     strings.ToUpper(strings.TrimSpace(f.Submission.PhoneDefaultRegion)),
+		AdminSessionTTL:        errs.duration("admin_auth.session_ttl", f.AdminAuth.SessionTTL),
+		AdminBootstrapUser:     f.AdminAuth.BootstrapUsername,
+		AdminBootstrapPassword: f.AdminAuth.BootstrapPassword,
+		ExportColumns:          f.Export.Columns,
+		RateLimitPerIP:         errs.limit("rate_limit.per_ip", f.RateLimit.PerIP),
+		RateLimitPerContact:    errs.limit("rate_limit.per_contact", f.RateLimit.PerContact),
+		HoneypotField:          strings.TrimSpace(f.RateLimit.HoneypotField),
+		MinFillTime:            errs.duration("rate_limit.min_fill_time", f.RateLimit.MinFillTime),
+		TrustedProxies:         f.RateLimit.TrustedProxies,
+		APICORS:                f.CORS.API.build("cors.api", &errs),
+		AdminCORS:              f.CORS.Admin.build("cors.admin", &errs),
+		OutboxPollInterval:     errs.duration("outbox.poll_interval", f.Outbox.PollInterval),
+		OutboxBatchSize:        f.Outbox.BatchSize,
+		OutboxMaxAttempts:      f.Outbox.MaxAttempts,
+		OutboxBaseBackoff:      errs.duration("outbox.base_backoff", f.Outbox.BaseBackoff),
+		OutboxMaxBackoff:       errs.duration("outbox.max_backoff", f.Outbox.MaxBackoff),
+		OutboxTimeout:          errs.duration("outbox.timeout", f.Outbox.Timeout),
+		EmailEnabled:           f.Notify.Email.Enabled,
+		EmailFrom:              strings.TrimSpace(f.Notify.Email.From),
+		EmailLanguages:         f.Notify.Email.Languages,
+		EmailTemplateDir:       f.Notify.Email.TemplateDir,
+		RabbitMQEnabled:        f.Events.RabbitMQ.Enabled,
+		RabbitMQURL:            f.Events.RabbitMQ.URL,
+		RabbitMQExchange:       strings.TrimSpace(f.Events.RabbitMQ.Exchange),
+		RabbitMQEvents:         f.Events.RabbitMQ.Events,
+		LogLevel:               f.Logging.Level,
+		LogOutput:              f.Logging.Output,
+		LogAccessLevel:         f.Logging.AccessLevel,
+		MetricsEnabled:         f.Metrics.Enabled,
+		MetricsAPIPort:         f.Metrics.APIPort,
+		MetricsAdminPort:       f.Metrics.AdminPort,
+		GOCWrapperPort:         f.GOC.WrapperPort,
+		GOCRabbitMQURL:         f.GOC.RabbitMQURL,
+		GOCSourceDir:           f.GOCBuild.SourceDir,
+	}
+	policy, err := models.ParseDuplicatePolicy(f.Submission.DuplicatePolicy)
+	if err != nil {
+		errs.add("submission.duplicate_policy: %v", err)
+	}
+	cfg.DuplicatePolicy = policy
+	// 与 goc 共用同一个 RabbitMQ 时不需要重复配置
+	if cfg.RabbitMQURL == "" {
+		cfg.RabbitMQURL = cfg.GOCRabbitMQURL
+	}
+	cfg.SMTP = notify.SMTPOptions{
+		Host:     f.Notify.Email.SMTP.Host,
+		Port:     f.Notify.Email.SMTP.Port,
+		Username: f.Notify.Email.SMTP.Username,
+		Password: f.Notify.Email.SMTP.Password,
+		TLS:      strings.ToLower(strings.TrimSpace(f.Notify.Email.SMTP.TLS)),
+	}
+	for _, w := range f.Webhooks {
+		cfg.Webhooks = append(cfg.Webhooks, webhook.Subscription{
+			Name:   strings.TrimSpace(w.Name),
+			URL:    strings.TrimSpace(w.URL),
+			Secret: w.Secret,
+			Events: w.Events,
+		})
+	}
+	return cfg, errs.err()
+}
 
-	return cfg
+// ApplyReloadable 把 next 中可以热更新的字段（日志级别和提交接口的防刷配置）复制到 c，
+// 其他字段保持不变。返回值表示 next 中是否还有需要重启才能生效的变化
+func (c *Config) ApplyReloadable(next *Config) (restartRequired bool) {
+	updated := *c
+	updated.LogLevel = next.LogLevel
+	updated.LogAccessLevel = next.LogAccessLevel
+	updated.RateLimitPerIP = next.RateLimitPerIP
+	updated.RateLimitPerContact = next.RateLimitPerContact
+	updated.HoneypotField = next.HoneypotField
+	updated.MinFillTime = next.MinFillTime
+	*c = updated
+	return !reflect.DeepEqual(updated, *next)
 }
 
-func getConfigPath() string {
+// getConfigPath explicit 表示路径来自 CONFIG_PATH，此时文件不存在视为错误
+func getConfigPath() (path string, explicit bool) {
 	// 首先检查环境变量
 	if path := os.Getenv("CONFIG_PATH"); path != "" {
-		return path
+		return path, true
 	}
 
 	// 尝试在backend目录下查找config.yaml
 	// 获取当前工作目录
 	wd, err := os.Getwd()
 	if err != nil {
-		return ""
+		return "", false
 	}
 
 	// 尝试多个可能的路径
@@ -91,35 +453,39 @@ func getConfigPath() string {
 
 	for _, path := range paths {
 		if _, err := os.Stat(path); err == nil {
-			return path
+			return path, false
 		}
 	}
 
-	return ""
+	return "", false
 }
 
-func loadFromFile(path string) (*ConfigFile, error) {
+// loadFromFile 把文件内容覆盖到 cfg 上，文件中没有的项保留原值。未知的项视为错误，避免拼错的配置被静默忽略
+func loadFromFile(path string, cfg *ConfigFile) error {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	var cfg ConfigFile
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, err
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && err != io.EOF {
+		return err
 	}
-
-	return &cfg, nil
+	return nil
 }
 
 func (c *Config) GetDSN() string {
-	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
 		c.DBUser, c.DBPassword, c.DBHost, c.DBPort, c.DBName)
-}
-
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	if c.DBConnectTimeout > 0 {
+		dsn += "&timeout=" + c.DBConnectTimeout.String()
+	}
+	if c.DBReadTimeout > 0 {
+		dsn += "&readTimeout=" + c.DBReadTimeout.String()
+	}
+	if c.DBWriteTimeout > 0 {
+		dsn += "&writeTimeout=" + c.DBWriteTimeout.String()
 	}
-	return defaultValue
+	return dsn
 }
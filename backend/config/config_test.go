@@ -0,0 +1,168 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"tuna/ratelimit"
+)
+
+// writeConfig 把 content 写入临时文件并通过 CONFIG_PATH 指向它
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_PATH", path)
+	return path
+}
+
+// expectError err 为空或不包含 want 中的任意一项时终止测试
+func expectError(t *testing.T, err error, want ...string) {
+	t.Helper()
+	if err == nil {
+		t.Fatalf("err = nil, want %q", want)
+	}
+	for _, w := range want {
+		if !strings.Contains(err.Error(), w) {
+			t.Errorf("err = %v, want it to contain %q", err, w)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	writeConfig(t, `
+database:
+  host: db.internal
+  max_open_conns: 50
+submission:
+  idempotency_ttl: 1h
+rate_limit:
+  per_ip: 10/1m
+`)
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DBHost != "db.internal" || cfg.DBMaxOpenConns != 50 || cfg.IdempotencyTTL != time.Hour {
+		t.Errorf("file values not applied: host %q, max_open_conns %d, idempotency_ttl %s",
+			cfg.DBHost, cfg.DBMaxOpenConns, cfg.IdempotencyTTL)
+	}
+	if want := (ratelimit.Limit{Requests: 10, Per: time.Minute}); cfg.RateLimitPerIP != want {
+		t.Errorf("rate_limit.per_ip = %+v, want %+v", cfg.RateLimitPerIP, want)
+	}
+	// 文件中没有的项保留默认值
+	if cfg.DBPort != "6666" || cfg.APIPort != "8812" {
+		t.Errorf("defaults lost: database.port %q, ports.api %q", cfg.DBPort, cfg.APIPort)
+	}
+	// /metrics 默认不挂在对外的服务端口上
+	if cfg.MetricsAPIPort == "" || cfg.MetricsAdminPort == "" {
+		t.Errorf("metrics ports = %q, %q, want separate ports by default", cfg.MetricsAPIPort, cfg.MetricsAdminPort)
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	t.Run("bad yaml", func(t *testing.T) {
+		path := writeConfig(t, "database: [host\n")
+		_, err := LoadConfig()
+		expectError(t, err, path)
+	})
+	t.Run("unknown key", func(t *testing.T) {
+		writeConfig(t, "database:\n  hots: db.internal\n")
+		_, err := LoadConfig()
+		expectError(t, err, "hots")
+	})
+	t.Run("missing explicit file", func(t *testing.T) {
+		t.Setenv("CONFIG_PATH", filepath.Join(t.TempDir(), "missing.yaml"))
+		if _, err := LoadConfig(); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("err = %v, want os.ErrNotExist", err)
+		}
+	})
+}
+
+func TestApplyEnv(t *testing.T) {
+	file := defaultFile()
+	env := map[string]string{
+		"DB_HOST":                  "db.internal",                                  // string
+		"METRICS_ENABLED":          "false",                                        // bool
+		"DB_MAX_OPEN_CONNS":        "7",                                            // int
+		"TRUSTED_PROXIES":          " 10.0.0.1, ,10.0.0.0/8 ",                      // 字符串列表按逗号分隔
+		"WEBHOOKS":                 `[{"name":"crm","url":"https://crm.example"}]`, // 其他列表为 JSON 数组
+		"CORS_API_ALLOWED_ORIGINS": "https://a.example",                            // 结构体字段的前缀
+		"LOG_LEVEL":                "",                                             // 为空时不覆盖
+	}
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	if err := applyEnv(reflect.ValueOf(&file).Elem()); err != nil {
+		t.Fatal(err)
+	}
+
+	if file.Database.Host != "db.internal" {
+		t.Errorf("DB_HOST: host = %q", file.Database.Host)
+	}
+	if file.Metrics.Enabled {
+		t.Error("METRICS_ENABLED: enabled = true")
+	}
+	if file.Database.MaxOpenConns != 7 {
+		t.Errorf("DB_MAX_OPEN_CONNS: max_open_conns = %d", file.Database.MaxOpenConns)
+	}
+	if want := []string{"10.0.0.1", "10.0.0.0/8"}; !reflect.DeepEqual(file.RateLimit.TrustedProxies, want) {
+		t.Errorf("TRUSTED_PROXIES: trusted_proxies = %q, want %q", file.RateLimit.TrustedProxies, want)
+	}
+	if want := []WebhookFile{{Name: "crm", URL: "https://crm.example"}}; !reflect.DeepEqual(file.Webhooks, want) {
+		t.Errorf("WEBHOOKS: webhooks = %+v, want %+v", file.Webhooks, want)
+	}
+	if want := []string{"https://a.example"}; !reflect.DeepEqual(file.CORS.API.AllowedOrigins, want) {
+		t.Errorf("CORS_API_ALLOWED_ORIGINS: allowed_origins = %q, want %q", file.CORS.API.AllowedOrigins, want)
+	}
+	if !reflect.DeepEqual(file.CORS.Admin, defaultFile().CORS.Admin) {
+		t.Error("CORS_API_ALLOWED_ORIGINS changed cors.admin")
+	}
+	if file.Logging.Level != "info" {
+		t.Errorf("empty LOG_LEVEL: level = %q, want the default", file.Logging.Level)
+	}
+}
+
+func TestApplyEnvErrors(t *testing.T) {
+	t.Setenv("METRICS_ENABLED", "maybe")
+	t.Setenv("DB_MAX_OPEN_CONNS", "many")
+	t.Setenv("WEBHOOKS", "crm")
+	file := defaultFile()
+	// 全部错误一次报告
+	expectError(t, applyEnv(reflect.ValueOf(&file).Elem()), "METRICS_ENABLED", "DB_MAX_OPEN_CONNS", "WEBHOOKS")
+
+	t.Setenv("TEST_RATIO", "0.5")
+	var unsupported struct {
+		Ratio float64 `env:"TEST_RATIO"`
+	}
+	expectError(t, applyEnv(reflect.ValueOf(&unsupported).Elem()), "TEST_RATIO: unsupported type float64")
+}
+
+func TestLoadConfigInvalidValues(t *testing.T) {
+	writeConfig(t, `
+database:
+  conn_max_lifetime: soon
+ports:
+  admin: "8812"
+submission:
+  duplicate_policy: ignore
+`)
+	_, err := LoadConfig()
+	expectError(t, err, "database.conn_max_lifetime", "submission.duplicate_policy")
+
+	// 解析通过后再校验取值范围和冲突
+	writeConfig(t, `
+ports:
+  admin: "8812"
+outbox:
+  batch_size: 0
+`)
+	_, err = LoadConfig()
+	expectError(t, err, "ports.admin: same as ports.api", "outbox.batch_size: must be positive")
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"tuna/ratelimit"
+)
+
+// applyEnv 按字段的 env 标签用环境变量覆盖 v 中的值，为空的环境变量不覆盖
+func applyEnv(v reflect.Value) error {
+	var errs errorList
+	applyEnvFields(v, "", &errs)
+	return errs.err()
+}
+
+func applyEnvFields(v reflect.Value, prefix string, errs *errorList) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			applyEnvFields(value, prefix+field.Tag.Get("env"), errs)
+			continue
+		}
+		key := field.Tag.Get("env")
+		if key == "" {
+			continue
+		}
+		key = prefix + key
+		raw := os.Getenv(key)
+		if raw == "" {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.String:
+			value.SetString(raw)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				errs.add("%s: invalid boolean %q", key, raw)
+				continue
+			}
+			value.SetBool(b)
+		case reflect.Int:
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				errs.add("%s: invalid integer %q", key, raw)
+				continue
+			}
+			value.SetInt(int64(n))
+		case reflect.Slice:
+			if field.Type.Elem().Kind() == reflect.String {
+				value.Set(reflect.ValueOf(splitList(raw)))
+				continue
+			}
+			list := reflect.New(field.Type)
+			if err := json.Unmarshal([]byte(raw), list.Interface()); err != nil {
+				errs.add("%s: invalid JSON array: %v", key, err)
+				continue
+			}
+			value.Set(list.Elem())
+		default:
+			errs.add("%s: unsupported type %s", key, field.Type)
+		}
+	}
+}
+
+// splitList 按逗号分隔，去掉空项
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// errorList 收集全部配置错误，一次报告
+type errorList []error
+
+func (e *errorList) add(format string, args ...any) {
+	*e = append(*e, fmt.Errorf(format, args...))
+}
+
+func (e errorList) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e errorList) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "invalid config:\n  " + strings.Join(msgs, "\n  ")
+}
+
+func (e errorList) Unwrap() []error {
+	return e
+}
+
+// duration 解析时长，出错时记录 key 并返回 0
+func (e *errorList) duration(key, s string) time.Duration {
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		e.add("%s: invalid duration %q", key, s)
+		return 0
+	}
+	if d < 0 {
+		e.add("%s: must not be negative", key)
+		return 0
+	}
+	return d
+}
+
+// limit 解析 "<次数>/<时长>" 格式的限流配置
+func (e *errorList) limit(key, s string) ratelimit.Limit {
+	l, err := ratelimit.ParseLimit(s)
+	if err != nil {
+		e.add("%s: %v", key, err)
+	}
+	return l
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"tuna/logging"
+	"tuna/models"
+	"tuna/notify"
+	"tuna/validation"
+)
+
+// webhookName 加上 "webhook:" 前缀后不超过 outbox_deliveries.sink 的长度
+var webhookName = regexp.MustCompile(`^[a-z0-9_-]{1,56}$`)
+
+// Validate 检查各项的取值范围和相互之间的冲突，返回全部不合法的项
+func (c *Config) Validate() error {
+	var errs errorList
+
+	if c.DBHost == "" {
+		errs.add("database.host: required")
+	}
+	if c.DBUser == "" {
+		errs.add("database.user: required")
+	}
+	if c.DBName == "" {
+		errs.add("database.name: required")
+	}
+	errs.port("database.port", c.DBPort, true)
+	if c.DBMaxOpenConns < 0 {
+		errs.add("database.max_open_conns: must not be negative")
+	}
+	if c.DBMaxIdleConns < 0 {
+		errs.add("database.max_idle_conns: must not be negative")
+	}
+	if c.DBMaxOpenConns > 0 && c.DBMaxIdleConns > c.DBMaxOpenConns {
+		errs.add("database.max_idle_conns: must not exceed max_open_conns (%d)", c.DBMaxOpenConns)
+	}
+
+	errs.port("ports.api", c.APIPort, true)
+	errs.port("ports.admin", c.AdminPort, true)
+	errs.port("metrics.api_port", c.MetricsAPIPort, false)
+	errs.port("metrics.admin_port", c.MetricsAdminPort, false)
+	errs.port("goc.wrapper_port", c.GOCWrapperPort, false)
+	if c.APIPort == c.AdminPort {
+		errs.add("ports.admin: same as ports.api (%s)", c.APIPort)
+	}
+	for _, m := range []struct{ key, port string }{
+		{"metrics.api_port", c.MetricsAPIPort},
+		{"metrics.admin_port", c.MetricsAdminPort},
+	} {
+		if m.port != "" && (m.port == c.APIPort || m.port == c.AdminPort) {
+			errs.add("%s: conflicts with a service port (%s)", m.key, m.port)
+		}
+	}
+
+	if c.IdempotencyTTL <= 0 {
+		errs.add("submission.idempotency_ttl: must be positive")
+	}
+	if !validation.SupportedRegion(c.PhoneDefaultRegion) {
+		errs.add("submission.phone_default_region: unsupported region %q", c.PhoneDefaultRegion)
+	}
+
+	if c.AdminSessionTTL <= 0 {
+		errs.add("admin_auth.session_ttl: must be positive")
+	}
+	if (c.AdminBootstrapUser == "") != (c.AdminBootstrapPassword == "") {
+		errs.add("admin_auth: bootstrap_username and bootstrap_password must be set together")
+	}
+
+	for _, proxy := range c.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				errs.add("rate_limit.trusted_proxies: invalid IP or CIDR %q", proxy)
+			}
+		}
+	}
+
+	if err := c.APICORS.Validate(); err != nil {
+		errs.add("cors.api: %v", err)
+	}
+	if err := c.AdminCORS.Validate(); err != nil {
+		errs.add("cors.admin: %v", err)
+	}
+
+	if c.OutboxPollInterval <= 0 {
+		errs.add("outbox.poll_interval: must be positive")
+	}
+	if c.OutboxBatchSize <= 0 {
+		errs.add("outbox.batch_size: must be positive")
+	}
+	if c.OutboxMaxAttempts <= 0 {
+		errs.add("outbox.max_attempts: must be positive")
+	}
+	if c.OutboxBaseBackoff <= 0 {
+		errs.add("outbox.base_backoff: must be positive")
+	}
+	if c.OutboxMaxBackoff < c.OutboxBaseBackoff {
+		errs.add("outbox.max_backoff: must not be less than base_backoff")
+	}
+	if c.OutboxTimeout <= 0 {
+		errs.add("outbox.timeout: must be positive")
+	}
+	names := map[string]bool{}
+	for i, w := range c.Webhooks {
+		key := fmt.Sprintf("webhooks[%d]", i)
+		if !webhookName.MatchString(w.Name) {
+			errs.add("%s.name: must be 1-56 characters of a-z, 0-9, _ and -", key)
+		} else if names[w.Name] {
+			errs.add("%s.name: duplicate name %q", key, w.Name)
+		}
+		names[w.Name] = true
+		if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs.add("%s.url: must be an http:// or https:// URL", key)
+		}
+		if w.Secret == "" {
+			errs.add("%s.secret: required", key)
+		}
+		if len(w.Events) == 0 {
+			errs.add("%s.events: required", key)
+		}
+		for _, event := range w.Events {
+			if !slices.Contains(models.EventTypes, event) {
+				errs.add("%s.events: unknown event %q", key, event)
+			}
+		}
+	}
+
+	if c.EmailEnabled {
+		if _, err := mail.ParseAddress(c.EmailFrom); err != nil {
+			errs.add("notify.email.from: invalid address %q", c.EmailFrom)
+		}
+		if len(c.EmailLanguages) == 0 {
+			errs.add("notify.email.languages: required")
+		}
+		for _, lang := range c.EmailLanguages {
+			if !slices.Contains(notify.Languages, lang) {
+				errs.add("notify.email.languages: unsupported language %q", lang)
+			}
+		}
+		if c.SMTP.Host == "" {
+			errs.add("notify.email.smtp.host: required")
+		}
+		errs.port("notify.email.smtp.port", c.SMTP.Port, true)
+		switch c.SMTP.TLS {
+		case notify.TLSStartTLS, notify.TLSImplicit, notify.TLSNone:
+		default:
+			errs.add("notify.email.smtp.tls: must be starttls, tls or none")
+		}
+	}
+
+	if c.RabbitMQEnabled {
+		if c.RabbitMQURL == "" {
+			errs.add("events.rabbitmq.url: required when goc.rabbitmq_url is empty")
+		} else {
+			errs.amqpURL("events.rabbitmq.url", c.RabbitMQURL)
+		}
+		if c.RabbitMQExchange == "" {
+			errs.add("events.rabbitmq.exchange: required")
+		}
+		if len(c.RabbitMQEvents) == 0 {
+			errs.add("events.rabbitmq.events: required")
+		}
+		for _, event := range c.RabbitMQEvents {
+			if !slices.Contains(models.EventTypes, event) {
+				errs.add("events.rabbitmq.events: unknown event %q", event)
+			}
+		}
+	}
+
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		errs.add("logging.level: %v", err)
+	}
+	if _, err := logging.ParseLevel(c.LogAccessLevel); err != nil {
+		errs.add("logging.access_level: %v", err)
+	}
+
+	if c.GOCRabbitMQURL != "" {
+		errs.amqpURL("goc.rabbitmq_url", c.GOCRabbitMQURL)
+	}
+
+	return errs.err()
+}
+
+// amqpURL RabbitMQ 的连接地址
+func (e *errorList) amqpURL(key, s string) {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "amqp" && u.Scheme != "amqps") || u.Host == "" {
+		e.add("%s: must be an amqp:// or amqps:// URL", key)
+	}
+}
+
+// port 端口必须是 1-65535 的整数，required 为 false 时允许为空
+func (e *errorList) port(key, port string, required bool) {
+	if port == "" {
+		if required {
+			e.add("%s: required", key)
+		}
+		return
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		e.add("%s: invalid port %q", key, port)
+	}
+}
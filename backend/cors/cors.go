@@ -0,0 +1,170 @@
+// Package cors api 和 admin 共用的跨域中间件，按来源白名单决定是否允许跨域访问
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Policy 跨域策略。AllowedOrigins 中的来源可以是：
+//   - 完整的来源，如 https://tuna.example.com
+//   - 通配子域名，如 https://*.example.com，匹配任意层级的子域名，不匹配 example.com 本身
+//   - *，允许任意来源，不能和 AllowCredentials 同时使用
+//
+// AllowedOrigins 为空时不允许任何跨域访问，只能同源调用
+type Policy struct {
+	AllowedOrigins []string
+	// AllowedMethods、AllowedHeaders 预检请求返回的允许的方法和请求头
+	AllowedMethods []string
+	AllowedHeaders []string
+	// ExposedHeaders 允许前端读取的响应头
+	ExposedHeaders []string
+	// AllowCredentials 是否允许携带 Cookie 等凭证
+	AllowCredentials bool
+	// MaxAge 浏览器缓存预检结果的时长，为 0 时不返回 Access-Control-Max-Age
+	MaxAge time.Duration
+}
+
+// Validate 检查来源的格式
+func (p Policy) Validate() error {
+	var errs []error
+	for _, origin := range p.AllowedOrigins {
+		if origin == "*" {
+			if p.AllowCredentials {
+				errs = append(errs, errors.New(`"*" cannot be used with allow_credentials`))
+			}
+			continue
+		}
+		if _, err := parseOrigin(origin); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if p.MaxAge < 0 {
+		errs = append(errs, errors.New("max_age must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// originPattern 小写的 scheme://host[:port]，wildcard 为 true 时 host 为通配符后面的部分
+type originPattern struct {
+	scheme   string
+	host     string
+	wildcard bool
+}
+
+func parseOrigin(origin string) (originPattern, error) {
+	invalid := fmt.Errorf("invalid origin %q, expected scheme://host[:port] without path", origin)
+	scheme, host, ok := strings.Cut(strings.ToLower(origin), "://")
+	if !ok || (scheme != "http" && scheme != "https") {
+		return originPattern{}, invalid
+	}
+	p := originPattern{scheme: scheme, host: host}
+	if rest, ok := strings.CutPrefix(host, "*."); ok {
+		p.host, p.wildcard = rest, true
+	}
+	u, err := url.Parse(scheme + "://" + p.host)
+	if err != nil || u.Host != p.host || p.host == "" || strings.Contains(p.host, "*") {
+		return originPattern{}, invalid
+	}
+	return p, nil
+}
+
+func (p originPattern) match(scheme, host string) bool {
+	if scheme != p.scheme {
+		return false
+	}
+	if !p.wildcard {
+		return host == p.host
+	}
+	sub, ok := strings.CutSuffix(host, "."+p.host)
+	return ok && sub != "" && !strings.ContainsAny(sub, "/:@")
+}
+
+// Middleware 允许的来源在响应中带上 Access-Control-Allow-Origin 等头；不允许的来源不带，
+// 由浏览器拦截响应。预检请求直接返回 204，不进入后续的处理
+func Middleware(p Policy) gin.HandlerFunc {
+	var patterns []originPattern
+	anyOrigin := false
+	for _, origin := range p.AllowedOrigins {
+		if origin == "*" {
+			anyOrigin = true
+			continue
+		}
+		// 不合法的来源在加载配置时已经报错，这里直接跳过
+		if pattern, err := parseOrigin(origin); err == nil {
+			patterns = append(patterns, pattern)
+		}
+	}
+	allowed := func(origin string) bool {
+		if anyOrigin {
+			return true
+		}
+		scheme, host, ok := strings.Cut(strings.ToLower(origin), "://")
+		if !ok {
+			return false
+		}
+		for _, pattern := range patterns {
+			if pattern.match(scheme, host) {
+				return true
+			}
+		}
+		return false
+	}
+
+	methods := strings.Join(p.AllowedMethods, ", ")
+	headers := strings.Join(p.AllowedHeaders, ", ")
+	exposed := strings.Join(p.ExposedHeaders, ", ")
+	maxAge := ""
+	if p.MaxAge > 0 {
+		maxAge = strconv.Itoa(int(p.MaxAge.Seconds()))
+	}
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		h := c.Writer.Header()
+		// 响应随 Origin 变化，避免缓存把一个来源的响应返回给另一个来源
+		h.Add("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		if allowed(origin) {
+			if anyOrigin && !p.AllowCredentials {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if p.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if preflight {
+				if methods != "" {
+					h.Set("Access-Control-Allow-Methods", methods)
+				}
+				if headers != "" {
+					h.Set("Access-Control-Allow-Headers", headers)
+				}
+				if maxAge != "" {
+					h.Set("Access-Control-Max-Age", maxAge)
+				}
+			} else if exposed != "" {
+				h.Set("Access-Control-Expose-Headers", exposed)
+			}
+		}
+
+		if preflight {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,179 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serve 用 p 处理一个请求，preflight 为 true 时发送预检请求
+func serve(p Policy, method, origin string, preflight bool) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware(p))
+	router.Any("/api/submit", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(method, "/api/submit", nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	if preflight {
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAllowedOrigins(t *testing.T) {
+	p := Policy{AllowedOrigins: []string{"https://tuna.example.com", "https://*.example.org", "http://localhost:3001"}}
+	for _, tc := range []struct {
+		origin string
+		want   bool
+	}{
+		// 完整的来源需要 scheme、host 和端口都相同，host 不区分大小写
+		{"https://tuna.example.com", true},
+		{"https://TUNA.example.com", true},
+		{"http://tuna.example.com", false},
+		{"https://tuna.example.com:8443", false},
+		{"https://example.com", false},
+		{"http://localhost:3001", true},
+		{"http://localhost:3000", false},
+		// 通配子域名匹配任意层级，不匹配域名本身和只是后缀相同的域名
+		{"https://a.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"https://evil-example.org", false},
+		{"https://evilexample.org", false},
+		{"https://a.example.org.evil.com", false},
+		{"https://a.example.org:8443", false},
+		{"https://user@a.example.org", false},
+		{"http://a.example.org", false},
+		{"null", false},
+	} {
+		got := serve(p, http.MethodGet, tc.origin, false).Header().Get("Access-Control-Allow-Origin")
+		if allowed := got == tc.origin; allowed != tc.want || (!tc.want && got != "") {
+			t.Errorf("origin %q: Access-Control-Allow-Origin = %q, want allowed %v", tc.origin, got, tc.want)
+		}
+	}
+}
+
+func TestSimpleRequest(t *testing.T) {
+	p := Policy{
+		AllowedOrigins: []string{"https://tuna.example.com"},
+		ExposedHeaders: []string{"Retry-After", "X-Request-ID"},
+	}
+
+	w := serve(p, http.MethodPost, "https://tuna.example.com", false)
+	if w.Code != http.StatusOK {
+		t.Errorf("status %d, want the handler to run", w.Code)
+	}
+	h := w.Header()
+	if h.Get("Access-Control-Expose-Headers") != "Retry-After, X-Request-ID" || h.Get("Vary") != "Origin" {
+		t.Errorf("headers %v", h)
+	}
+	if h.Get("Access-Control-Allow-Credentials") != "" || h.Get("Access-Control-Allow-Methods") != "" {
+		t.Errorf("unexpected headers %v", h)
+	}
+
+	// 不允许的来源照常处理，只是不带跨域头，由浏览器拦截
+	w = serve(p, http.MethodPost, "https://evil.example.com", false)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Expose-Headers") != "" {
+		t.Errorf("disallowed origin: status %d, headers %v", w.Code, w.Header())
+	}
+
+	// 同源请求不带 Origin
+	w = serve(p, http.MethodPost, "", false)
+	if w.Header().Get("Vary") != "" || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("same-origin request: headers %v", w.Header())
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	p := Policy{
+		AllowedOrigins: []string{"https://tuna.example.com"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Content-Type", "Idempotency-Key"},
+		ExposedHeaders: []string{"Retry-After"},
+		MaxAge:         10 * time.Minute,
+	}
+
+	w := serve(p, http.MethodOptions, "https://tuna.example.com", true)
+	if w.Code != http.StatusNoContent {
+		t.Errorf("status %d, want 204 without running the handler", w.Code)
+	}
+	h := w.Header()
+	for name, want := range map[string]string{
+		"Access-Control-Allow-Origin":   "https://tuna.example.com",
+		"Access-Control-Allow-Methods":  "GET, POST",
+		"Access-Control-Allow-Headers":  "Content-Type, Idempotency-Key",
+		"Access-Control-Max-Age":        "600",
+		"Access-Control-Expose-Headers": "",
+	} {
+		if got := h.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	// 不允许的来源的预检同样直接返回，但不带任何允许的头
+	w = serve(p, http.MethodOptions, "https://evil.example.com", true)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "" ||
+		w.Header().Get("Access-Control-Allow-Methods") != "" {
+		t.Errorf("disallowed preflight: status %d, headers %v", w.Code, w.Header())
+	}
+
+	// 没有 Access-Control-Request-Method 的 OPTIONS 不是预检，交给路由处理
+	if w := serve(p, http.MethodOptions, "https://tuna.example.com", false); w.Code != http.StatusOK {
+		t.Errorf("plain OPTIONS: status %d, want the handler to run", w.Code)
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	// 不允许凭证时任意来源返回 *
+	w := serve(Policy{AllowedOrigins: []string{"*"}}, http.MethodGet, "https://any.example", false)
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("any origin: Access-Control-Allow-Origin = %q, want *", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("credentials off: Access-Control-Allow-Credentials = %q", got)
+	}
+
+	// 允许凭证时必须回显具体的来源
+	p := Policy{AllowedOrigins: []string{"https://*.example.com"}, AllowCredentials: true}
+	w = serve(p, http.MethodGet, "https://admin.example.com", false)
+	if w.Header().Get("Access-Control-Allow-Origin") != "https://admin.example.com" ||
+		w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("credentials on: headers %v", w.Header())
+	}
+	w = serve(p, http.MethodGet, "https://evil-example.com", false)
+	if w.Header().Get("Access-Control-Allow-Origin") != "" || w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("credentials sent to a disallowed origin: headers %v", w.Header())
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		p     Policy
+		valid bool
+	}{
+		{"exact", Policy{AllowedOrigins: []string{"https://tuna.example.com", "http://localhost:3001"}}, true},
+		{"wildcard", Policy{AllowedOrigins: []string{"https://*.example.com"}}, true},
+		{"any", Policy{AllowedOrigins: []string{"*"}}, true},
+		{"empty", Policy{}, true},
+		{"any with credentials", Policy{AllowedOrigins: []string{"*"}, AllowCredentials: true}, false},
+		{"no scheme", Policy{AllowedOrigins: []string{"tuna.example.com"}}, false},
+		{"ftp", Policy{AllowedOrigins: []string{"ftp://tuna.example.com"}}, false},
+		{"path", Policy{AllowedOrigins: []string{"https://tuna.example.com/"}}, false},
+		{"inner wildcard", Policy{AllowedOrigins: []string{"https://a.*.example.com"}}, false},
+		{"bare wildcard", Policy{AllowedOrigins: []string{"https://*"}}, false},
+		{"negative max age", Policy{MaxAge: -time.Second}, false},
+	} {
+		if err := tc.p.Validate(); (err == nil) != tc.valid {
+			t.Errorf("%s: Validate = %v, want valid %v", tc.name, err, tc.valid)
+		}
+	}
+}
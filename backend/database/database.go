@@ -6,15 +6,21 @@ import (
 	"tuna/config"
 
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/sirupsen/logrus"
 )
 
 var DB *sql.DB
 
 func InitDB(cfg *config.Config) error {
-	dsn := cfg.GetDSN()
-	fmt.Println("dsn", dsn)
+	// DSN 中带有密码，只记录连接的地址
+	logrus.WithFields(logrus.Fields{
+		"host":     cfg.DBHost,
+		"port":     cfg.DBPort,
+		"database": cfg.DBName,
+		"user":     cfg.DBUser,
+	}).Info("Connecting to database")
 	var err error
-	DB, err = sql.Open("mysql", dsn)
+	DB, err = sql.Open("mysql", cfg.GetDSN())
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
@@ -23,8 +29,10 @@ func InitDB(cfg *config.Config) error {
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	DB.SetMaxOpenConns(25)
-	DB.SetMaxIdleConns(5)
+	DB.SetMaxOpenConns(cfg.DBMaxOpenConns)
+	DB.SetMaxIdleConns(cfg.DBMaxIdleConns)
+	DB.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+	DB.SetConnMaxIdleTime(cfg.DBConnMaxIdleTime)
 
 	return nil
 }
@@ -0,0 +1,181 @@
+// Package eventbus 把领域事件发布到消息队列的 topic 交换机，并提供订阅这些事件的消费端。
+//
+// 发布经 outbox 进行：Sink 作为 outbox 的接收方，只有 broker 确认收到后投递才算成功，失败时由 outbox 重试，
+// 所以同一事件可能被发布多次（at-least-once），消费方需要按事件 ID（消息的 MessageID）去重。
+// 路由键为事件类型，如 submission.created，订阅时可以使用 topic 通配符，如 submission.*、#
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+	"tuna/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ContentType 事件消息体的格式
+const ContentType = "application/json"
+
+// Message 交换机上的一条消息
+type Message struct {
+	RoutingKey string
+	MessageID  string
+	// Type 消息类型，事件消息为事件类型
+	Type        string
+	ContentType string
+	Timestamp   time.Time
+	Body        []byte
+	// Attempt 第几次投递，从 1 开始，只在消费时有值
+	Attempt int
+}
+
+// Handler 处理一条消息，返回 nil 时确认。返回错误时消息在 ConsumeOptions.RetryDelay 之后重新投递，
+// 第 MaxAttempts 次投递仍失败时拒绝且不再入队，队列配置了死信交换机时转入死信队列
+type Handler func(ctx context.Context, msg Message) error
+
+// ConsumeOptions 消费端的队列配置
+type ConsumeOptions struct {
+	// Queue 队列名，不存在时创建为持久化队列；同名队列的多个消费者分摊消息
+	Queue string
+	// Bindings 绑定到交换机的路由键，可以使用 * 匹配一个单词、# 匹配零个或多个单词
+	Bindings []string
+	// Prefetch 未确认消息的上限，为 0 时使用 DefaultPrefetch
+	Prefetch int
+	// RetryDelay 处理失败后重新投递前的等待时间，为 0 时使用 DefaultRetryDelay
+	RetryDelay time.Duration
+	// MaxAttempts 每条消息最多投递的次数，为 0 时使用 DefaultMaxAttempts
+	MaxAttempts int
+}
+
+// 未配置时的消费端默认值
+const (
+	DefaultPrefetch    = 10
+	DefaultRetryDelay  = 10 * time.Second
+	DefaultMaxAttempts = 5
+)
+
+// withDefaults 返回零值字段填充默认值后的配置
+func (o ConsumeOptions) withDefaults() ConsumeOptions {
+	if o.Prefetch <= 0 {
+		o.Prefetch = DefaultPrefetch
+	}
+	if o.RetryDelay <= 0 {
+		o.RetryDelay = DefaultRetryDelay
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = DefaultMaxAttempts
+	}
+	return o
+}
+
+// Broker 消息队列。RabbitMQ 为生产实现，MemoryBroker 用于测试
+type Broker interface {
+	// Publish 发布到交换机，返回 nil 表示 broker 已确认收到
+	Publish(ctx context.Context, msg Message) error
+	// Consume 声明并绑定队列后阻塞消费，直到 ctx 取消或连接断开
+	Consume(ctx context.Context, opts ConsumeOptions, handler Handler) error
+	Close() error
+}
+
+// 消费端断开后重连的退避时间，从 subscribeBaseBackoff 开始每次翻倍，不超过 subscribeMaxBackoff；
+// 连接保持的时间超过当前退避时间后断开的，重新从 subscribeBaseBackoff 开始
+var (
+	subscribeBaseBackoff = time.Second
+	subscribeMaxBackoff  = 30 * time.Second
+)
+
+// SinkName outbox 投递记录中的接收方名称
+const SinkName = "eventbus"
+
+// Sink 实现 outbox.Sink，把事件发布到 broker
+type Sink struct {
+	broker Broker
+	events []string
+}
+
+// NewSink events 为发布的事件类型
+func NewSink(broker Broker, events []string) *Sink {
+	return &Sink{broker: broker, events: events}
+}
+
+func (s *Sink) Name() string {
+	return SinkName
+}
+
+func (s *Sink) Accepts(eventType string) bool {
+	return slices.Contains(s.events, eventType)
+}
+
+func (s *Sink) Deliver(ctx context.Context, event models.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return s.broker.Publish(ctx, Message{
+		RoutingKey:  event.Type,
+		MessageID:   event.ID,
+		Type:        event.Type,
+		ContentType: ContentType,
+		Timestamp:   event.OccurredAt,
+		Body:        body,
+	})
+}
+
+// Subscribe 供其他服务订阅事件：消费 opts 指定的队列，把消息解码为事件交给 fn，连接断开时按退避重连，直到 ctx 取消。
+// fn 返回错误时按 opts 的 RetryDelay、MaxAttempts 稍后重新投递；无法解码的消息记录日志后丢弃，避免反复投递
+func Subscribe(ctx context.Context, broker Broker, opts ConsumeOptions, fn func(ctx context.Context, event models.Event) error) error {
+	handler := func(ctx context.Context, msg Message) error {
+		var event models.Event
+		if err := json.Unmarshal(msg.Body, &event); err != nil {
+			logrus.WithError(err).WithField("message_id", msg.MessageID).Error("Dropping malformed event message")
+			return nil
+		}
+		return fn(ctx, event)
+	}
+
+	backoff := subscribeBaseBackoff
+	for {
+		started := time.Now()
+		err := broker.Consume(ctx, opts, handler)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if time.Since(started) > backoff {
+			backoff = subscribeBaseBackoff
+		}
+		logrus.WithError(err).WithField("queue", opts.Queue).Warn("Event consumer stopped, reconnecting")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, subscribeMaxBackoff)
+	}
+}
+
+// MatchTopic 按 topic 交换机的规则判断路由键是否匹配绑定：以 . 分隔单词，* 匹配一个单词，# 匹配零个或多个单词
+func MatchTopic(pattern, key string) bool {
+	return matchWords(strings.Split(pattern, "."), strings.Split(key, "."))
+}
+
+func matchWords(pattern, key []string) bool {
+	if len(pattern) == 0 {
+		return len(key) == 0
+	}
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(key); i++ {
+			if matchWords(pattern[1:], key[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(key) > 0 && matchWords(pattern[1:], key[1:])
+	default:
+		return len(key) > 0 && key[0] == pattern[0] && matchWords(pattern[1:], key[1:])
+	}
+}
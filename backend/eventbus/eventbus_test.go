@@ -0,0 +1,355 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+	"tuna/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	logrus.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		pattern, key string
+		want         bool
+	}{
+		{"submission.created", "submission.created", true},
+		{"submission.created", "submission.approved", false},
+		{"submission.*", "submission.created", true},
+		{"submission.*", "submission", false},
+		{"submission.*", "submission.created.v2", false},
+		{"*.created", "submission.created", true},
+		{"#", "submission.created", true},
+		{"#", "", true},
+		{"submission.#", "submission", true},
+		{"submission.#", "submission.created.v2", true},
+		{"#.created", "submission.created", true},
+		{"#.created", "submission.approved", false},
+		{"submission.#.v2", "submission.v2", true},
+		{"submission.#.v2", "submission.created.v2", true},
+		{"submission.#.v2", "submission.created.v3", false},
+		{"*", "", true},
+		{"*", "a.b", false},
+	}
+	for _, tt := range tests {
+		if got := MatchTopic(tt.pattern, tt.key); got != tt.want {
+			t.Errorf("MatchTopic(%q, %q) = %v, want %v", tt.pattern, tt.key, got, tt.want)
+		}
+	}
+}
+
+func testEvent(id, eventType string) models.Event {
+	return models.Event{
+		ID:         id,
+		Type:       eventType,
+		OccurredAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		Data:       models.SubmissionEvent{ID: 42, Name: "张三", Email: "zhangsan@example.com"},
+	}
+}
+
+func TestSinkDeliver(t *testing.T) {
+	broker := NewMemoryBroker()
+	sink := NewSink(broker, []string{models.EventSubmissionCreated, models.EventSubmissionApproved})
+
+	if !sink.Accepts(models.EventSubmissionCreated) || sink.Accepts(models.EventSubmissionRejected) {
+		t.Error("Accepts should only match the configured events")
+	}
+
+	event := testEvent("evt-1", models.EventSubmissionCreated)
+	if err := sink.Deliver(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	published := broker.Published()
+	if len(published) != 1 {
+		t.Fatalf("%d messages published, want 1", len(published))
+	}
+	msg := published[0]
+	if msg.RoutingKey != event.Type || msg.Type != event.Type || msg.MessageID != event.ID ||
+		msg.ContentType != ContentType || !msg.Timestamp.Equal(event.OccurredAt) {
+		t.Errorf("message = %+v", msg)
+	}
+	var decoded models.Event
+	if err := json.Unmarshal(msg.Body, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.ID != event.ID || decoded.Data.Name != "张三" {
+		t.Errorf("body = %+v", decoded)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sink.Deliver(ctx, event); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	broker.Close()
+	if err := sink.Deliver(context.Background(), event); err == nil {
+		t.Error("Deliver succeeded on a closed broker")
+	}
+}
+
+// subscribe 在后台运行 Subscribe，返回等待其退出的函数
+func subscribe(ctx context.Context, broker Broker, opts ConsumeOptions, fn func(ctx context.Context, event models.Event) error) func() error {
+	done := make(chan error, 1)
+	go func() { done <- Subscribe(ctx, broker, opts, fn) }()
+	return func() error {
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(5 * time.Second):
+			return errors.New("Subscribe did not return")
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	broker := NewMemoryBroker()
+	// 订阅前声明队列，之后发布的消息保留到消费者启动
+	broker.DeclareQueue("audit", "submission.*")
+	ctx := context.Background()
+	publish := func(msg Message) {
+		t.Helper()
+		if err := broker.Publish(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sink := NewSink(broker, models.EventTypes)
+	deliver := func(event models.Event) {
+		t.Helper()
+		if err := sink.Deliver(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deliver(testEvent("evt-1", models.EventSubmissionCreated))
+	// 无法解码的消息丢弃，不重新入队
+	publish(Message{RoutingKey: models.EventSubmissionCreated, MessageID: "bad", Body: []byte("not json")})
+	deliver(testEvent("evt-2", models.EventSubmissionApproved))
+	// 没有绑定匹配的消息直接丢弃
+	publish(Message{RoutingKey: "other.created", Body: []byte("{}")})
+
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	var handled []string
+	received := make(chan struct{}, 10)
+	ctx, cancel := context.WithCancel(ctx)
+	wait := subscribe(ctx, broker, ConsumeOptions{Queue: "audit", Bindings: []string{"submission.*"}, RetryDelay: time.Millisecond}, func(ctx context.Context, event models.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[event.ID]++
+		// 第一次处理失败，消息重新入队后再次投递
+		if event.ID == "evt-1" && attempts[event.ID] == 1 {
+			return errors.New("temporary failure")
+		}
+		handled = append(handled, event.ID)
+		received <- struct{}{}
+		return nil
+	})
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for events")
+		}
+	}
+	cancel()
+	if err := wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Subscribe returned %v, want context.Canceled", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if attempts["evt-1"] != 2 || attempts["evt-2"] != 1 {
+		t.Errorf("attempts = %v, want evt-1 retried once", attempts)
+	}
+	if len(handled) != 2 || handled[0] != "evt-2" || handled[1] != "evt-1" {
+		t.Errorf("handled = %v, want the failed event requeued after evt-2", handled)
+	}
+}
+
+func TestRetryDelayAndMaxAttempts(t *testing.T) {
+	broker := NewMemoryBroker()
+	broker.DeclareQueue("audit", "#")
+	var mu sync.Mutex
+	var attempts []int
+	var times []time.Time
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	opts := ConsumeOptions{Queue: "audit", Bindings: []string{"#"}, RetryDelay: 20 * time.Millisecond, MaxAttempts: 3}
+	go func() {
+		done <- broker.Consume(ctx, opts, func(ctx context.Context, msg Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			attempts = append(attempts, msg.Attempt)
+			times = append(times, time.Now())
+			return errors.New("poison message")
+		})
+	}()
+
+	if err := broker.Publish(ctx, Message{RoutingKey: "submission.created"}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("Consume returned %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// 失败的消息不会立即重新投递，第 MaxAttempts 次失败后丢弃
+	if len(attempts) != 3 || attempts[0] != 1 || attempts[2] != 3 {
+		t.Fatalf("attempts = %v, want 1, 2, 3", attempts)
+	}
+	for i := 1; i < len(times); i++ {
+		if gap := times[i].Sub(times[i-1]); gap < opts.RetryDelay {
+			t.Errorf("attempt %d redelivered after %v, want at least %v", i+1, gap, opts.RetryDelay)
+		}
+	}
+}
+
+// flakyBroker 第 n 次 Consume 保持 uptime(n) 后返回错误，模拟连接断开；uptime 为负数时正常消费
+type flakyBroker struct {
+	*MemoryBroker
+	uptime func(call int) time.Duration
+	mu     sync.Mutex
+	calls  []time.Time
+}
+
+// failFirst 前 n 次连接立即失败
+func failFirst(n int) func(int) time.Duration {
+	return func(call int) time.Duration {
+		if call <= n {
+			return 0
+		}
+		return -1
+	}
+}
+
+func (b *flakyBroker) Consume(ctx context.Context, opts ConsumeOptions, handler Handler) error {
+	b.mu.Lock()
+	b.calls = append(b.calls, time.Now())
+	uptime := b.uptime(len(b.calls))
+	b.mu.Unlock()
+	if uptime < 0 {
+		return b.MemoryBroker.Consume(ctx, opts, handler)
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(uptime):
+		return errors.New("connection reset")
+	}
+}
+
+// shortBackoff 测试期间缩短重连退避
+func shortBackoff(t *testing.T, base, max time.Duration) {
+	saved := [2]time.Duration{subscribeBaseBackoff, subscribeMaxBackoff}
+	subscribeBaseBackoff, subscribeMaxBackoff = base, max
+	t.Cleanup(func() { subscribeBaseBackoff, subscribeMaxBackoff = saved[0], saved[1] })
+}
+
+func TestSubscribeReconnects(t *testing.T) {
+	shortBackoff(t, 10*time.Millisecond, 40*time.Millisecond)
+
+	broker := &flakyBroker{MemoryBroker: NewMemoryBroker(), uptime: failFirst(4)}
+	broker.DeclareQueue("audit", "#")
+	received := make(chan string, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	wait := subscribe(ctx, broker, ConsumeOptions{Queue: "audit", Bindings: []string{"#"}}, func(ctx context.Context, event models.Event) error {
+		received <- event.ID
+		return nil
+	})
+
+	if err := NewSink(broker, models.EventTypes).Deliver(ctx, testEvent("evt-1", models.EventSubmissionCreated)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case id := <-received:
+		if id != "evt-1" {
+			t.Errorf("received %q", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the event after reconnecting")
+	}
+	cancel()
+	if err := wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Subscribe returned %v, want context.Canceled", err)
+	}
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	if len(broker.calls) != 5 {
+		t.Fatalf("%d Consume calls, want 5", len(broker.calls))
+	}
+	// 退避每次翻倍，到上限后不再增长
+	for i, want := range []time.Duration{10, 20, 40, 40} {
+		if gap := broker.calls[i+1].Sub(broker.calls[i]); gap < want*time.Millisecond {
+			t.Errorf("reconnect %d after %v, want at least %v", i+1, gap, want*time.Millisecond)
+		}
+	}
+}
+
+func TestSubscribeStopsWhenCanceledDuringBackoff(t *testing.T) {
+	broker := &flakyBroker{MemoryBroker: NewMemoryBroker(), uptime: failFirst(1)}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	// 默认退避为 1s，ctx 在退避期间取消
+	start := time.Now()
+	if err := subscribe(ctx, broker, ConsumeOptions{Queue: "audit"}, nil)(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Subscribe returned %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed >= subscribeBaseBackoff {
+		t.Errorf("Subscribe waited %v for the full backoff", elapsed)
+	}
+}
+
+func TestSubscribeResetsBackoffAfterLongSession(t *testing.T) {
+	shortBackoff(t, 10*time.Millisecond, 10*time.Second)
+
+	// 前 5 次立即失败，退避增长到 320ms；第 6 次连接保持 500ms 后断开
+	broker := &flakyBroker{MemoryBroker: NewMemoryBroker(), uptime: func(call int) time.Duration {
+		switch {
+		case call <= 5:
+			return 0
+		case call == 6:
+			return 500 * time.Millisecond
+		default:
+			return -1
+		}
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	wait := subscribe(ctx, broker, ConsumeOptions{Queue: "audit"}, nil)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		broker.mu.Lock()
+		n := len(broker.calls)
+		broker.mu.Unlock()
+		if n >= 7 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	wait()
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	if len(broker.calls) < 7 {
+		t.Fatalf("%d Consume calls, want 7", len(broker.calls))
+	}
+	session := 500 * time.Millisecond
+	if gap := broker.calls[6].Sub(broker.calls[5]) - session; gap > 200*time.Millisecond {
+		t.Errorf("reconnected %v after a long session, want the base backoff", gap)
+	}
+}
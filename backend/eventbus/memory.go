@@ -0,0 +1,153 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+var errBrokerClosed = errors.New("eventbus: broker closed")
+
+// MemoryBroker 进程内的 Broker，路由规则与 RabbitMQ 的 topic 交换机一致，用于在没有 RabbitMQ 的环境下测试。
+// 与 RabbitMQ 一样，发布时没有任何队列匹配的消息会被丢弃；队列在第一次 Consume 时创建，消费者退出后保留
+type MemoryBroker struct {
+	mu        sync.Mutex
+	queues    map[string]*memoryQueue
+	published []Message
+	closed    bool
+}
+
+type memoryQueue struct {
+	bindings []string
+	messages []Message
+	// ready 有新消息时关闭并替换，唤醒等待中的消费者
+	ready chan struct{}
+}
+
+// NewMemoryBroker 返回空的内存 broker
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{queues: make(map[string]*memoryQueue)}
+}
+
+// Published 按发布顺序返回全部已发布的消息
+func (b *MemoryBroker) Published() []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Message(nil), b.published...)
+}
+
+// DeclareQueue 创建队列并追加绑定，之后发布的匹配消息会保留到有消费者为止
+func (b *MemoryBroker) DeclareQueue(name string, bindings ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.declareLocked(name, bindings)
+}
+
+func (b *MemoryBroker) declareLocked(name string, bindings []string) *memoryQueue {
+	q, ok := b.queues[name]
+	if !ok {
+		q = &memoryQueue{ready: make(chan struct{})}
+		b.queues[name] = q
+	}
+	for _, key := range bindings {
+		if !slices.Contains(q.bindings, key) {
+			q.bindings = append(q.bindings, key)
+		}
+	}
+	return q
+}
+
+func (b *MemoryBroker) Publish(ctx context.Context, msg Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return errBrokerClosed
+	}
+	b.published = append(b.published, msg)
+	for _, q := range b.queues {
+		for _, key := range q.bindings {
+			if MatchTopic(key, msg.RoutingKey) {
+				q.push(msg)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func (q *memoryQueue) push(msg Message) {
+	q.messages = append(q.messages, msg)
+	close(q.ready)
+	q.ready = make(chan struct{})
+}
+
+// Consume 逐条交给 handler，handler 返回错误时消息在 RetryDelay 之后回到队尾，投递 MaxAttempts 次后丢弃
+func (b *MemoryBroker) Consume(ctx context.Context, opts ConsumeOptions, handler Handler) error {
+	opts = opts.withDefaults()
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return errBrokerClosed
+	}
+	q := b.declareLocked(opts.Queue, opts.Bindings)
+	b.mu.Unlock()
+
+	for {
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			return errBrokerClosed
+		}
+		if len(q.messages) == 0 {
+			ready := q.ready
+			b.mu.Unlock()
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ready:
+			}
+			continue
+		}
+		msg := q.messages[0]
+		q.messages = q.messages[1:]
+		b.mu.Unlock()
+
+		msg.Attempt++
+		if err := handler(ctx, msg); err != nil && msg.Attempt < opts.MaxAttempts {
+			b.retryLater(q, msg, opts.RetryDelay)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+func (b *MemoryBroker) retryLater(q *memoryQueue, msg Message, delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if !b.closed {
+			q.push(msg)
+		}
+	})
+}
+
+// Close 之后发布和消费都返回错误，等待中的消费者立即退出
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	for _, q := range b.queues {
+		close(q.ready)
+		q.ready = make(chan struct{})
+	}
+	return nil
+}
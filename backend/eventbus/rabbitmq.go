@@ -0,0 +1,256 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/sirupsen/logrus"
+)
+
+// dialTimeout 建立连接的超时时间，应小于 outbox 单次投递的超时时间
+const dialTimeout = 5 * time.Second
+
+// RetryQueueSuffix 处理失败的消息先进入 <队列名><RetryQueueSuffix> 等待 RetryDelay，到期后经死信路由回到原队列
+const RetryQueueSuffix = ".retry"
+
+// 重新投递时记录投递次数和原路由键的消息头，死信路由会把路由键改为队列名
+const (
+	attemptHeader    = "x-tuna-attempt"
+	routingKeyHeader = "x-tuna-routing-key"
+)
+
+// RabbitMQ 基于 RabbitMQ topic 交换机的 Broker。第一次使用时才建立连接，连接断开后下次使用时重连，
+// 所以 RabbitMQ 不可用时服务照常启动，事件留在 outbox 中等待重试
+type RabbitMQ struct {
+	url      string
+	exchange string
+
+	mu   sync.Mutex
+	conn *amqp.Connection
+	// pub 发布用的 channel，处于 confirm 模式
+	pub *amqp.Channel
+}
+
+// NewRabbitMQ exchange 为 topic 交换机的名称，不存在时创建为持久化交换机
+func NewRabbitMQ(url, exchange string) *RabbitMQ {
+	return &RabbitMQ{url: url, exchange: exchange}
+}
+
+// connection 返回可用的连接，需要时重新连接并声明交换机
+func (r *RabbitMQ) connection() (*amqp.Connection, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.connectionLocked()
+}
+
+func (r *RabbitMQ) connectionLocked() (*amqp.Connection, error) {
+	if r.conn != nil && !r.conn.IsClosed() {
+		return r.conn, nil
+	}
+	conn, err := amqp.DialConfig(r.url, amqp.Config{
+		Heartbeat: 10 * time.Second,
+		Dial:      amqp.DefaultDial(dialTimeout),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rabbitmq: dial: %w", err)
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	defer ch.Close()
+	if err := ch.ExchangeDeclare(r.exchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("rabbitmq: declare exchange %s: %w", r.exchange, err)
+	}
+	r.conn, r.pub = conn, nil
+	return conn, nil
+}
+
+// publisher 返回发布用的 channel
+func (r *RabbitMQ) publisher() (*amqp.Channel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	conn, err := r.connectionLocked()
+	if err != nil {
+		return nil, err
+	}
+	if r.pub != nil && !r.pub.IsClosed() {
+		return r.pub, nil
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, err
+	}
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("rabbitmq: enable publisher confirms: %w", err)
+	}
+	r.pub = ch
+	return ch, nil
+}
+
+// Publish 以持久化消息发布，等待 broker 的确认
+func (r *RabbitMQ) Publish(ctx context.Context, msg Message) error {
+	ch, err := r.publisher()
+	if err != nil {
+		return err
+	}
+	confirm, err := ch.PublishWithDeferredConfirmWithContext(ctx, r.exchange, msg.RoutingKey, false, false, amqp.Publishing{
+		MessageId:    msg.MessageID,
+		Type:         msg.Type,
+		ContentType:  msg.ContentType,
+		Timestamp:    msg.Timestamp,
+		DeliveryMode: amqp.Persistent,
+		Body:         msg.Body,
+	})
+	if err != nil {
+		return fmt.Errorf("rabbitmq: publish: %w", err)
+	}
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("rabbitmq: wait for confirm: %w", err)
+	}
+	if !acked {
+		return errors.New("rabbitmq: message was nacked by the broker")
+	}
+	return nil
+}
+
+func (r *RabbitMQ) Consume(ctx context.Context, opts ConsumeOptions, handler Handler) error {
+	conn, err := r.connection()
+	if err != nil {
+		return err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		return err
+	}
+	defer ch.Close()
+
+	opts = opts.withDefaults()
+	if err := ch.Qos(opts.Prefetch, 0, false); err != nil {
+		return err
+	}
+	// 重新投递的消息要等 broker 确认后才确认原消息
+	if err := ch.Confirm(false); err != nil {
+		return fmt.Errorf("rabbitmq: enable publisher confirms: %w", err)
+	}
+	if _, err := ch.QueueDeclare(opts.Queue, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("rabbitmq: declare queue %s: %w", opts.Queue, err)
+	}
+	retryQueue := opts.Queue + RetryQueueSuffix
+	if _, err := ch.QueueDeclare(retryQueue, true, false, false, false, amqp.Table{
+		"x-dead-letter-exchange":    "",
+		"x-dead-letter-routing-key": opts.Queue,
+	}); err != nil {
+		return fmt.Errorf("rabbitmq: declare queue %s: %w", retryQueue, err)
+	}
+	for _, key := range opts.Bindings {
+		if err := ch.QueueBind(opts.Queue, key, r.exchange, false, nil); err != nil {
+			return fmt.Errorf("rabbitmq: bind queue %s to %s: %w", opts.Queue, key, err)
+		}
+	}
+	deliveries, err := ch.ConsumeWithContext(ctx, opts.Queue, "", false, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("rabbitmq: consume %s: %w", opts.Queue, err)
+	}
+
+	for d := range deliveries {
+		msg := Message{
+			RoutingKey:  d.RoutingKey,
+			MessageID:   d.MessageId,
+			Type:        d.Type,
+			ContentType: d.ContentType,
+			Timestamp:   d.Timestamp,
+			Body:        d.Body,
+			Attempt:     attempt(d.Headers),
+		}
+		if key, ok := d.Headers[routingKeyHeader].(string); ok {
+			msg.RoutingKey = key
+		}
+
+		err := handler(ctx, msg)
+		switch {
+		case err == nil:
+			err = d.Ack(false)
+		case msg.Attempt >= opts.MaxAttempts:
+			logrus.WithError(err).WithFields(logrus.Fields{
+				"queue":      opts.Queue,
+				"message_id": msg.MessageID,
+				"attempts":   msg.Attempt,
+			}).Error("Rejecting message after the last delivery attempt")
+			err = d.Nack(false, false)
+		default:
+			err = r.retryLater(ctx, ch, retryQueue, d, msg, opts.RetryDelay)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return errors.New("rabbitmq: consumer channel closed")
+}
+
+// retryLater 把消息复制到重试队列，过期后回到原队列，确认收到后再确认原消息
+func (r *RabbitMQ) retryLater(ctx context.Context, ch *amqp.Channel, retryQueue string, d amqp.Delivery, msg Message, delay time.Duration) error {
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers[attemptHeader] = int32(msg.Attempt + 1)
+	headers[routingKeyHeader] = msg.RoutingKey
+	confirm, err := ch.PublishWithDeferredConfirmWithContext(ctx, "", retryQueue, false, false, amqp.Publishing{
+		Headers:      headers,
+		MessageId:    msg.MessageID,
+		Type:         msg.Type,
+		ContentType:  msg.ContentType,
+		Timestamp:    msg.Timestamp,
+		DeliveryMode: amqp.Persistent,
+		Expiration:   strconv.FormatInt(delay.Milliseconds(), 10),
+		Body:         msg.Body,
+	})
+	if err != nil {
+		return fmt.Errorf("rabbitmq: publish retry: %w", err)
+	}
+	// 出错时不确认原消息，channel 关闭后由 broker 重新投递
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("rabbitmq: wait for retry confirm: %w", err)
+	}
+	if !acked {
+		return errors.New("rabbitmq: retry was nacked by the broker")
+	}
+	return d.Ack(false)
+}
+
+// attempt 从消息头取出第几次投递，第一次投递没有该消息头
+func attempt(headers amqp.Table) int {
+	switch n := headers[attemptHeader].(type) {
+	case int32:
+		return int(n)
+	case int64:
+		return int(n)
+	}
+	return 1
+}
+
+// Close 关闭连接，之后再使用会重新连接
+func (r *RabbitMQ) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.conn == nil || r.conn.IsClosed() {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn, r.pub = nil, nil
+	return err
+}
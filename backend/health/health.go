@@ -0,0 +1,222 @@
+// Package health api 和 admin 的存活检查（/healthz）和就绪检查（/readyz）
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+	"tuna/logging"
+	"tuna/migrate"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultTimeout 未配置时就绪检查中数据库查询的超时时间
+	DefaultTimeout = 2 * time.Second
+	// poolSaturatedUsage 使用中的连接占上限的比例达到该值时报告连接池饱和
+	poolSaturatedUsage = 0.9
+)
+
+// Pinger 检查数据库连接和连接池，由 *sql.DB 实现
+type Pinger interface {
+	PingContext(ctx context.Context) error
+	Stats() sql.DBStats
+}
+
+// SchemaChecker 查询数据库结构版本，由 *migrate.Migrator 实现
+type SchemaChecker interface {
+	Latest() int64
+	Status(ctx context.Context) ([]migrate.Status, error)
+}
+
+// Options 就绪检查的依赖，DB 为 nil 时跳过数据库相关的检查
+type Options struct {
+	DB Pinger
+	// Migrator 为 nil 时不检查数据库结构版本
+	Migrator SchemaChecker
+	// RequireSchemaCurrent 为 true 时数据库结构落后于代码视为未就绪，否则只报告
+	RequireSchemaCurrent bool
+	// Timeout 每项数据库检查的超时时间，为 0 时使用 DefaultTimeout
+	Timeout time.Duration
+}
+
+// Checker 检查服务是否可以接收流量。优雅退出开始后调用 StartDraining，/readyz 随即返回 503，
+// 让负载均衡在连接关闭前摘掉该实例
+type Checker struct {
+	opts     Options
+	draining atomic.Bool
+}
+
+func New(opts Options) *Checker {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	return &Checker{opts: opts}
+}
+
+// StartDraining 标记服务正在退出
+func (c *Checker) StartDraining() {
+	c.draining.Store(true)
+}
+
+// Draining 是否正在退出
+func (c *Checker) Draining() bool {
+	return c.draining.Load()
+}
+
+// Check 单项检查的结果，Status 为 ok 以外的值时 Error 说明原因。
+// Error 可能包含数据库地址等内部信息，不出现在 /readyz 的响应中，只写入日志
+type Check struct {
+	Status    string   `json:"status"`
+	Error     string   `json:"-"`
+	LatencyMS *float64 `json:"latency_ms,omitempty"`
+
+	// 连接池
+	Open      *int     `json:"open,omitempty"`
+	InUse     *int     `json:"in_use,omitempty"`
+	Idle      *int     `json:"idle,omitempty"`
+	MaxOpen   *int     `json:"max_open,omitempty"`
+	Usage     *float64 `json:"usage,omitempty"`
+	WaitCount *int64   `json:"wait_count,omitempty"`
+
+	// 数据库结构：已执行的最新迁移版本和代码中的最新版本
+	Applied *int64 `json:"applied,omitempty"`
+	Latest  *int64 `json:"latest,omitempty"`
+}
+
+// Report /readyz 的响应体
+type Report struct {
+	Status   string           `json:"status"`
+	Draining bool             `json:"draining"`
+	Checks   map[string]Check `json:"checks"`
+}
+
+// LiveResponse 存活检查的响应体
+type LiveResponse struct {
+	Status string `json:"status"`
+}
+
+// Live 进程能处理请求即返回 200，不检查依赖
+func (c *Checker) Live(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, LiveResponse{Status: "ok"})
+}
+
+// Ready 依赖可用且没有在退出时返回 200，否则返回 503。
+// 两个端口都对外开放，响应中只有各项检查的状态，失败原因写入日志
+func (c *Checker) Ready(ctx *gin.Context) {
+	report := c.Report(ctx.Request.Context())
+	status := http.StatusOK
+	if report.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+
+	for name, check := range report.Checks {
+		if check.Error != "" {
+			logging.FromContext(ctx.Request.Context()).WithFields(logrus.Fields{
+				"check":  name,
+				"status": check.Status,
+				"error":  check.Error,
+			}).Warn("Readiness check failed")
+		}
+	}
+	ctx.JSON(status, report)
+}
+
+// Report 执行全部检查。连接池饱和只报告，不影响就绪状态，避免高峰期所有实例同时被摘掉
+func (c *Checker) Report(ctx context.Context) Report {
+	report := Report{Status: "ok", Draining: c.Draining(), Checks: map[string]Check{}}
+	if report.Draining {
+		report.Status = "unavailable"
+	}
+	if c.opts.DB == nil {
+		return report
+	}
+
+	db := c.checkDatabase(ctx)
+	report.Checks["database"] = db
+	report.Checks["pool"] = c.checkPool()
+	if db.Status != "ok" {
+		report.Status = "unavailable"
+	}
+
+	if c.opts.Migrator != nil {
+		schema := c.checkSchema(ctx)
+		report.Checks["schema"] = schema
+		if schema.Status != "ok" && c.opts.RequireSchemaCurrent {
+			report.Status = "unavailable"
+		}
+	}
+	return report
+}
+
+func (c *Checker) checkDatabase(ctx context.Context) Check {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.opts.DB.PingContext(ctx)
+	latency := float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		return Check{Status: "error", Error: err.Error(), LatencyMS: &latency}
+	}
+	return Check{Status: "ok", LatencyMS: &latency}
+}
+
+func (c *Checker) checkPool() Check {
+	stats := c.opts.DB.Stats()
+	check := Check{
+		Status:    "ok",
+		Open:      &stats.OpenConnections,
+		InUse:     &stats.InUse,
+		Idle:      &stats.Idle,
+		MaxOpen:   &stats.MaxOpenConnections,
+		WaitCount: &stats.WaitCount,
+	}
+	// MaxOpenConnections 为 0 表示不限制，此时不计算使用率
+	if stats.MaxOpenConnections > 0 {
+		usage := float64(stats.InUse) / float64(stats.MaxOpenConnections)
+		check.Usage = &usage
+		if usage >= poolSaturatedUsage {
+			check.Status = "saturated"
+		}
+	}
+	return check
+}
+
+func (c *Checker) checkSchema(ctx context.Context) Check {
+	ctx, cancel := context.WithTimeout(ctx, c.opts.Timeout)
+	defer cancel()
+
+	latest := c.opts.Migrator.Latest()
+	check := Check{Status: "ok", Latest: &latest}
+	statuses, err := c.opts.Migrator.Status(ctx)
+	if err == nil {
+		var applied int64
+		for _, s := range statuses {
+			if s.Applied {
+				applied = max(applied, s.Version)
+			}
+		}
+		check.Applied = &applied
+		err = migrate.CheckStatus(statuses)
+	}
+	if err != nil {
+		check.Error = err.Error()
+		switch {
+		case errors.Is(err, migrate.ErrSchemaBehind):
+			check.Status = "behind"
+		case errors.Is(err, migrate.ErrDirty):
+			check.Status = "dirty"
+		case errors.Is(err, migrate.ErrChecksumMismatch):
+			check.Status = "checksum_mismatch"
+		default:
+			check.Status = "error"
+		}
+	}
+	return check
+}
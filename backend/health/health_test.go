@@ -0,0 +1,192 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"tuna/migrate"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	logrus.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+type stubPinger struct {
+	err   error
+	stats sql.DBStats
+}
+
+func (p stubPinger) PingContext(ctx context.Context) error { return p.err }
+func (p stubPinger) Stats() sql.DBStats                    { return p.stats }
+
+type stubSchema struct {
+	latest   int64
+	statuses []migrate.Status
+	err      error
+}
+
+func (s stubSchema) Latest() int64 { return s.latest }
+func (s stubSchema) Status(ctx context.Context) ([]migrate.Status, error) {
+	return s.statuses, s.err
+}
+
+// schemaAt 共有 latest 个迁移，已执行到 applied
+func schemaAt(applied, latest int64) stubSchema {
+	s := stubSchema{latest: latest}
+	for v := int64(1); v <= latest; v++ {
+		s.statuses = append(s.statuses, migrate.Status{Version: v, Applied: v <= applied})
+	}
+	return s
+}
+
+// ready 请求 /readyz，返回状态码、解码后的报告和原始响应体
+func ready(t *testing.T, c *Checker) (int, Report, string) {
+	t.Helper()
+	router := gin.New()
+	router.GET("/readyz", c.Ready)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var report Report
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode %s: %v", w.Body.String(), err)
+	}
+	return w.Code, report, w.Body.String()
+}
+
+func TestLive(t *testing.T) {
+	router := gin.New()
+	c := New(Options{DB: stubPinger{err: errors.New("down")}})
+	router.GET("/healthz", c.Live)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	// 存活检查不依赖数据库
+	if w.Code != http.StatusOK {
+		t.Errorf("status %d, want 200", w.Code)
+	}
+}
+
+func TestReady(t *testing.T) {
+	c := New(Options{
+		DB:       stubPinger{stats: sql.DBStats{MaxOpenConnections: 10, OpenConnections: 3, InUse: 1, Idle: 2}},
+		Migrator: schemaAt(3, 3),
+	})
+	code, report, _ := ready(t, c)
+	if code != http.StatusOK || report.Status != "ok" || report.Draining {
+		t.Fatalf("status %d, report %+v", code, report)
+	}
+	for _, name := range []string{"database", "pool", "schema"} {
+		if report.Checks[name].Status != "ok" {
+			t.Errorf("%s: status %q, want ok", name, report.Checks[name].Status)
+		}
+	}
+	pool := report.Checks["pool"]
+	if pool.InUse == nil || *pool.InUse != 1 || pool.Usage == nil || *pool.Usage != 0.1 {
+		t.Errorf("pool %+v", pool)
+	}
+	schema := report.Checks["schema"]
+	if schema.Applied == nil || *schema.Applied != 3 || schema.Latest == nil || *schema.Latest != 3 {
+		t.Errorf("schema %+v", schema)
+	}
+}
+
+func TestDraining(t *testing.T) {
+	for _, opts := range []Options{{}, {DB: stubPinger{}}} {
+		c := New(opts)
+		if code, _, _ := ready(t, c); code != http.StatusOK {
+			t.Fatalf("before draining: status %d", code)
+		}
+		c.StartDraining()
+		code, report, _ := ready(t, c)
+		if code != http.StatusServiceUnavailable || report.Status != "unavailable" || !report.Draining {
+			t.Errorf("draining: status %d, report %+v", code, report)
+		}
+	}
+}
+
+func TestDatabaseDown(t *testing.T) {
+	c := New(Options{DB: stubPinger{err: errors.New("dial tcp 10.0.0.5:3306: connect: connection refused")}})
+	code, report, body := ready(t, c)
+	if code != http.StatusServiceUnavailable || report.Checks["database"].Status != "error" {
+		t.Errorf("status %d, report %+v", code, report)
+	}
+	// 失败原因只写日志，不出现在对外的响应中
+	if strings.Contains(body, "10.0.0.5") || strings.Contains(body, "refused") {
+		t.Errorf("response leaks the error: %s", body)
+	}
+}
+
+func TestPoolSaturated(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		stats sql.DBStats
+		want  string
+	}{
+		{"below threshold", sql.DBStats{MaxOpenConnections: 10, InUse: 8}, "ok"},
+		{"saturated", sql.DBStats{MaxOpenConnections: 10, InUse: 9, WaitCount: 42}, "saturated"},
+		{"unlimited", sql.DBStats{InUse: 100}, "ok"},
+	} {
+		code, report, _ := ready(t, New(Options{DB: stubPinger{stats: tc.stats}}))
+		// 连接池饱和只报告，不影响就绪状态
+		if code != http.StatusOK {
+			t.Errorf("%s: status %d, want 200", tc.name, code)
+		}
+		if got := report.Checks["pool"].Status; got != tc.want {
+			t.Errorf("%s: pool status %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	dirty := schemaAt(2, 3)
+	dirty.statuses[1].Dirty = true
+	modified := schemaAt(3, 3)
+	modified.statuses[0].ChecksumMismatch = true
+
+	for _, tc := range []struct {
+		name   string
+		schema stubSchema
+		want   string
+	}{
+		{"current", schemaAt(3, 3), "ok"},
+		{"behind", schemaAt(2, 3), "behind"},
+		{"dirty", dirty, "dirty"},
+		{"modified", modified, "checksum_mismatch"},
+		{"query failed", stubSchema{latest: 3, err: errors.New("Error 1045: Access denied for user 'tuna'")}, "error"},
+	} {
+		for _, require := range []bool{false, true} {
+			c := New(Options{DB: stubPinger{}, Migrator: tc.schema, RequireSchemaCurrent: require})
+			code, report, body := ready(t, c)
+			if got := report.Checks["schema"].Status; got != tc.want {
+				t.Errorf("%s: schema status %q, want %q", tc.name, got, tc.want)
+			}
+			// 只有 RequireSchemaCurrent 时结构版本影响就绪状态
+			want := http.StatusOK
+			if require && tc.want != "ok" {
+				want = http.StatusServiceUnavailable
+			}
+			if code != want {
+				t.Errorf("%s, require %v: status %d, want %d", tc.name, require, code, want)
+			}
+			if strings.Contains(body, "Access denied") || strings.Contains(body, "pending versions") {
+				t.Errorf("%s: response leaks the error: %s", tc.name, body)
+			}
+		}
+	}
+
+	_, report, _ := ready(t, New(Options{DB: stubPinger{}, Migrator: schemaAt(2, 3)}))
+	if s := report.Checks["schema"]; s.Applied == nil || *s.Applied != 2 || *s.Latest != 3 {
+		t.Errorf("behind: schema %+v, want applied 2 of 3", s)
+	}
+}
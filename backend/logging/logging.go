@@ -0,0 +1,124 @@
+// Package logging api 和 admin 共用的结构化日志：JSON 格式输出、X-Request-ID 关联和访问日志
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Options 日志配置，零值表示 info 级别输出到标准输出
+type Options struct {
+	// Level 日志级别：debug、info、warn、error
+	Level string
+	// Output stdout、stderr 或文件路径，文件以追加方式打开
+	Output string
+	// AccessLevel 访问日志的级别，5xx 响应始终按 error 记录
+	AccessLevel string
+}
+
+var accessLevel atomic.Uint32
+
+func init() {
+	accessLevel.Store(uint32(logrus.InfoLevel))
+	logrus.SetFormatter(&logrus.JSONFormatter{})
+}
+
+// Setup 配置全局的 logrus 日志，并把标准库 log 的输出也转为 JSON 格式
+func Setup(opts Options) error {
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return err
+	}
+	access, err := ParseLevel(opts.AccessLevel)
+	if err != nil {
+		return fmt.Errorf("access log: %w", err)
+	}
+	out, err := openOutput(opts.Output)
+	if err != nil {
+		return err
+	}
+
+	logrus.SetOutput(out)
+	logrus.SetLevel(level)
+	accessLevel.Store(uint32(access))
+
+	log.SetFlags(0)
+	log.SetOutput(logrus.StandardLogger().WriterLevel(logrus.InfoLevel))
+	return nil
+}
+
+// SetLevel 运行中调整日志级别
+func SetLevel(level string) error {
+	l, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	logrus.SetLevel(l)
+	return nil
+}
+
+// SetAccessLevel 运行中调整访问日志的级别
+func SetAccessLevel(level string) error {
+	l, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	accessLevel.Store(uint32(l))
+	return nil
+}
+
+// ParseLevel 解析日志级别，空字符串视为 info
+func ParseLevel(level string) (logrus.Level, error) {
+	if strings.TrimSpace(level) == "" {
+		return logrus.InfoLevel, nil
+	}
+	l, err := logrus.ParseLevel(strings.TrimSpace(level))
+	if err != nil {
+		return 0, fmt.Errorf("invalid log level %q", level)
+	}
+	return l, nil
+}
+
+func openOutput(output string) (io.Writer, error) {
+	switch strings.TrimSpace(output) {
+	case "", "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	default:
+		f, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("open log file: %w", err)
+		}
+		return f, nil
+	}
+}
+
+type requestIDKey struct{}
+
+// WithRequestID 把请求 ID 放入 context，仓库层等下游通过 FromContext 取得带请求 ID 的日志
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID 返回 context 中的请求 ID，没有时返回空字符串
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// FromContext 返回带上 request_id 字段的日志
+func FromContext(ctx context.Context) *logrus.Entry {
+	entry := logrus.NewEntry(logrus.StandardLogger())
+	if id := RequestID(ctx); id != "" {
+		entry = entry.WithField("request_id", id)
+	}
+	return entry
+}
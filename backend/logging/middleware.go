@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// RequestIDHeader 请求和响应中携带请求 ID 的头
+	RequestIDHeader = "X-Request-ID"
+
+	maxRequestIDLen = 128
+	userIDKey       = "logging_user_id"
+)
+
+// Middleware 沿用客户端传入的 X-Request-ID，没有或不合法时生成一个，写入响应头和请求的 context；
+// 请求结束后输出一条访问日志。需要放在其他中间件之前，这样被中断的请求也有访问日志
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), id))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		// 只记录路由模板，不记录实际路径和查询参数，避免查询凭证等敏感信息进入日志
+		fields := logrus.Fields{
+			"request_id": id,
+			"method":     c.Request.Method,
+			"route":      c.FullPath(),
+			"status":     status,
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"client_ip":  c.ClientIP(),
+			"bytes":      max(c.Writer.Size(), 0),
+		}
+		if userID, ok := c.Get(userIDKey); ok {
+			fields["user_id"] = userID
+		}
+		if len(c.Errors) > 0 {
+			fields["errors"] = c.Errors.String()
+		}
+
+		level := logrus.Level(accessLevel.Load())
+		if status >= 500 {
+			level = logrus.ErrorLevel
+		}
+		logrus.WithFields(fields).Log(level, "access")
+	}
+}
+
+// SetUserID 记录当前请求的用户，写入访问日志的 user_id 字段
+func SetUserID(c *gin.Context, id any) {
+	c.Set(userIDKey, id)
+}
+
+// validRequestID 只接受长度有限的可见 ASCII 字符，防止日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,151 @@
+// Package metrics api 和 admin 的 Prometheus 指标：HTTP 请求、数据库连接池和审核业务指标
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+// Path 指标的访问路径
+const Path = "/metrics"
+
+// Options 指标配置
+type Options struct {
+	// Enabled 为 false 时不统计请求，也不暴露指标
+	Enabled bool
+	// Port 单独的指标监听端口，为空时 /metrics 挂在服务本身的端口上
+	Port string
+}
+
+// Public 是否在服务本身的路由上暴露 /metrics
+func (o Options) Public() bool {
+	return o.Enabled && o.Port == ""
+}
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tuna_http_requests_total",
+		Help: "HTTP requests by service, method, route template and status.",
+	}, []string{"service", "method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "tuna_http_request_duration_seconds",
+		Help:    "HTTP request latency by service, method, route template and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"service", "method", "route", "status"})
+
+	submissionsCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tuna_submissions_created_total",
+		Help: "Submissions saved, by source (api, import) and result (created, merged).",
+	}, []string{"source", "result"})
+
+	reviewDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tuna_review_decisions_total",
+		Help: "Review decisions by decision (approved, rejected).",
+	}, []string{"decision"})
+
+	outboxDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tuna_outbox_deliveries_total",
+		Help: "Outbox delivery attempts by sink and result (delivered, retry, failed).",
+	}, []string{"sink", "result"})
+
+	pendingDesc = prometheus.NewDesc(
+		"tuna_pending_submissions",
+		"Submissions waiting for review, counted at scrape time.",
+		nil, nil,
+	)
+)
+
+// Middleware 统计请求数和耗时。route 使用路由模板，未匹配到路由的请求记为 unmatched，避免标签基数无限增长
+func Middleware(service string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		labels := prometheus.Labels{
+			"service": service,
+			"method":  c.Request.Method,
+			"route":   route,
+			"status":  strconv.Itoa(c.Writer.Status()),
+		}
+		httpRequests.With(labels).Inc()
+		httpDuration.With(labels).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler 输出默认注册表中的指标
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// NewServer 单独监听 port 的指标服务，只提供 /metrics
+func NewServer(port string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle(Path, Handler())
+	return &http.Server{Addr: ":" + port, Handler: mux}
+}
+
+// RegisterDB 注册数据库连接池指标（go_sql_*），数据来自 db.Stats()
+func RegisterDB(db *sql.DB, name string) error {
+	return prometheus.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterPendingBacklog 注册待审核数量指标，每次抓取时调用 count 查询
+func RegisterPendingBacklog(count func(ctx context.Context) (int64, error)) error {
+	return prometheus.Register(pendingCollector{count: count})
+}
+
+// SubmissionCreated 记录一条保存成功的提交，source 为 api 或 import
+func SubmissionCreated(source string, merged bool) {
+	result := "created"
+	if merged {
+		result = "merged"
+	}
+	submissionsCreated.WithLabelValues(source, result).Inc()
+}
+
+// ReviewDecision 记录一次审核通过或拒绝，reopen 等其他状态变更不计入
+func ReviewDecision(status string) {
+	if status == "approved" || status == "rejected" {
+		reviewDecisions.WithLabelValues(status).Inc()
+	}
+}
+
+// OutboxDelivery 记录一次事件投递尝试，result 为 delivered、retry 或 failed
+func OutboxDelivery(sink, result string) {
+	outboxDeliveries.WithLabelValues(sink, result).Inc()
+}
+
+// pendingCollector 查询失败时跳过该指标并写日志，不影响其他指标的抓取
+type pendingCollector struct {
+	count func(ctx context.Context) (int64, error)
+}
+
+func (p pendingCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pendingDesc
+}
+
+func (p pendingCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	n, err := p.count(ctx)
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to count pending submissions for metrics")
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(pendingDesc, prometheus.GaugeValue, float64(n))
+}
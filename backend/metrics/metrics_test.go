@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddlewareLabels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware("test"))
+	router.GET("/users/:id", func(c *gin.Context) {
+		if c.Param("id") == "0" {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	for _, path := range []string{"/users/1", "/users/2", "/users/0", "/no/such/route", "/another/route"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// 路径参数不进入标签，未匹配的路由合并为 unmatched
+	for _, tc := range []struct {
+		route, status string
+		want          float64
+	}{
+		{"/users/:id", "200", 2},
+		{"/users/:id", "404", 1},
+		{"unmatched", "404", 2},
+	} {
+		got := testutil.ToFloat64(httpRequests.WithLabelValues("test", http.MethodGet, tc.route, tc.status))
+		if got != tc.want {
+			t.Errorf("requests{route=%q, status=%q} = %v, want %v", tc.route, tc.status, got, tc.want)
+		}
+	}
+	if n := testutil.CollectAndCount(httpDuration, "tuna_http_request_duration_seconds"); n < 3 {
+		t.Errorf("%d duration series, want at least 3", n)
+	}
+}
+
+func TestOptionsPublic(t *testing.T) {
+	for _, tc := range []struct {
+		opts Options
+		want bool
+	}{
+		{Options{Enabled: true}, true},
+		{Options{Enabled: true, Port: "9812"}, false},
+		{Options{Enabled: false}, false},
+	} {
+		if got := tc.opts.Public(); got != tc.want {
+			t.Errorf("%+v.Public() = %v, want %v", tc.opts, got, tc.want)
+		}
+	}
+}
+
+func TestNewServer(t *testing.T) {
+	handler := NewServer("9812").Handler
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, Path, nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "tuna_http_requests_total") {
+		t.Errorf("GET %s = %d, want the metrics", Path, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/submit", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /api/submit = %d, want 404", w.Code)
+	}
+}
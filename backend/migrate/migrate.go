@@ -0,0 +1,420 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// lockName 同一时间只允许一个进程执行迁移
+const lockName = "tuna_schema_migrations"
+
+var (
+	// ErrSchemaBehind 数据库中还有未执行的迁移
+	ErrSchemaBehind = errors.New("database schema is behind")
+	// ErrDirty 某个迁移执行到一半失败，需要人工处理后再继续
+	ErrDirty = errors.New("database schema is dirty")
+	// ErrChecksumMismatch 已执行迁移的内容在代码中被修改过
+	ErrChecksumMismatch = errors.New("migration checksum mismatch")
+)
+
+// Migration 一个版本的迁移，文件名格式为 <version>_<name>.up.sql 和 <version>_<name>.down.sql
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // up 脚本的 SHA-256
+}
+
+// Status 单个迁移的执行情况
+type Status struct {
+	Version          int64      `json:"version"`
+	Name             string     `json:"name"`
+	Applied          bool       `json:"applied"`
+	Dirty            bool       `json:"dirty,omitempty"`
+	ChecksumMismatch bool       `json:"checksum_mismatch,omitempty"`
+	AppliedAt        *time.Time `json:"applied_at,omitempty"`
+}
+
+type appliedRecord struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	Dirty     bool
+	AppliedAt time.Time
+}
+
+// Migrator 在 schema_migrations 表中记录已执行的迁移
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// New 使用编译进二进制的迁移文件
+func New(db *sql.DB) (*Migrator, error) {
+	migrations, err := load(migrationFS, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// Migrations 按版本升序返回全部迁移
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Latest 返回代码中最新的迁移版本
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status 返回每个迁移的执行情况，数据库中存在而代码中没有的版本也会列出
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	known := make(map[int64]bool, len(m.migrations))
+	for _, mig := range m.migrations {
+		known[mig.Version] = true
+		s := Status{Version: mig.Version, Name: mig.Name}
+		if rec, ok := applied[mig.Version]; ok {
+			appliedAt := rec.AppliedAt
+			s.Applied = true
+			s.Dirty = rec.Dirty
+			s.ChecksumMismatch = rec.Checksum != mig.Checksum
+			s.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, s)
+	}
+	for version, rec := range applied {
+		if known[version] {
+			continue
+		}
+		appliedAt := rec.AppliedAt
+		statuses = append(statuses, Status{
+			Version:   version,
+			Name:      rec.Name,
+			Applied:   true,
+			Dirty:     rec.Dirty,
+			AppliedAt: &appliedAt,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Check 在数据库结构落后于代码、存在失败的迁移或已执行的迁移被修改时返回错误，
+// 供 api/admin 启动时拒绝在旧结构上运行
+func (m *Migrator) Check(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	return CheckStatus(statuses)
+}
+
+// CheckStatus 按 Status 的结果做与 Check 相同的判断
+func CheckStatus(statuses []Status) error {
+	var pending []string
+	for _, s := range statuses {
+		switch {
+		case s.Dirty:
+			return fmt.Errorf("%w: version %d", ErrDirty, s.Version)
+		case s.ChecksumMismatch:
+			return fmt.Errorf("%w: version %d", ErrChecksumMismatch, s.Version)
+		case !s.Applied:
+			pending = append(pending, strconv.FormatInt(s.Version, 10))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: pending versions %s", ErrSchemaBehind, strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// Up 执行全部未执行的迁移
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.To(ctx, m.Latest())
+}
+
+// Down 按版本从新到旧回滚 steps 个已执行的迁移
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps <= 0 {
+		return nil, nil
+	}
+
+	var result []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && len(result) < steps; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if err := m.runDown(ctx, conn, mig); err != nil {
+				return err
+			}
+			result = append(result, mig)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// To 迁移到指定版本：高于当前版本时依次执行 up，低于时依次执行 down
+func (m *Migrator) To(ctx context.Context, version int64) ([]Migration, error) {
+	if version != 0 && m.find(version) == nil {
+		return nil, fmt.Errorf("unknown migration version %d", version)
+	}
+
+	var result []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok || mig.Version <= version {
+				continue
+			}
+			if err := m.runDown(ctx, conn, mig); err != nil {
+				return err
+			}
+			result = append(result, mig)
+		}
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok || mig.Version > version {
+				continue
+			}
+			if err := m.runUp(ctx, conn, mig); err != nil {
+				return err
+			}
+			result = append(result, mig)
+		}
+		return nil
+	})
+	return result, err
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// verify 在执行迁移前检查失败残留、内容被修改以及代码中不存在的版本
+func (m *Migrator) verify(ctx context.Context, conn *sql.Conn) (map[int64]appliedRecord, error) {
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	for version, rec := range applied {
+		if rec.Dirty {
+			return nil, fmt.Errorf("%w: version %d, fix the schema manually and delete its row from schema_migrations", ErrDirty, version)
+		}
+		mig := m.find(version)
+		if mig == nil {
+			return nil, fmt.Errorf("database has migration %d which is unknown to this binary", version)
+		}
+		if mig.Checksum != rec.Checksum {
+			return nil, fmt.Errorf("%w: version %d", ErrChecksumMismatch, version)
+		}
+	}
+	return applied, nil
+}
+
+// MySQL 的 DDL 不支持事务，先写入 dirty 标记，全部语句成功后再清除
+func (m *Migrator) runUp(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	query := `INSERT INTO schema_migrations (version, name, checksum, dirty, applied_at) VALUES (?, ?, ?, 1, ?)`
+	if _, err := conn.ExecContext(ctx, query, mig.Version, mig.Name, mig.Checksum, time.Now()); err != nil {
+		return err
+	}
+	if err := execScript(ctx, conn, mig.Up); err != nil {
+		return fmt.Errorf("migration %d_%s up failed: %w", mig.Version, mig.Name, err)
+	}
+	_, err := conn.ExecContext(ctx, `UPDATE schema_migrations SET dirty = 0 WHERE version = ?`, mig.Version)
+	return err
+}
+
+func (m *Migrator) runDown(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	if _, err := conn.ExecContext(ctx, `UPDATE schema_migrations SET dirty = 1 WHERE version = ?`, mig.Version); err != nil {
+		return err
+	}
+	if err := execScript(ctx, conn, mig.Down); err != nil {
+		return fmt.Errorf("migration %d_%s down failed: %w", mig.Version, mig.Name, err)
+	}
+	_, err := conn.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, mig.Version)
+	return err
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func (m *Migrator) ensureTable(ctx context.Context, db execer) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT NOT NULL PRIMARY KEY COMMENT '迁移版本',
+    name VARCHAR(255) NOT NULL COMMENT '迁移名称',
+    checksum CHAR(64) NOT NULL COMMENT 'up 脚本的 SHA-256',
+    dirty TINYINT(1) NOT NULL DEFAULT 0 COMMENT '是否执行失败',
+    applied_at DATETIME NOT NULL COMMENT '执行时间'
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='数据库迁移记录表'`)
+	return err
+}
+
+func (m *Migrator) applied(ctx context.Context, db execer) (map[int64]appliedRecord, error) {
+	rows, err := db.QueryContext(ctx, `SELECT version, name, checksum, dirty, applied_at FROM schema_migrations`)
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == 1146 {
+		// 表不存在说明还没有执行过任何迁移
+		return map[int64]appliedRecord{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]appliedRecord)
+	for rows.Next() {
+		var rec appliedRecord
+		if err := rows.Scan(&rec.Version, &rec.Name, &rec.Checksum, &rec.Dirty, &rec.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[rec.Version] = rec
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, `SELECT GET_LOCK(?, 30)`, lockName).Scan(&locked); err != nil {
+		return err
+	}
+	if locked.Int64 != 1 {
+		return errors.New("another process is running migrations")
+	}
+	defer conn.ExecContext(context.Background(), `SELECT RELEASE_LOCK(?)`, lockName)
+
+	return fn(conn)
+}
+
+func execScript(ctx context.Context, conn *sql.Conn, script string) error {
+	for _, stmt := range splitStatements(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitStatements 按行尾的分号拆分脚本并去掉整行注释，迁移文件中的语句不要在行中间出现 ";\n"
+func splitStatements(script string) []string {
+	var stmts []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		stmts = append(stmts, rest)
+	}
+	return stmts
+}
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+func load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		match := migrationFileRe.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %q", entry.Name())
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, mig.Name, match[2])
+		}
+		if match[3] == "up" {
+			mig.Up = string(data)
+			sum := sha256.Sum256(data)
+			mig.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			mig.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s must have both up and down files", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
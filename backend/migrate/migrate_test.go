@@ -0,0 +1,423 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// 已发布的迁移可能已经在某些环境中执行过，修改后这些环境的迁移会因 checksum 不一致而失败。
+// 新增迁移时在这里追加，不要修改已有的值
+var releasedChecksums = map[int64]string{
+	1: "c17cc2fa04ece48f8552a56af3b5d90036a8e7a8e90553bdd4e1ed241c4f5f1e",
+	2: "286aea6e13e104de7eec2adfdd82bee715e10621a0ff3395cf1b8c74d36fa04f",
+	3: "91a468fd90594f6fa7d8b890e13ae0b070c56f9822b1dddbb569593e840d68b4",
+	4: "9c8cc754dc7c65a841edaa79c9f944ebd1e35688919f8bbaab338585b6c23e20",
+	5: "1c5a4b6eccb1a262974d5f72e633938a9f57f839b98ac5c3c8a52ada7a7672de",
+	6: "b93a49560e165c36fdc5555d962ed81e87198dc1803c5f913a65b667436e7874",
+	7: "cb496eb5bcc2ff95f4c0b6b49b61e2efb049b6772e3fd2cba1c2911812562ca9",
+	8: "2f6e398555517b9e8e40d8e37d97fe221d82426b949b9ca09af381bd49d029d9",
+	9: "efbf9efe3d588792eedb3dedc425b6af679664e629166367883ca737ed6cde90",
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	m, err := New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrations := m.Migrations()
+	if len(migrations) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+	for i, mig := range migrations {
+		// 版本号从 1 开始连续，避免两个分支各自新增同一个版本
+		if mig.Version != int64(i+1) {
+			t.Errorf("migration %d_%s: version %d, want %d", mig.Version, mig.Name, mig.Version, i+1)
+		}
+		sum := sha256.Sum256([]byte(mig.Up))
+		if mig.Checksum != hex.EncodeToString(sum[:]) {
+			t.Errorf("migration %d: checksum %s does not match its up script", mig.Version, mig.Checksum)
+		}
+		if want, ok := releasedChecksums[mig.Version]; !ok {
+			t.Errorf("migration %d: add its checksum %s to releasedChecksums", mig.Version, mig.Checksum)
+		} else if mig.Checksum != want {
+			t.Errorf("released migration %d_%s was modified, add a new migration instead", mig.Version, mig.Name)
+		}
+		if len(splitStatements(mig.Up)) == 0 || len(splitStatements(mig.Down)) == 0 {
+			t.Errorf("migration %d: empty up or down script", mig.Version)
+		}
+	}
+	if m.Latest() != migrations[len(migrations)-1].Version {
+		t.Errorf("Latest = %d", m.Latest())
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0010_add_index.up.sql":      {Data: []byte("CREATE INDEX idx ON t (c);")},
+		"m/0010_add_index.down.sql":    {Data: []byte("DROP INDEX idx ON t;")},
+		"m/0002_create_table.up.sql":   {Data: []byte("CREATE TABLE t (c INT);")},
+		"m/0002_create_table.down.sql": {Data: []byte("DROP TABLE t;")},
+	}
+	migrations, err := load(fsys, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 按数值而不是文件名排序
+	if len(migrations) != 2 || migrations[0].Version != 2 || migrations[1].Version != 10 {
+		t.Fatalf("migrations %+v, want versions 2, 10", migrations)
+	}
+	if mig := migrations[0]; mig.Name != "create_table" || mig.Up != "CREATE TABLE t (c INT);" || mig.Down != "DROP TABLE t;" {
+		t.Errorf("migration 2 = %+v", mig)
+	}
+
+	for name, fsys := range map[string]fstest.MapFS{
+		"bad name":       {"m/2_Create.up.sql": {}, "m/2_Create.down.sql": {}},
+		"not sql":        {"m/README.md": {}},
+		"missing down":   {"m/0001_a.up.sql": {Data: []byte("SELECT 1;")}},
+		"missing up":     {"m/0001_a.down.sql": {Data: []byte("SELECT 1;")}},
+		"name conflicts": {"m/0001_a.up.sql": {Data: []byte("SELECT 1;")}, "m/0001_b.down.sql": {Data: []byte("SELECT 1;")}},
+	} {
+		if _, err := load(fsys, "m"); err == nil {
+			t.Errorf("%s: load succeeded", name)
+		}
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- 注释
+CREATE TABLE t (
+    c VARCHAR(10) COMMENT 'a;b'
+);
+
+  -- 缩进的注释
+INSERT INTO t VALUES ('x');
+SELECT 1`
+	want := []string{
+		"CREATE TABLE t (\n    c VARCHAR(10) COMMENT 'a;b'\n)",
+		"INSERT INTO t VALUES ('x')",
+		"SELECT 1",
+	}
+	if got := splitStatements(script); !reflect.DeepEqual(got, want) {
+		t.Errorf("splitStatements = %q, want %q", got, want)
+	}
+}
+
+func TestCheckStatus(t *testing.T) {
+	applied := Status{Version: 1, Applied: true}
+	for _, tc := range []struct {
+		name     string
+		statuses []Status
+		want     error
+	}{
+		{"current", []Status{applied, {Version: 2, Applied: true}}, nil},
+		{"empty", nil, nil},
+		{"behind", []Status{applied, {Version: 2}}, ErrSchemaBehind},
+		{"dirty", []Status{applied, {Version: 2, Applied: true, Dirty: true}}, ErrDirty},
+		{"modified", []Status{{Version: 1, Applied: true, ChecksumMismatch: true}, {Version: 2}}, ErrChecksumMismatch},
+	} {
+		if err := CheckStatus(tc.statuses); !errors.Is(err, tc.want) {
+			t.Errorf("%s: CheckStatus = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
+
+// fakeDB 模拟 Migrator 用到的 MySQL 语句：schema_migrations 表的读写和 GET_LOCK，
+// 其他语句视为迁移脚本，按顺序记录在 executed 中
+type fakeDB struct {
+	mu       sync.Mutex
+	applied  map[int64]appliedRecord
+	executed []string
+	// failOn 执行包含该字符串的迁移语句时返回错误
+	failOn string
+}
+
+var (
+	fakeDBsMu sync.Mutex
+	fakeDBs   = map[string]*fakeDB{}
+)
+
+func init() {
+	sql.Register("migratetest", fakeDriver{})
+}
+
+// newFakeMigrator 返回使用 migrations 和 fakeDB 的 Migrator
+func newFakeMigrator(t *testing.T, migrations []Migration) (*Migrator, *fakeDB) {
+	t.Helper()
+	fake := &fakeDB{applied: map[int64]appliedRecord{}}
+	fakeDBsMu.Lock()
+	fakeDBs[t.Name()] = fake
+	fakeDBsMu.Unlock()
+
+	db, err := sql.Open("migratetest", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &Migrator{db: db, migrations: migrations}, fake
+}
+
+// testMigrations 三个迁移，up 和 down 分别执行 "up N"、"down N"
+func testMigrations() []Migration {
+	var migrations []Migration
+	for v := int64(1); v <= 3; v++ {
+		up := fmt.Sprintf("up %d;", v)
+		sum := sha256.Sum256([]byte(up))
+		migrations = append(migrations, Migration{
+			Version:  v,
+			Name:     fmt.Sprintf("step_%d", v),
+			Up:       up,
+			Down:     fmt.Sprintf("down %d;", v),
+			Checksum: hex.EncodeToString(sum[:]),
+		})
+	}
+	return migrations
+}
+
+// takeExecuted 返回并清空已执行的迁移语句
+func (f *fakeDB) takeExecuted() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	executed := f.executed
+	f.executed = nil
+	return executed
+}
+
+func (f *fakeDB) exec(query string, args []driver.NamedValue) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	arg := func(i int) any { return args[i].Value }
+	switch {
+	case strings.HasPrefix(query, "CREATE TABLE IF NOT EXISTS schema_migrations"),
+		strings.HasPrefix(query, "SELECT RELEASE_LOCK"):
+	case strings.HasPrefix(query, "INSERT INTO schema_migrations"):
+		version := arg(0).(int64)
+		f.applied[version] = appliedRecord{
+			Version: version, Name: arg(1).(string), Checksum: arg(2).(string), Dirty: true, AppliedAt: arg(3).(time.Time),
+		}
+	case strings.HasPrefix(query, "UPDATE schema_migrations SET dirty"):
+		version := arg(0).(int64)
+		rec := f.applied[version]
+		rec.Dirty = strings.Contains(query, "dirty = 1")
+		f.applied[version] = rec
+	case strings.HasPrefix(query, "DELETE FROM schema_migrations"):
+		delete(f.applied, arg(0).(int64))
+	default:
+		if f.failOn != "" && strings.Contains(query, f.failOn) {
+			return errors.New("syntax error")
+		}
+		f.executed = append(f.executed, query)
+	}
+	return nil
+}
+
+func (f *fakeDB) query(query string) (driver.Rows, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case strings.HasPrefix(query, "SELECT GET_LOCK"):
+		return &fakeRows{columns: []string{"locked"}, values: [][]driver.Value{{int64(1)}}}, nil
+	case strings.HasPrefix(query, "SELECT version, name, checksum, dirty, applied_at FROM schema_migrations"):
+		rows := &fakeRows{columns: []string{"version", "name", "checksum", "dirty", "applied_at"}}
+		for _, rec := range f.applied {
+			rows.values = append(rows.values, []driver.Value{rec.Version, rec.Name, rec.Checksum, rec.Dirty, rec.AppliedAt})
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("unexpected query %q", query)
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeDBsMu.Lock()
+	defer fakeDBsMu.Unlock()
+	return fakeConn{fakeDBs[name]}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare is not supported")
+}
+func (c fakeConn) Close() error { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+func (c fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.db.exec(query, args); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.db.query(query)
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// versions 返回已执行的迁移版本
+func versions(t *testing.T, m *Migrator) []int64 {
+	t.Helper()
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var applied []int64
+	for _, s := range statuses {
+		if s.Applied {
+			applied = append(applied, s.Version)
+		}
+	}
+	return applied
+}
+
+func TestUpDownTo(t *testing.T) {
+	ctx := context.Background()
+	m, db := newFakeMigrator(t, testMigrations())
+
+	if err := m.Check(ctx); !errors.Is(err, ErrSchemaBehind) {
+		t.Errorf("Check before Up = %v, want ErrSchemaBehind", err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := db.takeExecuted(), []string{"up 1", "up 2", "up 3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Up executed %q, want %q", got, want)
+	}
+	if err := m.Check(ctx); err != nil {
+		t.Errorf("Check after Up = %v", err)
+	}
+	// 已是最新时不再执行
+	if result, err := m.Up(ctx); err != nil || len(result) != 0 {
+		t.Errorf("second Up = %d migrations, %v", len(result), err)
+	}
+
+	if _, err := m.Down(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := db.takeExecuted(), []string{"down 3", "down 2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Down executed %q, want %q", got, want)
+	}
+	if got := versions(t, m); !reflect.DeepEqual(got, []int64{1}) {
+		t.Errorf("applied after Down = %v, want [1]", got)
+	}
+	if err := m.Check(ctx); !errors.Is(err, ErrSchemaBehind) {
+		t.Errorf("Check after Down = %v, want ErrSchemaBehind", err)
+	}
+
+	if _, err := m.To(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.To(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := db.takeExecuted(), []string{"up 2", "down 2", "down 1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("To executed %q, want %q", got, want)
+	}
+	if got := versions(t, m); len(got) != 0 {
+		t.Errorf("applied after To(0) = %v", got)
+	}
+	if _, err := m.To(ctx, 4); err == nil {
+		t.Error("To an unknown version succeeded")
+	}
+}
+
+func TestDirty(t *testing.T) {
+	ctx := context.Background()
+	m, db := newFakeMigrator(t, testMigrations())
+	db.failOn = "up 2"
+
+	if _, err := m.Up(ctx); err == nil {
+		t.Fatal("Up succeeded with a failing migration")
+	}
+	if got, want := db.takeExecuted(), []string{"up 1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("executed %q, want %q", got, want)
+	}
+	// 失败的迁移留下 dirty 标记，修复前拒绝继续迁移
+	if err := m.Check(ctx); !errors.Is(err, ErrDirty) {
+		t.Errorf("Check = %v, want ErrDirty", err)
+	}
+	db.failOn = ""
+	if _, err := m.Up(ctx); !errors.Is(err, ErrDirty) {
+		t.Errorf("Up on a dirty schema = %v, want ErrDirty", err)
+	}
+	if executed := db.takeExecuted(); len(executed) != 0 {
+		t.Errorf("executed %q on a dirty schema", executed)
+	}
+}
+
+func TestChecksumMismatch(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newFakeMigrator(t, testMigrations())
+	if _, err := m.To(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// 已执行的迁移在代码中被修改
+	m.migrations[0].Checksum = "modified"
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !statuses[0].ChecksumMismatch || statuses[1].ChecksumMismatch {
+		t.Errorf("statuses %+v, want only version 1 mismatched", statuses)
+	}
+	if err := m.Check(ctx); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Check = %v, want ErrChecksumMismatch", err)
+	}
+	if _, err := m.Up(ctx); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Up = %v, want ErrChecksumMismatch", err)
+	}
+}
+
+func TestUnknownAppliedVersion(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newFakeMigrator(t, testMigrations())
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 数据库已由更新的版本迁移过，旧的二进制不能继续迁移
+	m.migrations = m.migrations[:2]
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 3 || !statuses[2].Applied || statuses[2].Name != "step_3" {
+		t.Errorf("statuses %+v, want version 3 listed from the database", statuses)
+	}
+	if _, err := m.Down(ctx, 1); err == nil {
+		t.Error("Down succeeded with an unknown applied version")
+	}
+}
@@ -1,9 +1,3 @@
--- 创建数据库
-CREATE DATABASE IF NOT EXISTS tuna CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
-
--- 使用数据库
-USE tuna;
-
 -- 创建用户信息表
 CREATE TABLE IF NOT EXISTS user_info_tab (
     id BIGINT AUTO_INCREMENT PRIMARY KEY,
@@ -18,4 +12,3 @@ CREATE TABLE IF NOT EXISTS user_info_tab (
     INDEX idx_status (status),
     INDEX idx_created_at (created_at)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='用户信息表';
-
@@ -0,0 +1,19 @@
+-- 创建管理员账号表
+CREATE TABLE IF NOT EXISTS admin_account (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    username VARCHAR(64) NOT NULL COMMENT '登录名',
+    password_hash VARCHAR(255) NOT NULL COMMENT 'bcrypt 密码摘要',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP COMMENT '更新时间',
+    UNIQUE KEY uk_username (username)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='管理员账号表';
+
+-- 创建管理员登录会话表
+CREATE TABLE IF NOT EXISTS admin_session (
+    token_hash CHAR(64) NOT NULL PRIMARY KEY COMMENT 'token 的 SHA-256 摘要',
+    account_id BIGINT NOT NULL COMMENT '管理员账号ID',
+    expires_at DATETIME NOT NULL COMMENT '过期时间',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    INDEX idx_account_id (account_id),
+    INDEX idx_expires_at (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='管理员登录会话表';
@@ -0,0 +1,11 @@
+-- 创建审核历史表
+CREATE TABLE IF NOT EXISTS status_history (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT NOT NULL COMMENT '用户信息ID',
+    actor VARCHAR(64) NOT NULL COMMENT '操作的管理员',
+    old_status VARCHAR(20) NOT NULL COMMENT '变更前状态',
+    new_status VARCHAR(20) NOT NULL COMMENT '变更后状态',
+    reason VARCHAR(500) NOT NULL DEFAULT '' COMMENT '原因',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '操作时间',
+    INDEX idx_user_id_created_at (user_id, created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='审核历史表';
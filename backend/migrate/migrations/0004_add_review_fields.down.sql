@@ -0,0 +1,3 @@
+ALTER TABLE user_info_tab
+    DROP COLUMN review_notes,
+    DROP COLUMN reject_reason;
@@ -0,0 +1,4 @@
+-- 拒绝原因和审核备注
+ALTER TABLE user_info_tab
+    ADD COLUMN reject_reason VARCHAR(500) NOT NULL DEFAULT '' COMMENT '拒绝原因' AFTER status,
+    ADD COLUMN review_notes VARCHAR(1000) NOT NULL DEFAULT '' COMMENT '审核备注' AFTER reject_reason;
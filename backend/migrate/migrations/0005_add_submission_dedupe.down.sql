@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS idempotency_key;
+
+ALTER TABLE user_info_tab
+    DROP INDEX uk_pending_phone,
+    DROP INDEX uk_pending_email,
+    DROP COLUMN pending_phone,
+    DROP COLUMN pending_email;
@@ -0,0 +1,19 @@
+-- 待审核记录查重用的列，只在 pending 且需要查重时有值
+ALTER TABLE user_info_tab
+    ADD COLUMN pending_email VARCHAR(255) NULL DEFAULT NULL COMMENT '待审核时用于查重的邮箱，离开待审核后清空' AFTER review_notes,
+    ADD COLUMN pending_phone VARCHAR(20) NULL DEFAULT NULL COMMENT '待审核时用于查重的手机号，离开待审核后清空' AFTER pending_email,
+    ADD UNIQUE KEY uk_pending_email (pending_email),
+    ADD UNIQUE KEY uk_pending_phone (pending_phone);
+
+-- 创建幂等键表，保存 Idempotency-Key 对应的响应
+CREATE TABLE IF NOT EXISTS idempotency_key (
+    scope VARCHAR(32) NOT NULL COMMENT '接口',
+    idem_key VARCHAR(128) NOT NULL COMMENT 'Idempotency-Key 请求头',
+    request_hash CHAR(64) NOT NULL COMMENT '请求体的 SHA-256 摘要',
+    status_code INT NOT NULL DEFAULT 0 COMMENT '响应状态码，0 表示处理中',
+    response_body MEDIUMBLOB NULL COMMENT '响应体',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+    expires_at DATETIME NOT NULL COMMENT '过期时间',
+    PRIMARY KEY (scope, idem_key),
+    INDEX idx_expires_at (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='幂等键表';
@@ -0,0 +1,3 @@
+ALTER TABLE user_info_tab
+    DROP INDEX uk_tracking_token,
+    DROP COLUMN tracking_token;
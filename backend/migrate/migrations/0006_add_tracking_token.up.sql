@@ -0,0 +1,4 @@
+-- 提交者查询审核进度的凭证，老数据为 NULL
+ALTER TABLE user_info_tab
+    ADD COLUMN tracking_token VARCHAR(64) NULL DEFAULT NULL COMMENT '提交者查询审核进度的凭证' AFTER pending_phone,
+    ADD UNIQUE KEY uk_tracking_token (tracking_token);
@@ -0,0 +1,5 @@
+ALTER TABLE user_info_tab
+    DROP INDEX idx_phone_e164,
+    DROP INDEX idx_email_normalized,
+    DROP COLUMN phone_e164,
+    DROP COLUMN email_normalized;
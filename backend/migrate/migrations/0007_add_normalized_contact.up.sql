@@ -0,0 +1,18 @@
+-- 规范化后的邮箱和 E.164 手机号，用于查重和搜索
+ALTER TABLE user_info_tab
+    ADD COLUMN email_normalized VARCHAR(255) NULL DEFAULT NULL COMMENT '去掉空白并转为小写的邮箱' AFTER phone,
+    ADD COLUMN phone_e164 VARCHAR(20) NULL DEFAULT NULL COMMENT 'E.164 格式的手机号，无法解析时为 NULL' AFTER email_normalized,
+    ADD INDEX idx_email_normalized (email_normalized),
+    ADD INDEX idx_phone_e164 (phone_e164);
+
+UPDATE user_info_tab SET email_normalized = LOWER(TRIM(email));
+
+-- 不带国际区号的老号码需要按默认地区解析，在 SQL 中无法完成，保持 NULL，
+-- 搜索时仍会匹配原始的 phone 列；已经是 + 开头的号码直接去掉分隔符
+UPDATE user_info_tab
+SET phone_e164 = REPLACE(REPLACE(REPLACE(REPLACE(REPLACE(TRIM(phone), ' ', ''), '-', ''), '(', ''), ')', ''), '.', '')
+WHERE REPLACE(REPLACE(REPLACE(REPLACE(REPLACE(TRIM(phone), ' ', ''), '-', ''), '(', ''), ')', ''), '.', '') REGEXP '^[+][1-9][0-9]{7,14}$';
+
+-- 待审核记录的查重键改用规范化的值，与已有记录冲突的保持原值
+UPDATE IGNORE user_info_tab SET pending_phone = phone_e164
+WHERE pending_phone IS NOT NULL AND phone_e164 IS NOT NULL;
@@ -0,0 +1,29 @@
+-- 领域事件 outbox，与业务数据在同一事务中写入，由后台 relay 分发给各个接收方
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    event_id CHAR(32) NOT NULL COMMENT '事件的全局唯一ID，接收方用于去重',
+    event_type VARCHAR(64) NOT NULL COMMENT '事件类型，如 submission.created',
+    payload JSON NOT NULL COMMENT '事件内容',
+    created_at DATETIME(3) NOT NULL COMMENT '发生时间',
+    dispatched_at DATETIME(3) NULL DEFAULT NULL COMMENT '生成投递记录的时间，NULL 表示尚未分发',
+    UNIQUE KEY uk_event_id (event_id),
+    INDEX idx_dispatched_at (dispatched_at, id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='领域事件 outbox';
+
+-- 每个事件对每个接收方（如某个 webhook 订阅）一条投递记录，记录重试状态
+CREATE TABLE IF NOT EXISTS outbox_deliveries (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    event_id BIGINT NOT NULL COMMENT 'outbox_events.id',
+    sink VARCHAR(64) NOT NULL COMMENT '接收方，如 webhook:crm',
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' COMMENT 'pending, delivered, failed',
+    attempts INT NOT NULL DEFAULT 0 COMMENT '已尝试次数',
+    next_attempt_at DATETIME(3) NOT NULL COMMENT '下次尝试时间',
+    locked_until DATETIME(3) NULL DEFAULT NULL COMMENT '被某个实例领取后的租约到期时间',
+    claim_token CHAR(32) NULL DEFAULT NULL COMMENT '领取时写入的随机值',
+    last_error VARCHAR(1000) NOT NULL DEFAULT '' COMMENT '最近一次失败的原因',
+    delivered_at DATETIME(3) NULL DEFAULT NULL COMMENT '投递成功的时间',
+    created_at DATETIME(3) NOT NULL COMMENT '创建时间',
+    UNIQUE KEY uk_event_sink (event_id, sink),
+    INDEX idx_status_next_attempt (status, next_attempt_at),
+    INDEX idx_claim_token (claim_token)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='outbox 投递记录';
@@ -0,0 +1,3 @@
+-- 处理中的幂等键只锁定一小段时间，进程在保存响应前退出时，锁过期后同一个 key 的请求可以接管
+ALTER TABLE idempotency_key
+    ADD COLUMN locked_until DATETIME NULL DEFAULT NULL COMMENT '处理中记录的锁过期时间' AFTER response_body;
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+type AdminAccount struct {
+	ID           int64     `json:"id" db:"id"`
+	Username     string    `json:"username" db:"username"`
+	PasswordHash string    `json:"-" db:"password_hash"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// AdminSession 服务端保存的登录会话，只存 token 的 SHA-256 摘要
+type AdminSession struct {
+	TokenHash string    `db:"token_hash"`
+	AccountID int64     `db:"account_id"`
+	ExpiresAt time.Time `db:"expires_at"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// MeResponse GET /admin/me 的响应
+type MeResponse struct {
+	Account AdminAccount `json:"account"`
+}
+
+type LoginResponse struct {
+	Token     string       `json:"token"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	Account   AdminAccount `json:"account"`
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// AdminRepository 管理员账号和登录会话的存储
+type AdminRepository interface {
+	CreateAccount(ctx context.Context, account *AdminAccount) error
+	// GetAccountByUsername 和 GetAccountByID 在账号不存在时返回 nil, nil
+	GetAccountByUsername(ctx context.Context, username string) (*AdminAccount, error)
+	GetAccountByID(ctx context.Context, id int64) (*AdminAccount, error)
+
+	CreateSession(ctx context.Context, session *AdminSession) error
+	// GetSession 在会话不存在或已过期时返回 nil, nil
+	GetSession(ctx context.Context, tokenHash string) (*AdminSession, error)
+	DeleteSession(ctx context.Context, tokenHash string) error
+	DeleteExpiredSessions(ctx context.Context) error
+}
+
+type mysqlAdminRepository struct {
+	db *sql.DB
+}
+
+// NewMySQLAdminRepository 返回基于 MySQL 的 AdminRepository
+func NewMySQLAdminRepository(db *sql.DB) AdminRepository {
+	return &mysqlAdminRepository{db: db}
+}
+
+func (r *mysqlAdminRepository) CreateAccount(ctx context.Context, account *AdminAccount) error {
+	query := `INSERT INTO admin_account (username, password_hash, created_at, updated_at) VALUES (?, ?, ?, ?)`
+
+	now := time.Now()
+	result, err := r.db.ExecContext(ctx, query, account.Username, account.PasswordHash, now, now)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	account.ID = id
+	account.CreatedAt = now
+	account.UpdatedAt = now
+	return nil
+}
+
+func (r *mysqlAdminRepository) GetAccountByUsername(ctx context.Context, username string) (*AdminAccount, error) {
+	query := `SELECT id, username, password_hash, created_at, updated_at FROM admin_account WHERE username = ?`
+	return r.scanAccount(r.db.QueryRowContext(ctx, query, username))
+}
+
+func (r *mysqlAdminRepository) GetAccountByID(ctx context.Context, id int64) (*AdminAccount, error) {
+	query := `SELECT id, username, password_hash, created_at, updated_at FROM admin_account WHERE id = ?`
+	return r.scanAccount(r.db.QueryRowContext(ctx, query, id))
+}
+
+func (r *mysqlAdminRepository) scanAccount(row *sql.Row) (*AdminAccount, error) {
+	var account AdminAccount
+	err := row.Scan(&account.ID, &account.Username, &account.PasswordHash, &account.CreatedAt, &account.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (r *mysqlAdminRepository) CreateSession(ctx context.Context, session *AdminSession) error {
+	query := `INSERT INTO admin_session (token_hash, account_id, expires_at, created_at) VALUES (?, ?, ?, ?)`
+
+	session.CreatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx, query, session.TokenHash, session.AccountID, session.ExpiresAt, session.CreatedAt)
+	return err
+}
+
+func (r *mysqlAdminRepository) GetSession(ctx context.Context, tokenHash string) (*AdminSession, error) {
+	query := `SELECT token_hash, account_id, expires_at, created_at FROM admin_session
+	          WHERE token_hash = ? AND expires_at > ?`
+
+	var session AdminSession
+	err := r.db.QueryRowContext(ctx, query, tokenHash, time.Now()).Scan(&session.TokenHash,
+		&session.AccountID, &session.ExpiresAt, &session.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *mysqlAdminRepository) DeleteSession(ctx context.Context, tokenHash string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM admin_session WHERE token_hash = ?`, tokenHash)
+	return err
+}
+
+func (r *mysqlAdminRepository) DeleteExpiredSessions(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM admin_session WHERE expires_at <= ?`, time.Now())
+	return err
+}
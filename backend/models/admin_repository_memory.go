@@ -0,0 +1,106 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type memoryAdminRepository struct {
+	mu       sync.RWMutex
+	nextID   int64
+	accounts map[int64]AdminAccount
+	sessions map[string]AdminSession
+}
+
+// NewMemoryAdminRepository 返回基于内存的 AdminRepository
+func NewMemoryAdminRepository() AdminRepository {
+	return &memoryAdminRepository{
+		accounts: make(map[int64]AdminAccount),
+		sessions: make(map[string]AdminSession),
+	}
+}
+
+func (r *memoryAdminRepository) CreateAccount(ctx context.Context, account *AdminAccount) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.accounts {
+		if existing.Username == account.Username {
+			return fmt.Errorf("admin account %q already exists", account.Username)
+		}
+	}
+
+	r.nextID++
+	now := time.Now()
+	account.ID = r.nextID
+	account.CreatedAt = now
+	account.UpdatedAt = now
+	r.accounts[account.ID] = *account
+	return nil
+}
+
+func (r *memoryAdminRepository) GetAccountByUsername(ctx context.Context, username string) (*AdminAccount, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, account := range r.accounts {
+		if account.Username == username {
+			return &account, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryAdminRepository) GetAccountByID(ctx context.Context, id int64) (*AdminAccount, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	account, ok := r.accounts[id]
+	if !ok {
+		return nil, nil
+	}
+	return &account, nil
+}
+
+func (r *memoryAdminRepository) CreateSession(ctx context.Context, session *AdminSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session.CreatedAt = time.Now()
+	r.sessions[session.TokenHash] = *session
+	return nil
+}
+
+func (r *memoryAdminRepository) GetSession(ctx context.Context, tokenHash string) (*AdminSession, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session, ok := r.sessions[tokenHash]
+	if !ok || !session.ExpiresAt.After(time.Now()) {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+func (r *memoryAdminRepository) DeleteSession(ctx context.Context, tokenHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.sessions, tokenHash)
+	return nil
+}
+
+func (r *memoryAdminRepository) DeleteExpiredSessions(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for hash, session := range r.sessions {
+		if !session.ExpiresAt.After(now) {
+			delete(r.sessions, hash)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DuplicatePolicy 同一邮箱或手机号已有待审核记录时的处理方式
+type DuplicatePolicy string
+
+const (
+	// DuplicateReject 拒绝新的提交
+	DuplicateReject DuplicatePolicy = "reject"
+	// DuplicateMerge 邮箱和手机号都相同时用新提交的内容覆盖已有的待审核记录，只有一项相同时按 reject 处理
+	DuplicateMerge DuplicatePolicy = "merge"
+	// DuplicateAllow 不做重复检查
+	DuplicateAllow DuplicatePolicy = "allow"
+)
+
+// ErrDuplicateSubmission 已存在相同邮箱或手机号的待审核记录
+var ErrDuplicateSubmission = errors.New("a pending submission with the same email or phone already exists")
+
+// ParseDuplicatePolicy 空字符串视为 reject
+func ParseDuplicatePolicy(s string) (DuplicatePolicy, error) {
+	switch p := DuplicatePolicy(strings.ToLower(strings.TrimSpace(s))); p {
+	case "":
+		return DuplicateReject, nil
+	case DuplicateReject, DuplicateMerge, DuplicateAllow:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown duplicate policy %q", s)
+	}
+}
+
+// dedupeKeys 返回写入 pending_email/pending_phone 的值。这两列上有唯一索引，
+// 只在记录处于 pending 且需要查重时有值，离开 pending 后清空。
+// 使用规范化后的值，调用前需要先 normalizeContact
+func dedupeKeys(user *UserInfo, policy DuplicatePolicy) (sql.NullString, sql.NullString) {
+	if policy == DuplicateAllow || user.Status != StatusPending {
+		return sql.NullString{}, sql.NullString{}
+	}
+	phone := user.PhoneE164
+	if phone == "" {
+		phone = strings.TrimSpace(user.Phone)
+	}
+	return nullString(user.EmailNormalized), nullString(phone)
+}
+
+// canMerge 只有邮箱和手机号都与已有记录相同时才允许合并，
+// 否则只知道对方邮箱的人就能覆盖对方的待审核记录
+func canMerge(existing, keys [2]sql.NullString) bool {
+	return sameKey(existing[0], keys[0]) && sameKey(existing[1], keys[1])
+}
+
+// sameKey 与 SQL 中 NULL 不等于任何值的语义一致
+func sameKey(a, b sql.NullString) bool {
+	return a.Valid && b.Valid && a.String == b.String
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
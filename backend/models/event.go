@@ -0,0 +1,107 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// 领域事件类型
+const (
+	EventSubmissionCreated  = "submission.created"
+	EventSubmissionApproved = "submission.approved"
+	EventSubmissionRejected = "submission.rejected"
+	EventSubmissionReopened = "submission.reopened"
+)
+
+// submission.created 的提交来源
+const (
+	EventSourceAPI    = "api"
+	EventSourceImport = "import"
+)
+
+// EventTypes 全部事件类型
+var EventTypes = []string{EventSubmissionCreated, EventSubmissionApproved, EventSubmissionRejected, EventSubmissionReopened}
+
+// Event 领域事件，与业务数据在同一事务中写入 outbox_events，提交后由 outbox relay 分发
+type Event struct {
+	// ID 全局唯一，同一事件重试投递时不变，接收方可以据此去重
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       SubmissionEvent `json:"data"`
+}
+
+// SubmissionEvent 提交记录相关事件的内容，为事件发生时记录的快照
+type SubmissionEvent struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Phone  string `json:"phone"`
+	Hobby  string `json:"hobby"`
+	Age    int    `json:"age"`
+	Status string `json:"status"`
+	// Source 提交来源：api 或 import，只在 submission.created 中有值
+	Source string `json:"source,omitempty"`
+	// Merged 为 true 表示合并到了已有的待审核记录
+	Merged bool `json:"merged,omitempty"`
+	// PreviousStatus、Actor、Reason 只在状态变更事件中有值
+	PreviousStatus string `json:"previous_status,omitempty"`
+	Actor          string `json:"actor,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+}
+
+// statusEventTypes 状态变更后的状态对应的事件类型
+var statusEventTypes = map[string]string{
+	StatusApproved: EventSubmissionApproved,
+	StatusRejected: EventSubmissionRejected,
+	StatusPending:  EventSubmissionReopened,
+}
+
+// randomID 生成 128 位随机数的十六进制编码
+func randomID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func newEvent(eventType string, data SubmissionEvent, now time.Time) (Event, error) {
+	id, err := randomID()
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{ID: id, Type: eventType, OccurredAt: now, Data: data}, nil
+}
+
+// createdEvent 保存提交记录后的事件，user 为写入后的记录
+func createdEvent(user *UserInfo, source string, merged bool, now time.Time) (Event, error) {
+	return newEvent(EventSubmissionCreated, SubmissionEvent{
+		ID:     user.ID,
+		Name:   user.Name,
+		Email:  user.Email,
+		Phone:  user.Phone,
+		Hobby:  user.Hobby,
+		Age:    user.Age,
+		Status: user.Status,
+		Source: source,
+		Merged: merged,
+	}, now)
+}
+
+// statusChangedEvent 审核状态变更后的事件，user 为变更前的记录
+func statusChangedEvent(user *UserInfo, change StatusChange, now time.Time) (Event, error) {
+	return newEvent(statusEventTypes[change.Status], SubmissionEvent{
+		ID:             user.ID,
+		Name:           user.Name,
+		Email:          user.Email,
+		Phone:          user.Phone,
+		Hobby:          user.Hobby,
+		Age:            user.Age,
+		Status:         change.Status,
+		PreviousStatus: user.Status,
+		Actor:          change.Actor,
+		Reason:         change.Reason,
+	}, now)
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// IdempotencyRecord 一个 Idempotency-Key 对应的请求及其响应。
+// StatusCode 为 0 表示首个请求仍在处理中
+type IdempotencyRecord struct {
+	Scope        string `db:"scope"`
+	Key          string `db:"idem_key"`
+	RequestHash  string `db:"request_hash"`
+	StatusCode   int    `db:"status_code"`
+	ResponseBody []byte `db:"response_body"`
+	// LockedUntil 处理中的记录在此之前不能被其他请求接管，过期说明首个请求已经中断
+	LockedUntil time.Time `db:"locked_until"`
+	CreatedAt   time.Time `db:"created_at"`
+	ExpiresAt   time.Time `db:"expires_at"`
+}
+
+// stale 处理中且锁已过期，首个请求已经中断
+func (r *IdempotencyRecord) stale(now time.Time) bool {
+	return r.StatusCode == 0 && !r.LockedUntil.After(now)
+}
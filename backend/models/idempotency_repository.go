@@ -0,0 +1,109 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// IdempotencyRepository 保存 Idempotency-Key 与响应的对应关系，用于重放重复请求
+type IdempotencyRepository interface {
+	// Reserve 占用 record 中的 key。key 已被占用且未过期时不写入，返回已有记录；
+	// 已有记录仍在处理中但 LockedUntil 已过期时由 record 接管
+	Reserve(ctx context.Context, record *IdempotencyRecord) (existing *IdempotencyRecord, err error)
+	// Complete 保存首个请求的响应
+	Complete(ctx context.Context, scope, key string, statusCode int, body []byte) error
+	// Release 释放 key，首个请求失败时调用，允许客户端用同一个 key 重试
+	Release(ctx context.Context, scope, key string) error
+	// DeleteExpired 删除全部过期的记录，返回删除的条数
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// idempotencyDeleteBatch DeleteExpired 每条 DELETE 语句删除的行数上限，避免长时间锁表
+const idempotencyDeleteBatch = 1000
+
+type mysqlIdempotencyRepository struct {
+	db *sql.DB
+}
+
+// NewMySQLIdempotencyRepository 返回基于 MySQL 的 IdempotencyRepository
+func NewMySQLIdempotencyRepository(db *sql.DB) IdempotencyRepository {
+	return &mysqlIdempotencyRepository{db: db}
+}
+
+func (r *mysqlIdempotencyRepository) Reserve(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error) {
+	now := time.Now()
+	if _, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_key WHERE scope = ? AND idem_key = ? AND expires_at <= ?`,
+		record.Scope, record.Key, now); err != nil {
+		return nil, err
+	}
+
+	record.CreatedAt = now
+	query := `INSERT INTO idempotency_key (scope, idem_key, request_hash, status_code, locked_until, created_at, expires_at)
+	          VALUES (?, ?, ?, 0, ?, ?, ?)`
+	_, err := r.db.ExecContext(ctx, query, record.Scope, record.Key, record.RequestHash, record.LockedUntil,
+		record.CreatedAt, record.ExpiresAt)
+	if err == nil {
+		return nil, nil
+	}
+	if !isDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	// 接管锁已过期的处理中记录；并发接管时行锁保证只有一个请求更新成功。
+	// 增加 locked_until 列之前写入的处理中记录没有锁，同样视为已中断
+	query = `UPDATE idempotency_key SET request_hash = ?, locked_until = ?, created_at = ?, expires_at = ?
+	         WHERE scope = ? AND idem_key = ? AND status_code = 0 AND (locked_until IS NULL OR locked_until <= ?)`
+	result, err := r.db.ExecContext(ctx, query, record.RequestHash, record.LockedUntil, record.CreatedAt, record.ExpiresAt,
+		record.Scope, record.Key, now)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 1 {
+		return nil, nil
+	}
+
+	query = `SELECT scope, idem_key, request_hash, status_code, response_body, locked_until, created_at, expires_at
+	         FROM idempotency_key WHERE scope = ? AND idem_key = ?`
+	var existing IdempotencyRecord
+	var lockedUntil sql.NullTime
+	err = r.db.QueryRowContext(ctx, query, record.Scope, record.Key).Scan(&existing.Scope, &existing.Key,
+		&existing.RequestHash, &existing.StatusCode, &existing.ResponseBody, &lockedUntil, &existing.CreatedAt, &existing.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	existing.LockedUntil = lockedUntil.Time
+	return &existing, nil
+}
+
+func (r *mysqlIdempotencyRepository) Complete(ctx context.Context, scope, key string, statusCode int, body []byte) error {
+	query := `UPDATE idempotency_key SET status_code = ?, response_body = ? WHERE scope = ? AND idem_key = ?`
+	_, err := r.db.ExecContext(ctx, query, statusCode, body, scope, key)
+	return err
+}
+
+func (r *mysqlIdempotencyRepository) Release(ctx context.Context, scope, key string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_key WHERE scope = ? AND idem_key = ?`, scope, key)
+	return err
+}
+
+func (r *mysqlIdempotencyRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	var total int64
+	for {
+		result, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_key WHERE expires_at <= ? LIMIT ?`,
+			time.Now(), idempotencyDeleteBatch)
+		if err != nil {
+			return total, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if n < idempotencyDeleteBatch {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryIdempotencyRepository struct {
+	mu      sync.Mutex
+	records map[[2]string]IdempotencyRecord
+}
+
+// NewMemoryIdempotencyRepository 返回基于内存的 IdempotencyRepository
+func NewMemoryIdempotencyRepository() IdempotencyRepository {
+	return &memoryIdempotencyRepository{
+		records: make(map[[2]string]IdempotencyRecord),
+	}
+}
+
+func (r *memoryIdempotencyRepository) Reserve(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	id := [2]string{record.Scope, record.Key}
+	if existing, ok := r.records[id]; ok && existing.ExpiresAt.After(now) && !existing.stale(now) {
+		return &existing, nil
+	}
+
+	record.CreatedAt = now
+	stored := *record
+	stored.StatusCode = 0
+	stored.ResponseBody = nil
+	r.records[id] = stored
+	return nil, nil
+}
+
+func (r *memoryIdempotencyRepository) Complete(ctx context.Context, scope, key string, statusCode int, body []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := [2]string{scope, key}
+	record, ok := r.records[id]
+	if !ok {
+		return nil
+	}
+	record.StatusCode = statusCode
+	record.ResponseBody = append([]byte(nil), body...)
+	r.records[id] = record
+	return nil
+}
+
+func (r *memoryIdempotencyRepository) Release(ctx context.Context, scope, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.records, [2]string{scope, key})
+	return nil
+}
+
+func (r *memoryIdempotencyRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	now := time.Now()
+	for id, record := range r.records {
+		if !record.ExpiresAt.After(now) {
+			delete(r.records, id)
+			n++
+		}
+	}
+	return n, nil
+}
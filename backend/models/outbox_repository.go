@@ -0,0 +1,214 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// 投递记录的状态
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// maxDeliveryError last_error 列的长度
+const maxDeliveryError = 1000
+
+// ErrLeaseExpired 租约已过期且记录被重新领取，本次投递的结果不再写回
+var ErrLeaseExpired = errors.New("outbox delivery lease expired")
+
+// Delivery 一个事件对一个接收方的投递
+type Delivery struct {
+	ID   int64
+	Sink string
+	// Attempts 包括本次在内的尝试次数
+	Attempts int
+	Event    Event
+	// ClaimToken 领取时写入的标记，写回结果时用于确认租约仍属于本次领取
+	ClaimToken string
+}
+
+// OutboxRepository 抽象 outbox_events 和 outbox_deliveries 的读写。事件由 UserRepository 在业务事务中写入，
+// 这里只负责分发和投递状态。多个实例可以同时处理，领取投递记录时以租约互斥
+type OutboxRepository interface {
+	// DispatchEvents 按写入顺序取最多 limit 个尚未分发的事件，按 sinks 返回的接收方生成投递记录，
+	// 返回处理的事件数。没有接收方的事件也标记为已分发
+	DispatchEvents(ctx context.Context, limit int, sinks func(eventType string) []string) (int, error)
+	// ClaimDeliveries 领取最多 limit 条到期的待投递记录并把尝试次数加一，lease 内其他实例不会再领取。
+	// 实例在租约内崩溃时，租约到期后记录会被重新领取
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error)
+	// CompleteDelivery 标记投递成功。记录已被其他实例重新领取时返回 ErrLeaseExpired，不做修改
+	CompleteDelivery(ctx context.Context, d Delivery) error
+	// FailDelivery 记录失败原因。final 为 true 时不再重试，否则在 retryAt 之后重新领取。
+	// 记录已被其他实例重新领取时返回 ErrLeaseExpired，不做修改
+	FailDelivery(ctx context.Context, d Delivery, reason string, retryAt time.Time, final bool) error
+}
+
+type mysqlOutboxRepository struct {
+	db *sql.DB
+}
+
+// NewMySQLOutboxRepository 返回基于 MySQL 的 OutboxRepository
+func NewMySQLOutboxRepository(db *sql.DB) OutboxRepository {
+	return &mysqlOutboxRepository{db: db}
+}
+
+// insertEvent 在业务事务中写入事件
+func insertEvent(ctx context.Context, tx *sql.Tx, event Event) error {
+	payload, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO outbox_events (event_id, event_type, payload, created_at) VALUES (?, ?, ?, ?)`
+	_, err = tx.ExecContext(ctx, query, event.ID, event.Type, payload, event.OccurredAt)
+	return err
+}
+
+func (r *mysqlOutboxRepository) DispatchEvents(ctx context.Context, limit int, sinks func(eventType string) []string) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// 加锁避免多个实例重复分发，投递记录的唯一索引兜底
+	query := `SELECT id, event_type FROM outbox_events WHERE dispatched_at IS NULL ORDER BY id LIMIT ? FOR UPDATE`
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return 0, err
+	}
+	type pendingEvent struct {
+		id        int64
+		eventType string
+	}
+	var events []pendingEvent
+	for rows.Next() {
+		var e pendingEvent
+		if err := rows.Scan(&e.id, &e.eventType); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	now := time.Now()
+	ids := make([]interface{}, 0, len(events)+1)
+	ids = append(ids, now)
+	for _, e := range events {
+		for _, sink := range sinks(e.eventType) {
+			query := `INSERT IGNORE INTO outbox_deliveries (event_id, sink, status, next_attempt_at, created_at)
+			          VALUES (?, ?, ?, ?, ?)`
+			if _, err := tx.ExecContext(ctx, query, e.id, sink, DeliveryPending, now, now); err != nil {
+				return 0, err
+			}
+		}
+		ids = append(ids, e.id)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(events)), ",")
+	if _, err := tx.ExecContext(ctx, `UPDATE outbox_events SET dispatched_at = ? WHERE id IN (`+placeholders+`)`, ids...); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(events), nil
+}
+
+func (r *mysqlOutboxRepository) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error) {
+	token, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+
+	// 先用一条 UPDATE 写入领取标记，再按标记读出，不需要显式事务
+	now := time.Now()
+	query := `UPDATE outbox_deliveries SET claim_token = ?, locked_until = ?, attempts = attempts + 1
+	          WHERE status = ? AND next_attempt_at <= ? AND (locked_until IS NULL OR locked_until <= ?)
+	          ORDER BY next_attempt_at, id LIMIT ?`
+	result, err := r.db.ExecContext(ctx, query, token, now.Add(lease), DeliveryPending, now, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return []Delivery{}, err
+	}
+
+	query = `SELECT d.id, d.sink, d.attempts, e.event_id, e.event_type, e.payload, e.created_at
+	         FROM outbox_deliveries d JOIN outbox_events e ON e.id = d.event_id
+	         WHERE d.claim_token = ? ORDER BY d.next_attempt_at, d.id`
+	rows, err := r.db.QueryContext(ctx, query, token)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		var d Delivery
+		var payload []byte
+		if err := rows.Scan(&d.ID, &d.Sink, &d.Attempts, &d.Event.ID, &d.Event.Type, &payload, &d.Event.OccurredAt); err != nil {
+			return nil, err
+		}
+		d.ClaimToken = token
+		if err := json.Unmarshal(payload, &d.Event.Data); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (r *mysqlOutboxRepository) CompleteDelivery(ctx context.Context, d Delivery) error {
+	query := `UPDATE outbox_deliveries SET status = ?, delivered_at = ?, locked_until = NULL, claim_token = NULL
+	          WHERE id = ? AND claim_token = ?`
+	result, err := r.db.ExecContext(ctx, query, DeliveryDelivered, time.Now(), d.ID, d.ClaimToken)
+	return leaseResult(result, err)
+}
+
+func (r *mysqlOutboxRepository) FailDelivery(ctx context.Context, d Delivery, reason string, retryAt time.Time, final bool) error {
+	status := DeliveryPending
+	if final {
+		status = DeliveryFailed
+	}
+	query := `UPDATE outbox_deliveries SET status = ?, last_error = ?, next_attempt_at = ?,
+	          locked_until = NULL, claim_token = NULL WHERE id = ? AND claim_token = ?`
+	result, err := r.db.ExecContext(ctx, query, status, truncateError(reason), retryAt, d.ID, d.ClaimToken)
+	return leaseResult(result, err)
+}
+
+// leaseResult 没有更新到记录时说明 claim_token 已被其他实例的领取覆盖
+func leaseResult(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLeaseExpired
+	}
+	return nil
+}
+
+// truncateError 按字符截断，避免超出 last_error 的长度
+func truncateError(reason string) string {
+	runes := []rune(reason)
+	if len(runes) <= maxDeliveryError {
+		return reason
+	}
+	return string(runes[:maxDeliveryError])
+}
@@ -0,0 +1,161 @@
+package models
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryOutbox 内存中的 outbox_events 和 outbox_deliveries，由 memoryUserRepository 写入事件
+type memoryOutbox struct {
+	mu             sync.Mutex
+	events         []memoryEvent
+	nextDeliveryID int64
+	deliveries     []*memoryDelivery
+}
+
+type memoryEvent struct {
+	event      Event
+	dispatched bool
+}
+
+type memoryDelivery struct {
+	id            int64
+	sink          string
+	event         Event
+	status        string
+	attempts      int
+	nextAttemptAt time.Time
+	lockedUntil   time.Time
+	claimToken    string
+	lastError     string
+}
+
+func (o *memoryOutbox) insertEvent(event Event) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, memoryEvent{event: event})
+}
+
+func (o *memoryOutbox) eventCount() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.events)
+}
+
+// truncateEvents 丢弃第 n 个之后写入的事件，用于回滚
+func (o *memoryOutbox) truncateEvents(n int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = o.events[:n]
+}
+
+type memoryOutboxRepository struct {
+	outbox *memoryOutbox
+}
+
+// NewMemoryOutboxRepository 返回读取 users 中事件的内存 OutboxRepository，users 必须由 NewMemoryUserRepository 创建
+func NewMemoryOutboxRepository(users UserRepository) OutboxRepository {
+	return &memoryOutboxRepository{outbox: users.(*memoryUserRepository).outbox}
+}
+
+func (r *memoryOutboxRepository) DispatchEvents(ctx context.Context, limit int, sinks func(eventType string) []string) (int, error) {
+	o := r.outbox
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now()
+	n := 0
+	for i := range o.events {
+		if n == limit {
+			break
+		}
+		e := &o.events[i]
+		if e.dispatched {
+			continue
+		}
+		for _, sink := range sinks(e.event.Type) {
+			o.nextDeliveryID++
+			o.deliveries = append(o.deliveries, &memoryDelivery{
+				id:            o.nextDeliveryID,
+				sink:          sink,
+				event:         e.event,
+				status:        DeliveryPending,
+				nextAttemptAt: now,
+			})
+		}
+		e.dispatched = true
+		n++
+	}
+	return n, nil
+}
+
+func (r *memoryOutboxRepository) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error) {
+	o := r.outbox
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	now := time.Now()
+	var due []*memoryDelivery
+	for _, d := range o.deliveries {
+		if d.status == DeliveryPending && !d.nextAttemptAt.After(now) && !d.lockedUntil.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].nextAttemptAt.Before(due[j].nextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	token, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+	deliveries := make([]Delivery, 0, len(due))
+	for _, d := range due {
+		d.attempts++
+		d.lockedUntil = now.Add(lease)
+		d.claimToken = token
+		deliveries = append(deliveries, Delivery{ID: d.id, Sink: d.sink, Attempts: d.attempts, Event: d.event, ClaimToken: token})
+	}
+	return deliveries, nil
+}
+
+func (r *memoryOutboxRepository) CompleteDelivery(ctx context.Context, delivery Delivery) error {
+	return r.update(delivery, func(d *memoryDelivery) {
+		d.status = DeliveryDelivered
+	})
+}
+
+func (r *memoryOutboxRepository) FailDelivery(ctx context.Context, delivery Delivery, reason string, retryAt time.Time, final bool) error {
+	return r.update(delivery, func(d *memoryDelivery) {
+		d.status = DeliveryPending
+		if final {
+			d.status = DeliveryFailed
+		}
+		d.lastError = truncateError(reason)
+		d.nextAttemptAt = retryAt
+	})
+}
+
+// update 与 MySQL 实现相同，claim_token 不匹配时返回 ErrLeaseExpired
+func (r *memoryOutboxRepository) update(delivery Delivery, fn func(d *memoryDelivery)) error {
+	o := r.outbox
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, d := range o.deliveries {
+		if d.id == delivery.ID {
+			if d.claimToken == "" || d.claimToken != delivery.ClaimToken {
+				return ErrLeaseExpired
+			}
+			fn(d)
+			d.lockedUntil = time.Time{}
+			d.claimToken = ""
+			return nil
+		}
+	}
+	return ErrLeaseExpired
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
+// statusTransitions 审核状态机：pending 只能被通过或拒绝，
+// approved/rejected 只能通过 reopen 回到 pending
+var statusTransitions = map[string][]string{
+	StatusPending:  {StatusApproved, StatusRejected},
+	StatusApproved: {StatusPending},
+	StatusRejected: {StatusPending},
+}
+
+var (
+	// ErrRejectReasonRequired 拒绝时没有填写原因
+	ErrRejectReasonRequired = errors.New("reason is required when rejecting")
+	// ErrRolledBack 批量审核要求全部成功时，因其他记录失败而未生效
+	ErrRolledBack = errors.New("rolled back because another item failed")
+)
+
+// StatusChangeResult 批量审核中单条记录的结果，Err 为 nil 表示已生效
+type StatusChangeResult struct {
+	ID  int64
+	Err error
+}
+
+// TransitionError 状态机不允许的状态变更
+type TransitionError struct {
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("status transition from %s to %s is not allowed", e.From, e.To)
+}
+
+// CanTransition 判断状态机是否允许从 from 变更到 to
+func CanTransition(from, to string) bool {
+	for _, next := range statusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate 检查变更本身是否完整，与当前状态无关
+func (c StatusChange) Validate() error {
+	if c.Status == StatusRejected && c.Reason == "" {
+		return ErrRejectReasonRequired
+	}
+	return nil
+}
+
+// checkTransition 在仓库实现中读取当前状态后调用，保证校验与写入在同一事务内
+func checkTransition(from string, change StatusChange) error {
+	if err := change.Validate(); err != nil {
+		return err
+	}
+	if !CanTransition(from, change.Status) {
+		return &TransitionError{From: from, To: change.Status}
+	}
+	return nil
+}
+
+// planStatusChanges 根据加锁后读到的当前状态逐条校验批量变更，
+// current 中不存在的 id 视为记录不存在
+func planStatusChanges(ids []int64, current map[int64]string, change StatusChange, atomic bool) []StatusChangeResult {
+	results := make([]StatusChangeResult, len(ids))
+	failed := false
+	for i, id := range ids {
+		results[i].ID = id
+		from, ok := current[id]
+		if !ok {
+			results[i].Err = ErrUserNotFound
+		} else {
+			results[i].Err = checkTransition(from, change)
+		}
+		if results[i].Err != nil {
+			failed = true
+		}
+	}
+
+	if atomic && failed {
+		for i := range results {
+			if results[i].Err == nil {
+				results[i].Err = ErrRolledBack
+			}
+		}
+	}
+	return results
+}
+
+// uniqueIDs 去重并保持原有顺序
+func uniqueIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	unique := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+)
+
+// NewTrackingToken 生成 256 位随机数的 URL 安全编码，作为提交记录的查询凭证
+func NewTrackingToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func ensureTrackingToken(user *UserInfo) error {
+	if user.TrackingToken != "" {
+		return nil
+	}
+	token, err := NewTrackingToken()
+	if err != nil {
+		return err
+	}
+	user.TrackingToken = token
+	return nil
+}
@@ -1,28 +1,138 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"time"
+	"tuna/validation"
+)
+
+// ErrUserNotFound 要修改的用户记录不存在
+var ErrUserNotFound = errors.New("user not found")
 
 type UserInfo struct {
-	ID          int64     `json:"id" db:"id"`
-	Name        string    `json:"name" db:"name"`
-	Email       string    `json:"email" db:"email"`
-	Phone       string    `json:"phone" db:"phone"`
-	Hobby       string    `json:"hobby" db:"hobby"`
-	Age         int       `json:"age" db:"age"`
-	Status      string    `json:"status" db:"status"` // pending, approved, rejected
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+	ID            int64  `json:"id" db:"id"`
+	Name          string `json:"name" db:"name"`
+	Email         string `json:"email" db:"email"`
+	Phone         string `json:"phone" db:"phone"`
+	Hobby         string `json:"hobby" db:"hobby"`
+	Age           int    `json:"age" db:"age"`
+	Status        string `json:"status" db:"status"` // pending, approved, rejected
+	RejectReason  string `json:"reject_reason" db:"reject_reason"`
+	ReviewNotes   string `json:"review_notes" db:"review_notes"`
+	TrackingToken string `json:"-" db:"tracking_token"` // 提交者查询审核进度的凭证，只在提交时返回
+	// EmailNormalized、PhoneE164 为规范化后的邮箱和 E.164 手机号，用于查重和搜索，由仓库层写入时计算
+	EmailNormalized string    `json:"-" db:"email_normalized"`
+	PhoneE164       string    `json:"-" db:"phone_e164"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
 }
 
 type CreateUserRequest struct {
 	Name  string `json:"name" binding:"required"`
 	Email string `json:"email" binding:"required,email"`
-	Phone string `json:"phone" binding:"required"`
+	Phone string `json:"phone" binding:"required,phone"`
 	Hobby string `json:"hobby" binding:"required"`
 	Age   int    `json:"age" binding:"required,min=1,max=150"`
 }
 
+// SubmitResponse POST /api/submit 的响应
+type SubmitResponse struct {
+	Message       string `json:"message"`
+	ID            int64  `json:"id"`
+	TrackingToken string `json:"tracking_token"`
+	Merged        bool   `json:"merged,omitempty"`
+}
+
+// MessageResponse 只返回一条提示信息的成功响应
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// SubmissionStatusResponse 凭 tracking token 查询到的审核进度，不包含个人信息
+type SubmissionStatusResponse struct {
+	Status       string    `json:"status"`
+	RejectReason string    `json:"reject_reason,omitempty"`
+	SubmittedAt  time.Time `json:"submitted_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// UpdateStatusRequest 审核通过或拒绝，拒绝时必须填写原因；回到 pending 需走 ReopenRequest
 type UpdateStatusRequest struct {
 	Status string `json:"status" binding:"required,oneof=approved rejected"`
+	Reason string `json:"reason" binding:"required_if=Status rejected,max=500"`
+	Notes  string `json:"notes" binding:"max=1000"`
 }
 
+// BulkUpdateStatusRequest PUT /admin/users/status 的请求体，
+// mode 为 all_or_nothing（默认，任意一条失败则全部不生效）或 best_effort
+type BulkUpdateStatusRequest struct {
+	IDs    []int64 `json:"ids" binding:"required,min=1,max=500,dive,min=1"`
+	Status string  `json:"status" binding:"required,oneof=approved rejected"`
+	Reason string  `json:"reason" binding:"required_if=Status rejected,max=500"`
+	Notes  string  `json:"notes" binding:"max=1000"`
+	Mode   string  `json:"mode" binding:"omitempty,oneof=all_or_nothing best_effort"`
+}
+
+const (
+	BulkModeAllOrNothing = "all_or_nothing"
+	BulkModeBestEffort   = "best_effort"
+)
+
+// BulkStatusItemResult 批量审核中单个 id 的结果
+type BulkStatusItemResult struct {
+	ID      int64  `json:"id"`
+	Success bool   `json:"success"`
+	Code    string `json:"code,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+type BulkUpdateStatusResponse struct {
+	Mode      string                 `json:"mode"`
+	Succeeded int                    `json:"succeeded"`
+	Failed    int                    `json:"failed"`
+	Results   []BulkStatusItemResult `json:"results"`
+}
+
+// ReopenRequest 把已审核的记录重新打开为 pending
+type ReopenRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// StatusChange 一次审核状态变更，Actor 为操作的管理员用户名
+type StatusChange struct {
+	Status string
+	Actor  string
+	Reason string
+	Notes  string
+}
+
+// reviewNotes 变更后的审核备注。change 没有填写备注时保留原有的备注，reopen 等操作不会清空审核员的记录
+func reviewNotes(user *UserInfo, change StatusChange) string {
+	if change.Notes == "" {
+		return user.ReviewNotes
+	}
+	return change.Notes
+}
+
+// StatusHistory status_history 表中的一条审核记录
+type StatusHistory struct {
+	ID        int64     `json:"id" db:"id"`
+	UserID    int64     `json:"user_id" db:"user_id"`
+	Actor     string    `json:"actor" db:"actor"`
+	OldStatus string    `json:"old_status" db:"old_status"`
+	NewStatus string    `json:"new_status" db:"new_status"`
+	Reason    string    `json:"reason" db:"reason"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// UserHistoryResponse GET /admin/users/:id/history 的响应，History 按时间先后排列
+type UserHistoryResponse struct {
+	User    UserInfo        `json:"user"`
+	History []StatusHistory `json:"history"`
+}
+
+// normalizeContact 计算规范化的邮箱和手机号，手机号无法解析时留空
+func normalizeContact(user *UserInfo) {
+	user.EmailNormalized = validation.NormalizeEmail(user.Email)
+	user.PhoneE164, _ = validation.NormalizePhone(user.Phone)
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"strings"
+	"tuna/validation"
+)
+
+// 导出文件格式
+const (
+	ExportCSV   = "csv"
+	ExportXLSX  = "xlsx"
+	ExportJSONL = "jsonl"
+)
+
+// ExportUsersRequest 导出接口的查询参数，筛选条件与列表接口相同。
+// columns 为逗号分隔的列名，为空时使用默认列；lang 决定表头语言
+type ExportUsersRequest struct {
+	UserFilterRequest
+	Format  string `form:"format" binding:"omitempty,oneof=csv xlsx jsonl"`
+	Columns string `form:"columns"`
+	Lang    string `form:"lang" binding:"omitempty,oneof=zh en"`
+	SortBy  string `form:"sort_by" binding:"omitempty,oneof=id name age status created_at updated_at"`
+	Order   string `form:"order" binding:"omitempty,oneof=asc desc"`
+}
+
+// ToOptions 填充默认值（csv、中文表头、按创建时间倒序）并转换为仓库层的查询参数，
+// 导出不分页，Offset/Limit 为 0
+func (r *ExportUsersRequest) ToOptions() (ListUsersOptions, error) {
+	filter, err := r.UserFilterRequest.ToFilter()
+	if err != nil {
+		return ListUsersOptions{}, err
+	}
+
+	if r.Format == "" {
+		r.Format = ExportCSV
+	}
+	if r.Lang == "" {
+		r.Lang = "zh"
+	}
+	if r.SortBy == "" {
+		r.SortBy = "created_at"
+	}
+	if r.Order == "" {
+		r.Order = "desc"
+	}
+
+	return ListUsersOptions{Filter: filter, SortBy: r.SortBy, Order: r.Order}, nil
+}
+
+// ColumnList 拆分 columns 参数，去掉空白和重复项；allowed 之外的列名返回错误
+func (r *ExportUsersRequest) ColumnList(allowed func(string) bool) ([]string, error) {
+	var columns []string
+	seen := map[string]bool{}
+	for _, name := range strings.Split(r.Columns, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		if !allowed(name) {
+			return nil, &validation.FieldError{Field: "columns", Rule: "export_column", Param: name}
+		}
+		seen[name] = true
+		columns = append(columns, name)
+	}
+	return columns, nil
+}
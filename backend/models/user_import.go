@@ -0,0 +1,42 @@
+package models
+
+// ImportResult 批量导入中单条记录的结果，Err 为 nil 表示已写入
+type ImportResult struct {
+	Merged bool
+	Err    error
+}
+
+// ImportUsersRequest 导入接口的表单参数，文件放在 file 字段中。
+// status 为导入后的审核状态，rejected 时 reason 必填；duplicate 为查重方式，默认 reject
+type ImportUsersRequest struct {
+	DryRun    bool   `form:"dry_run"`
+	Status    string `form:"status" binding:"omitempty,oneof=pending approved rejected"`
+	Reason    string `form:"reason" binding:"required_if=Status rejected,max=500"`
+	Duplicate string `form:"duplicate" binding:"omitempty,oneof=reject merge allow"`
+}
+
+// ImportFieldError 某一行中某个字段的错误，Field 为空表示整行的错误。
+// Code 为校验规则名（如 email）或错误码（如 duplicate_submission），Error 为本地化后的提示
+type ImportFieldError struct {
+	Field string `json:"field,omitempty"`
+	Code  string `json:"code"`
+	Error string `json:"error"`
+}
+
+// ImportRowError 文件中某一行的错误，Row 为文件中的行号（表头为第 1 行）
+type ImportRowError struct {
+	Row    int                `json:"row"`
+	Errors []ImportFieldError `json:"errors"`
+}
+
+// ImportUsersResponse Valid 为通过格式校验的行数，Imported、Merged 为写入和合并的行数，
+// dry_run 时为实际导入会写入和合并的行数，查重失败的行同样出现在 Errors 中
+type ImportUsersResponse struct {
+	DryRun   bool             `json:"dry_run"`
+	Total    int              `json:"total"`
+	Valid    int              `json:"valid"`
+	Imported int              `json:"imported"`
+	Merged   int              `json:"merged"`
+	Failed   int              `json:"failed"`
+	Errors   []ImportRowError `json:"errors"`
+}
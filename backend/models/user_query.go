@@ -0,0 +1,161 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"tuna/validation"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// UserFilter 用户列表的筛选条件，时间区间为 [CreatedFrom, CreatedTo)，零值表示不限制
+type UserFilter struct {
+	Status      string
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	AgeMin      int
+	AgeMax      int
+	Keyword     string
+}
+
+// ListUsersOptions 描述一次分页查询
+type ListUsersOptions struct {
+	Filter UserFilter
+	SortBy string // 取值见 userSortColumns
+	Order  string // asc 或 desc
+	Offset int
+	Limit  int
+}
+
+// userSortColumns 允许排序的字段，同时作为 SQL 列名白名单
+var userSortColumns = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"age":        "age",
+	"status":     "status",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// UserFilterRequest 筛选参数的 query string 形式
+type UserFilterRequest struct {
+	Status      string `form:"status" binding:"omitempty,oneof=pending approved rejected"`
+	CreatedFrom string `form:"created_from"`
+	CreatedTo   string `form:"created_to"`
+	AgeMin      int    `form:"age_min" binding:"omitempty,min=1,max=150"`
+	AgeMax      int    `form:"age_max" binding:"omitempty,min=1,max=150"`
+	Keyword     string `form:"keyword" binding:"omitempty,max=100"`
+}
+
+// ListUsersRequest GET /admin/users 的查询参数
+type ListUsersRequest struct {
+	UserFilterRequest
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+	SortBy   string `form:"sort_by" binding:"omitempty,oneof=id name age status created_at updated_at"`
+	Order    string `form:"order" binding:"omitempty,oneof=asc desc"`
+}
+
+// ListUsersResponse 分页查询结果
+type ListUsersResponse struct {
+	Users      []UserInfo `json:"users"`
+	Total      int64      `json:"total"`
+	Page       int        `json:"page"`
+	PageSize   int        `json:"page_size"`
+	TotalPages int        `json:"total_pages"`
+}
+
+// ToFilter 解析时间参数，created_from/created_to 支持 RFC3339 或 2006-01-02，
+// 只给日期时 created_to 包含当天
+func (r *UserFilterRequest) ToFilter() (UserFilter, error) {
+	f := UserFilter{
+		Status:  r.Status,
+		AgeMin:  r.AgeMin,
+		AgeMax:  r.AgeMax,
+		Keyword: strings.TrimSpace(r.Keyword),
+	}
+
+	if r.CreatedFrom != "" {
+		t, _, err := parseTimeParam(r.CreatedFrom)
+		if err != nil {
+			return f, &validation.FieldError{Field: "created_from", Rule: "datetime"}
+		}
+		f.CreatedFrom = t
+	}
+	if r.CreatedTo != "" {
+		t, dateOnly, err := parseTimeParam(r.CreatedTo)
+		if err != nil {
+			return f, &validation.FieldError{Field: "created_to", Rule: "datetime"}
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		f.CreatedTo = t
+	}
+
+	if f.AgeMin > 0 && f.AgeMax > 0 && f.AgeMin > f.AgeMax {
+		return f, &validation.FieldError{Field: "age_min", Rule: "ltefield", Param: "age_max"}
+	}
+	if !f.CreatedFrom.IsZero() && !f.CreatedTo.IsZero() && !f.CreatedFrom.Before(f.CreatedTo) {
+		return f, &validation.FieldError{Field: "created_from", Rule: "ltfield", Param: "created_to"}
+	}
+	return f, nil
+}
+
+// ToOptions 填充默认值并转换为仓库层的查询参数
+func (r *ListUsersRequest) ToOptions() (ListUsersOptions, error) {
+	filter, err := r.UserFilterRequest.ToFilter()
+	if err != nil {
+		return ListUsersOptions{}, err
+	}
+
+	if r.Page == 0 {
+		r.Page = 1
+	}
+	if r.PageSize == 0 {
+		r.PageSize = DefaultPageSize
+	}
+	if r.SortBy == "" {
+		r.SortBy = "created_at"
+	}
+	if r.Order == "" {
+		r.Order = "desc"
+	}
+
+	return ListUsersOptions{
+		Filter: filter,
+		SortBy: r.SortBy,
+		Order:  r.Order,
+		Offset: (r.Page - 1) * r.PageSize,
+		Limit:  r.PageSize,
+	}, nil
+}
+
+func parseTimeParam(value string) (t time.Time, dateOnly bool, err error) {
+	if t, err = time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, true, nil
+	}
+	t, err = time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
+// phoneSearchKey 关键字去掉分隔符后是至少 4 位的号码时，返回用于匹配 phone_e164 的部分，
+// 这样 "138 0013 8000" 也能搜到 +8613800138000
+func phoneSearchKey(keyword string) string {
+	key := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(keyword))
+
+	digits := strings.TrimPrefix(key, "+")
+	if len(digits) < 4 || strings.TrimLeft(digits, "0123456789") != "" {
+		return ""
+	}
+	return key
+}
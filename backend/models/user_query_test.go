@@ -0,0 +1,62 @@
+package models
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPhoneSearchKey(t *testing.T) {
+	for _, tc := range []struct{ keyword, want string }{
+		{"13800138000", "13800138000"},
+		{"138 0013 8000", "13800138000"},
+		{"138-0013-8000", "13800138000"},
+		{"(138) 0013.8000", "13800138000"},
+		{"+86 138 0013 8000", "+8613800138000"},
+		{" 8000 ", "8000"},
+		// 太短或不是号码时不按手机号匹配
+		{"800", ""},
+		{"+800", ""},
+		{"张三", ""},
+		{"138abc", ""},
+		{"86+138", ""},
+		{"", ""},
+	} {
+		if got := phoneSearchKey(tc.keyword); got != tc.want {
+			t.Errorf("phoneSearchKey(%q) = %q, want %q", tc.keyword, got, tc.want)
+		}
+	}
+}
+
+func TestKeywordSearchByPhone(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryUserRepository()
+	for _, user := range []UserInfo{
+		{Name: "张三", Age: 25, Email: "zhangsan@example.com", Phone: "138 0013 8000", PhoneE164: "+8613800138000"},
+		{Name: "李四", Age: 32, Email: "lisi@example.com", Phone: "+1 201 555 0123", PhoneE164: "+12015550123"},
+	} {
+		if _, err := repo.CreateUserInfo(ctx, &user, DuplicateReject); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 不论输入时是否带区号、用什么分隔符，都能搜到规范化后的号码
+	for _, tc := range []struct {
+		keyword string
+		want    string
+	}{
+		{"13800138000", "张三"},
+		{"138-0013-8000", "张三"},
+		{"+86 138 0013 8000", "张三"},
+		{"+8613800138000", "张三"},
+		{"(201) 555-0123", "李四"},
+		{"+12015550123", "李四"},
+	} {
+		users, total, err := repo.ListUsers(ctx, ListUsersOptions{Filter: UserFilter{Keyword: tc.keyword}, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 1 || len(users) != 1 || users[0].Name != tc.want {
+			t.Errorf("keyword %q: found %d users %+v, want %s", tc.keyword, total, users, tc.want)
+		}
+	}
+}
@@ -1,66 +1,523 @@
 package models
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"strings"
 	"time"
-	"tuna/database"
+	"tuna/logging"
+	"tuna/validation"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/sirupsen/logrus"
 )
 
-func CreateUserInfo(user *UserInfo) error {
-	query := `INSERT INTO user_info_tab (name, email, phone, hobby, age, status, created_at, updated_at) 
-	          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
-	
+// UserRepository 抽象 user_info_tab 的读写，api 和 admin 的 handler 只依赖该接口
+type UserRepository interface {
+	// CreateUserInfo 新建待审核记录。policy 不是 allow 时，若已有相同邮箱或手机号的待审核记录，
+	// reject 返回 ErrDuplicateSubmission；merge 在邮箱和手机号都相同时用 user 覆盖该记录、换发查询凭证
+	// 并返回 merged=true，只有一项相同时同样返回 ErrDuplicateSubmission
+	CreateUserInfo(ctx context.Context, user *UserInfo, policy DuplicatePolicy) (merged bool, err error)
+	// ListUsers 按条件分页查询，同时返回满足条件的总数
+	ListUsers(ctx context.Context, opts ListUsersOptions) ([]UserInfo, int64, error)
+	// CountUsers 返回满足条件的记录数
+	CountUsers(ctx context.Context, filter UserFilter) (int64, error)
+	// StreamUsers 按 opts 的筛选和排序逐条读取记录并交给 fn，忽略 Offset/Limit，
+	// 不会把结果一次性加载到内存。fn 返回错误时停止读取并原样返回该错误
+	StreamUsers(ctx context.Context, opts ListUsersOptions, fn func(*UserInfo) error) error
+	// UpdateUserStatus 按状态机修改审核状态并在同一事务中写入 status_history。
+	// 记录不存在时返回 ErrUserNotFound，状态机不允许时返回 *TransitionError
+	UpdateUserStatus(ctx context.Context, id int64, change StatusChange) error
+	// BulkUpdateUserStatus 在一个事务中修改多条记录的审核状态，返回每个 id 的结果。
+	// atomic 为 true 时任意一条失败则全部不生效；返回的 error 只表示数据库错误
+	BulkUpdateUserStatus(ctx context.Context, ids []int64, change StatusChange, atomic bool) ([]StatusChangeResult, error)
+	// ListStatusHistory 按时间先后返回某条记录的审核历史
+	ListStatusHistory(ctx context.Context, userID int64) ([]StatusHistory, error)
+	// GetUserByID 在记录不存在时返回 nil, nil
+	GetUserByID(ctx context.Context, id int64) (*UserInfo, error)
+	// GetUserByTrackingToken 按提交时返回的查询凭证查找记录，不存在时返回 nil, nil
+	GetUserByTrackingToken(ctx context.Context, token string) (*UserInfo, error)
+	// ImportUsers 在一个事务中写入一批记录，状态由调用方在 Status 中指定，查重规则与 CreateUserInfo 相同，
+	// 只对 pending 记录生效；重复的记录对应结果的 Err 为 ErrDuplicateSubmission，其余记录照常写入。
+	// 非 pending 的记录同时写入一条以 actor 为操作人的审核历史。dryRun 为 true 时照常执行后回滚事务，
+	// 结果与实际导入相同但不保存。返回的 error 只表示数据库错误
+	ImportUsers(ctx context.Context, users []*UserInfo, policy DuplicatePolicy, actor string, dryRun bool) ([]ImportResult, error)
+}
+
+// userColumns 与 scanUser 的字段顺序一一对应
+const userColumns = `id, name, email, phone, hobby, age, status, reject_reason, review_notes,
+	COALESCE(tracking_token, ''), COALESCE(email_normalized, ''), COALESCE(phone_e164, ''), created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner, user *UserInfo) error {
+	return row.Scan(&user.ID, &user.Name, &user.Email, &user.Phone, &user.Hobby, &user.Age,
+		&user.Status, &user.RejectReason, &user.ReviewNotes, &user.TrackingToken, &user.EmailNormalized, &user.PhoneE164,
+		&user.CreatedAt, &user.UpdatedAt)
+}
+
+type mysqlUserRepository struct {
+	db *sql.DB
+}
+
+// NewMySQLUserRepository 返回基于 MySQL 的 UserRepository
+func NewMySQLUserRepository(db *sql.DB) UserRepository {
+	return &mysqlUserRepository{db: db}
+}
+
+func (r *mysqlUserRepository) CreateUserInfo(ctx context.Context, user *UserInfo, policy DuplicatePolicy) (bool, error) {
+	user.Status = StatusPending
+	if err := ensureTrackingToken(user); err != nil {
+		return false, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
 	now := time.Now()
-	_, err := database.DB.Exec(query, user.Name, user.Email, user.Phone, user.Hobby, user.Age, 
-		"pending", now, now)
-	return err
+	merged, err := insertUser(ctx, tx, user, policy, now)
+	if err != nil {
+		return false, err
+	}
+	if err := writeCreatedEvent(ctx, tx, user, EventSourceAPI, merged, now); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	logging.FromContext(ctx).WithFields(logrus.Fields{"user_id": user.ID, "merged": merged}).Debug("user info saved")
+	return merged, nil
+}
+
+// insertUser 按 user.Status 写入一条记录，查重逻辑见 CreateUserInfo，只对 pending 记录生效
+func insertUser(ctx context.Context, tx *sql.Tx, user *UserInfo, policy DuplicatePolicy, now time.Time) (bool, error) {
+	normalizeContact(user)
+	pendingEmail, pendingPhone := dedupeKeys(user, policy)
+
+	if pendingEmail.Valid || pendingPhone.Valid {
+		query := `SELECT id, pending_email, pending_phone FROM user_info_tab
+		          WHERE pending_email = ? OR pending_phone = ? FOR UPDATE`
+		rows, err := tx.QueryContext(ctx, query, pendingEmail, pendingPhone)
+		if err != nil {
+			return false, err
+		}
+		var ids []int64
+		var existing [2]sql.NullString
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id, &existing[0], &existing[1]); err != nil {
+				rows.Close()
+				return false, err
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return false, err
+		}
+
+		if len(ids) > 0 {
+			if policy != DuplicateMerge || len(ids) > 1 || !canMerge(existing, [2]sql.NullString{pendingEmail, pendingPhone}) {
+				return false, ErrDuplicateSubmission
+			}
+			// 合并时换发新的查询凭证，原凭证随之失效
+			query := `UPDATE user_info_tab SET name = ?, email = ?, phone = ?, hobby = ?, age = ?,
+			          email_normalized = ?, phone_e164 = ?, pending_email = ?, pending_phone = ?,
+			          tracking_token = ?, updated_at = ? WHERE id = ?`
+			if _, err := tx.ExecContext(ctx, query, user.Name, user.Email, user.Phone, user.Hobby, user.Age,
+				nullString(user.EmailNormalized), nullString(user.PhoneE164), pendingEmail, pendingPhone,
+				user.TrackingToken, now, ids[0]); err != nil {
+				return false, err
+			}
+			user.ID = ids[0]
+			return true, nil
+		}
+	}
+
+	query := `INSERT INTO user_info_tab (name, email, phone, hobby, age, status, reject_reason, review_notes,
+	          email_normalized, phone_e164, pending_email, pending_phone, tracking_token, created_at, updated_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	result, err := tx.ExecContext(ctx, query, user.Name, user.Email, user.Phone, user.Hobby, user.Age,
+		user.Status, user.RejectReason, user.ReviewNotes, nullString(user.EmailNormalized), nullString(user.PhoneE164),
+		pendingEmail, pendingPhone, user.TrackingToken, now, now)
+	if isDuplicateKeyError(err) {
+		// 并发提交时由唯一索引兜底
+		return false, ErrDuplicateSubmission
+	}
+	if err != nil {
+		return false, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return false, err
+	}
+
+	user.ID = id
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	return false, nil
 }
 
-func GetAllUsers() ([]UserInfo, error) {
-	query := `SELECT id, name, email, phone, hobby, age, status, created_at, updated_at 
-	          FROM user_info_tab ORDER BY created_at DESC`
-	
-	rows, err := database.DB.Query(query)
+func (r *mysqlUserRepository) ImportUsers(ctx context.Context, users []*UserInfo, policy DuplicatePolicy, actor string, dryRun bool) ([]ImportResult, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	results := make([]ImportResult, len(users))
+	for i, user := range users {
+		if err := ensureTrackingToken(user); err != nil {
+			return nil, err
+		}
+		// 唯一索引冲突只回滚当前语句，事务可以继续
+		merged, err := insertUser(ctx, tx, user, policy, now)
+		if errors.Is(err, ErrDuplicateSubmission) {
+			results[i].Err = err
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		results[i].Merged = merged
+		if err := writeCreatedEvent(ctx, tx, user, EventSourceImport, merged, now); err != nil {
+			return nil, err
+		}
+
+		if user.Status != StatusPending {
+			query := `INSERT INTO status_history (user_id, actor, old_status, new_status, reason, created_at)
+			          VALUES (?, ?, ?, ?, ?, ?)`
+			if _, err := tx.ExecContext(ctx, query, user.ID, actor, StatusPending, user.Status, user.RejectReason, now); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// dry run 由 defer 回滚，自增 id 会被消耗
+	if dryRun {
+		return results, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).WithFields(logrus.Fields{"rows": len(users), "actor": actor}).Debug("users imported")
+	return results, nil
+}
+
+// writeCreatedEvent 在事务内写入 submission.created 事件
+func writeCreatedEvent(ctx context.Context, tx *sql.Tx, user *UserInfo, source string, merged bool, now time.Time) error {
+	event, err := createdEvent(user, source, merged, now)
+	if err != nil {
+		return err
+	}
+	return insertEvent(ctx, tx, event)
+}
+
+func isDuplicateKeyError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1062
+}
+
+func (r *mysqlUserRepository) ListUsers(ctx context.Context, opts ListUsersOptions) ([]UserInfo, int64, error) {
+	total, err := r.CountUsers(ctx, opts.Filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	if total == 0 {
+		return []UserInfo{}, 0, nil
+	}
+
+	where, args := buildUserFilter(opts.Filter)
+	query := `SELECT ` + userColumns + ` FROM user_info_tab` + where +
+		buildUserOrderBy(opts.SortBy, opts.Order) + ` LIMIT ? OFFSET ?`
+
+	rows, err := r.db.QueryContext(ctx, query, append(args, opts.Limit, opts.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
 	defer rows.Close()
 
-	var users []UserInfo
+	users := []UserInfo{}
 	for rows.Next() {
 		var user UserInfo
-		err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Phone, &user.Hobby, 
-			&user.Age, &user.Status, &user.CreatedAt, &user.UpdatedAt)
-		if err != nil {
-			return nil, err
+		if err := scanUser(rows, &user); err != nil {
+			return nil, 0, err
 		}
 		users = append(users, user)
 	}
 
-	return users, rows.Err()
+	return users, total, rows.Err()
+}
+
+func (r *mysqlUserRepository) CountUsers(ctx context.Context, filter UserFilter) (int64, error) {
+	where, args := buildUserFilter(filter)
+	var total int64
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM user_info_tab`+where, args...).Scan(&total)
+	return total, err
+}
+
+func (r *mysqlUserRepository) StreamUsers(ctx context.Context, opts ListUsersOptions, fn func(*UserInfo) error) error {
+	where, args := buildUserFilter(opts.Filter)
+	query := `SELECT ` + userColumns + ` FROM user_info_tab` + where + buildUserOrderBy(opts.SortBy, opts.Order)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user UserInfo
+		if err := scanUser(rows, &user); err != nil {
+			return err
+		}
+		if err := fn(&user); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// buildUserFilter 生成 WHERE 子句，条件直接作用于列上以便命中 idx_status 和 idx_created_at
+func buildUserFilter(f UserFilter) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+
+	if f.Status != "" {
+		conds = append(conds, "status = ?")
+		args = append(args, f.Status)
+	}
+	if !f.CreatedFrom.IsZero() {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, f.CreatedFrom)
+	}
+	if !f.CreatedTo.IsZero() {
+		conds = append(conds, "created_at < ?")
+		args = append(args, f.CreatedTo)
+	}
+	if f.AgeMin > 0 {
+		conds = append(conds, "age >= ?")
+		args = append(args, f.AgeMin)
+	}
+	if f.AgeMax > 0 {
+		conds = append(conds, "age <= ?")
+		args = append(args, f.AgeMax)
+	}
+	if f.Keyword != "" {
+		like := "%" + escapeLike(f.Keyword) + "%"
+		cond := "name LIKE ? OR email_normalized LIKE ? OR phone LIKE ? OR hobby LIKE ?"
+		args = append(args, like, "%"+escapeLike(validation.NormalizeEmail(f.Keyword))+"%", like, like)
+		if key := phoneSearchKey(f.Keyword); key != "" {
+			cond += " OR phone_e164 LIKE ?"
+			args = append(args, "%"+key+"%")
+		}
+		conds = append(conds, "("+cond+")")
+	}
+
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+func buildUserOrderBy(sortBy, order string) string {
+	column, ok := userSortColumns[sortBy]
+	if !ok {
+		column = "created_at"
+	}
+	direction := "DESC"
+	if order == "asc" {
+		direction = "ASC"
+	}
+	orderBy := " ORDER BY " + column + " " + direction
+	if column != "id" {
+		orderBy += ", id " + direction
+	}
+	return orderBy
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+func (r *mysqlUserRepository) UpdateUserStatus(ctx context.Context, id int64, change StatusChange) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var user UserInfo
+	err = scanUser(tx.QueryRowContext(ctx, `SELECT `+userColumns+` FROM user_info_tab WHERE id = ? FOR UPDATE`, id), &user)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := checkTransition(user.Status, change); err != nil {
+		return err
+	}
+
+	if err := writeStatusChange(ctx, tx, &user, change, time.Now()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *mysqlUserRepository) BulkUpdateUserStatus(ctx context.Context, ids []int64, change StatusChange, atomic bool) ([]StatusChangeResult, error) {
+	ids = uniqueIDs(ids)
+	if len(ids) == 0 {
+		return []StatusChangeResult{}, nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// 一次性按主键加锁，避免逐条加锁时与其他批量请求互相死锁
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := tx.QueryContext(ctx, `SELECT `+userColumns+` FROM user_info_tab WHERE id IN (`+placeholders+`) FOR UPDATE`, args...)
+	if err != nil {
+		return nil, err
+	}
+	users := make(map[int64]*UserInfo, len(ids))
+	current := make(map[int64]string, len(ids))
+	for rows.Next() {
+		var user UserInfo
+		if err := scanUser(rows, &user); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		users[user.ID] = &user
+		current[user.ID] = user.Status
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	results := planStatusChanges(ids, current, change, atomic)
+
+	now := time.Now()
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		if err := writeStatusChange(ctx, tx, users[result.ID], change, now); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
 }
 
-func UpdateUserStatus(id int64, status string) error {
-	query := `UPDATE user_info_tab SET status = ?, updated_at = ? WHERE id = ?`
-	_, err := database.DB.Exec(query, status, time.Now(), id)
-	return err
+// writeStatusChange 在事务内写入新状态、对应的 status_history 和状态变更事件，user 为变更前的记录，
+// 调用方负责加锁和状态机校验
+func writeStatusChange(ctx context.Context, tx *sql.Tx, user *UserInfo, change StatusChange, now time.Time) error {
+	id, oldStatus := user.ID, user.Status
+	rejectReason := ""
+	if change.Status == StatusRejected {
+		rejectReason = change.Reason
+	}
+	// 离开 pending 后不再参与查重，reopen 也不恢复，避免与之后的新提交冲突
+	query := `UPDATE user_info_tab SET status = ?, reject_reason = ?, review_notes = ?,
+	          pending_email = NULL, pending_phone = NULL, updated_at = ? WHERE id = ?`
+	if _, err := tx.ExecContext(ctx, query, change.Status, rejectReason, reviewNotes(user, change), now, id); err != nil {
+		return err
+	}
+
+	query = `INSERT INTO status_history (user_id, actor, old_status, new_status, reason, created_at)
+	         VALUES (?, ?, ?, ?, ?, ?)`
+	if _, err := tx.ExecContext(ctx, query, id, change.Actor, oldStatus, change.Status, change.Reason, now); err != nil {
+		return err
+	}
+	event, err := statusChangedEvent(user, change, now)
+	if err != nil {
+		return err
+	}
+	if err := insertEvent(ctx, tx, event); err != nil {
+		return err
+	}
+	// 事务提交前记录，回滚时日志中会多出一条，以 status_history 为准
+	logging.FromContext(ctx).WithFields(logrus.Fields{
+		"user_id": id,
+		"from":    oldStatus,
+		"to":      change.Status,
+		"actor":   change.Actor,
+	}).Debug("user status changed")
+	return nil
+}
+
+func (r *mysqlUserRepository) ListStatusHistory(ctx context.Context, userID int64) ([]StatusHistory, error) {
+	query := `SELECT id, user_id, actor, old_status, new_status, reason, created_at
+	          FROM status_history WHERE user_id = ? ORDER BY created_at, id`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []StatusHistory{}
+	for rows.Next() {
+		var h StatusHistory
+		if err := rows.Scan(&h.ID, &h.UserID, &h.Actor, &h.OldStatus, &h.NewStatus, &h.Reason, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, h)
+	}
+
+	return history, rows.Err()
 }
 
-func GetUserByID(id int64) (*UserInfo, error) {
-	query := `SELECT id, name, email, phone, hobby, age, status, created_at, updated_at 
-	          FROM user_info_tab WHERE id = ?`
-	
+func (r *mysqlUserRepository) GetUserByID(ctx context.Context, id int64) (*UserInfo, error) {
+	query := `SELECT ` + userColumns + ` FROM user_info_tab WHERE id = ?`
+
 	var user UserInfo
-	err := database.DB.QueryRow(query, id).Scan(&user.ID, &user.Name, &user.Email, 
-		&user.Phone, &user.Hobby, &user.Age, &user.Status, &user.CreatedAt, &user.UpdatedAt)
-	
+	err := scanUser(r.db.QueryRowContext(ctx, query, id), &user)
+
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
-	
+
 	return &user, nil
 }
 
+func (r *mysqlUserRepository) GetUserByTrackingToken(ctx context.Context, token string) (*UserInfo, error) {
+	query := `SELECT ` + userColumns + ` FROM user_info_tab WHERE tracking_token = ?`
+
+	var user UserInfo
+	err := scanUser(r.db.QueryRowContext(ctx, query, token), &user)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
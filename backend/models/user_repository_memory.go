@@ -0,0 +1,390 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryUserRepository struct {
+	mu            sync.RWMutex
+	nextID        int64
+	users         map[int64]UserInfo
+	dedupe        map[int64][2]sql.NullString // 对应 pending_email、pending_phone 列
+	nextHistoryID int64
+	history       []StatusHistory
+	outbox        *memoryOutbox
+}
+
+// NewMemoryUserRepository 返回基于内存的 UserRepository，行为与 MySQL 实现保持一致，
+// 用于在没有数据库的环境下运行 handler 测试
+func NewMemoryUserRepository() UserRepository {
+	return &memoryUserRepository{
+		users:  make(map[int64]UserInfo),
+		dedupe: make(map[int64][2]sql.NullString),
+		outbox: &memoryOutbox{},
+	}
+}
+
+func (r *memoryUserRepository) CreateUserInfo(ctx context.Context, user *UserInfo, policy DuplicatePolicy) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user.Status = StatusPending
+	if err := ensureTrackingToken(user); err != nil {
+		return false, err
+	}
+	now := time.Now()
+	merged, err := r.insertUser(user, policy, now)
+	if err != nil {
+		return false, err
+	}
+	if err := r.writeCreatedEvent(user, EventSourceAPI, merged, now); err != nil {
+		return false, err
+	}
+	return merged, nil
+}
+
+// writeCreatedEvent 调用方需持有写锁
+func (r *memoryUserRepository) writeCreatedEvent(user *UserInfo, source string, merged bool, now time.Time) error {
+	event, err := createdEvent(user, source, merged, now)
+	if err != nil {
+		return err
+	}
+	r.outbox.insertEvent(event)
+	return nil
+}
+
+// insertUser 调用方需持有写锁；命中重复且不能合并时返回 ErrDuplicateSubmission
+func (r *memoryUserRepository) insertUser(user *UserInfo, policy DuplicatePolicy, now time.Time) (bool, error) {
+	normalizeContact(user)
+	keys := [2]sql.NullString{}
+	keys[0], keys[1] = dedupeKeys(user, policy)
+
+	if keys[0].Valid || keys[1].Valid {
+		var ids []int64
+		for id, existing := range r.dedupe {
+			if sameKey(existing[0], keys[0]) || sameKey(existing[1], keys[1]) {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) > 0 {
+			if policy != DuplicateMerge || len(ids) > 1 || !canMerge(r.dedupe[ids[0]], keys) {
+				return false, ErrDuplicateSubmission
+			}
+			stored := r.users[ids[0]]
+			stored.Name = user.Name
+			stored.Email = user.Email
+			stored.Phone = user.Phone
+			stored.Hobby = user.Hobby
+			stored.Age = user.Age
+			stored.EmailNormalized = user.EmailNormalized
+			stored.PhoneE164 = user.PhoneE164
+			stored.TrackingToken = user.TrackingToken
+			stored.UpdatedAt = now
+			r.users[stored.ID] = stored
+			r.dedupe[stored.ID] = keys
+			user.ID = stored.ID
+			return true, nil
+		}
+	}
+
+	r.nextID++
+	stored := *user
+	stored.ID = r.nextID
+	stored.CreatedAt = now
+	stored.UpdatedAt = now
+	r.users[stored.ID] = stored
+	if keys[0].Valid || keys[1].Valid {
+		r.dedupe[stored.ID] = keys
+	}
+
+	user.ID = stored.ID
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	return false, nil
+}
+
+func (r *memoryUserRepository) ImportUsers(ctx context.Context, users []*UserInfo, policy DuplicatePolicy, actor string, dryRun bool) ([]ImportResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if dryRun {
+		defer r.snapshot()()
+	}
+
+	now := time.Now()
+	results := make([]ImportResult, len(users))
+	for i, user := range users {
+		if err := ensureTrackingToken(user); err != nil {
+			return nil, err
+		}
+		merged, err := r.insertUser(user, policy, now)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Merged = merged
+		if err := r.writeCreatedEvent(user, EventSourceImport, merged, now); err != nil {
+			return nil, err
+		}
+
+		if user.Status != StatusPending {
+			r.nextHistoryID++
+			r.history = append(r.history, StatusHistory{
+				ID:        r.nextHistoryID,
+				UserID:    user.ID,
+				Actor:     actor,
+				OldStatus: StatusPending,
+				NewStatus: user.Status,
+				Reason:    user.RejectReason,
+				CreatedAt: now,
+			})
+		}
+	}
+	return results, nil
+}
+
+// snapshot 保存 ImportUsers 会修改的状态，返回的函数恢复到保存时的状态，相当于回滚事务。
+// 与 MySQL 一样，已经分配的 id 不会收回。调用方需持有写锁
+func (r *memoryUserRepository) snapshot() (restore func()) {
+	users, dedupe := maps.Clone(r.users), maps.Clone(r.dedupe)
+	history := len(r.history)
+	events := r.outbox.eventCount()
+	return func() {
+		r.users, r.dedupe = users, dedupe
+		r.history = r.history[:history]
+		r.outbox.truncateEvents(events)
+	}
+}
+
+func (r *memoryUserRepository) ListUsers(ctx context.Context, opts ListUsersOptions) ([]UserInfo, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := []UserInfo{}
+	for _, user := range r.users {
+		if matchUserFilter(&user, opts.Filter) {
+			users = append(users, user)
+		}
+	}
+	sortUsers(users, opts.SortBy, opts.Order)
+
+	total := int64(len(users))
+	if opts.Offset >= len(users) {
+		return []UserInfo{}, total, nil
+	}
+	end := len(users)
+	if opts.Limit > 0 && opts.Offset+opts.Limit < end {
+		end = opts.Offset + opts.Limit
+	}
+	return users[opts.Offset:end], total, nil
+}
+
+func (r *memoryUserRepository) CountUsers(ctx context.Context, filter UserFilter) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var total int64
+	for _, user := range r.users {
+		if matchUserFilter(&user, filter) {
+			total++
+		}
+	}
+	return total, nil
+}
+
+// StreamUsers 先在读锁内复制出匹配的记录，回调时不持有锁
+func (r *memoryUserRepository) StreamUsers(ctx context.Context, opts ListUsersOptions, fn func(*UserInfo) error) error {
+	r.mu.RLock()
+	users := []UserInfo{}
+	for _, user := range r.users {
+		if matchUserFilter(&user, opts.Filter) {
+			users = append(users, user)
+		}
+	}
+	r.mu.RUnlock()
+	sortUsers(users, opts.SortBy, opts.Order)
+
+	for i := range users {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(&users[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func matchUserFilter(user *UserInfo, f UserFilter) bool {
+	if f.Status != "" && user.Status != f.Status {
+		return false
+	}
+	if !f.CreatedFrom.IsZero() && user.CreatedAt.Before(f.CreatedFrom) {
+		return false
+	}
+	if !f.CreatedTo.IsZero() && !user.CreatedAt.Before(f.CreatedTo) {
+		return false
+	}
+	if f.AgeMin > 0 && user.Age < f.AgeMin {
+		return false
+	}
+	if f.AgeMax > 0 && user.Age > f.AgeMax {
+		return false
+	}
+	if f.Keyword != "" {
+		// 与 utf8mb4_unicode_ci 的 LIKE 一样不区分大小写
+		keyword := strings.ToLower(f.Keyword)
+		for _, field := range []string{user.Name, user.EmailNormalized, user.Phone, user.Hobby} {
+			if strings.Contains(strings.ToLower(field), keyword) {
+				return true
+			}
+		}
+		key := phoneSearchKey(f.Keyword)
+		return key != "" && user.PhoneE164 != "" && strings.Contains(user.PhoneE164, key)
+	}
+	return true
+}
+
+func sortUsers(users []UserInfo, sortBy, order string) {
+	compare := func(a, b *UserInfo) int {
+		switch sortBy {
+		case "id":
+			return 0
+		case "name":
+			return strings.Compare(a.Name, b.Name)
+		case "age":
+			return a.Age - b.Age
+		case "status":
+			return strings.Compare(a.Status, b.Status)
+		case "updated_at":
+			return a.UpdatedAt.Compare(b.UpdatedAt)
+		default:
+			return a.CreatedAt.Compare(b.CreatedAt)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		c := compare(&users[i], &users[j])
+		if c == 0 {
+			c = int(users[i].ID - users[j].ID)
+		}
+		if order == "asc" {
+			return c < 0
+		}
+		return c > 0
+	})
+}
+
+func (r *memoryUserRepository) UpdateUserStatus(ctx context.Context, id int64, change StatusChange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return ErrUserNotFound
+	}
+	if err := checkTransition(user.Status, change); err != nil {
+		return err
+	}
+
+	return r.writeStatusChange(id, change, time.Now())
+}
+
+func (r *memoryUserRepository) BulkUpdateUserStatus(ctx context.Context, ids []int64, change StatusChange, atomic bool) ([]StatusChangeResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids = uniqueIDs(ids)
+	current := make(map[int64]string, len(ids))
+	for _, id := range ids {
+		if user, ok := r.users[id]; ok {
+			current[id] = user.Status
+		}
+	}
+
+	results := planStatusChanges(ids, current, change, atomic)
+
+	now := time.Now()
+	for _, result := range results {
+		if result.Err != nil {
+			continue
+		}
+		if err := r.writeStatusChange(result.ID, change, now); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// writeStatusChange 调用方需持有写锁并已完成状态机校验
+func (r *memoryUserRepository) writeStatusChange(id int64, change StatusChange, now time.Time) error {
+	user := r.users[id]
+	event, err := statusChangedEvent(&user, change, now)
+	if err != nil {
+		return err
+	}
+	r.outbox.insertEvent(event)
+
+	r.nextHistoryID++
+	r.history = append(r.history, StatusHistory{
+		ID:        r.nextHistoryID,
+		UserID:    id,
+		Actor:     change.Actor,
+		OldStatus: user.Status,
+		NewStatus: change.Status,
+		Reason:    change.Reason,
+		CreatedAt: now,
+	})
+
+	user.Status = change.Status
+	user.RejectReason = ""
+	if change.Status == StatusRejected {
+		user.RejectReason = change.Reason
+	}
+	user.ReviewNotes = reviewNotes(&user, change)
+	user.UpdatedAt = now
+	r.users[id] = user
+	delete(r.dedupe, id)
+	return nil
+}
+
+func (r *memoryUserRepository) ListStatusHistory(ctx context.Context, userID int64) ([]StatusHistory, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// history 按写入顺序追加，天然有序
+	history := []StatusHistory{}
+	for _, h := range r.history {
+		if h.UserID == userID {
+			history = append(history, h)
+		}
+	}
+	return history, nil
+}
+
+func (r *memoryUserRepository) GetUserByID(ctx context.Context, id int64) (*UserInfo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, nil
+	}
+	return &user, nil
+}
+
+func (r *memoryUserRepository) GetUserByTrackingToken(ctx context.Context, token string) (*UserInfo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if token != "" && user.TrackingToken == token {
+			return &user, nil
+		}
+	}
+	return nil, nil
+}
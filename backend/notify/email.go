@@ -0,0 +1,60 @@
+// Package notify 在审核通过或拒绝后给提交者发送邮件通知。
+//
+// EmailSink 作为 outbox 的接收方，事件在审核的事务中写入 outbox，由 relay 投递并在失败时按退避重试；
+// 邮件内容由可编辑的模板生成，发送方式由 Sender 决定
+package notify
+
+import (
+	"context"
+	"time"
+	"tuna/models"
+)
+
+// EmailSinkName outbox 投递记录中的接收方名称
+const EmailSinkName = "email"
+
+// EmailSink 实现 outbox.Sink，把审核结果发送到提交时填写的邮箱
+type EmailSink struct {
+	sender    Sender
+	templates *Templates
+	from      string
+}
+
+// NewEmailSink from 为发件人，如 "Tuna <noreply@example.com>"
+func NewEmailSink(sender Sender, templates *Templates, from string) *EmailSink {
+	return &EmailSink{sender: sender, templates: templates, from: from}
+}
+
+func (s *EmailSink) Name() string {
+	return EmailSinkName
+}
+
+func (s *EmailSink) Accepts(eventType string) bool {
+	return s.templates.Has(eventType)
+}
+
+func (s *EmailSink) Deliver(ctx context.Context, event models.Event) error {
+	// 老数据可能没有邮箱，没有收件人时视为已处理
+	if event.Data.Email == "" {
+		return nil
+	}
+	subject, body, err := s.templates.Render(event.Type, TemplateData{
+		ID:         event.Data.ID,
+		Name:       event.Data.Name,
+		Email:      event.Data.Email,
+		Status:     event.Data.Status,
+		Reason:     event.Data.Reason,
+		OccurredAt: event.OccurredAt,
+	})
+	if err != nil {
+		return err
+	}
+	return s.sender.Send(ctx, Message{
+		From:      s.from,
+		To:        event.Data.Email,
+		Subject:   subject,
+		Body:      body,
+		MessageID: event.ID + "@tuna",
+		Date:      time.Now(),
+	})
+}
@@ -0,0 +1,247 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"tuna/models"
+	"tuna/notify/smtptest"
+	"tuna/outbox"
+)
+
+func newTestSink(t *testing.T, dir string) (*EmailSink, *smtptest.Server) {
+	t.Helper()
+	server, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	templates, err := LoadTemplates(dir, Languages)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port := server.HostPort()
+	sender := NewSMTPSender(SMTPOptions{Host: host, Port: port, TLS: TLSNone})
+	return NewEmailSink(sender, templates, "Tuna <noreply@example.com>"), server
+}
+
+func rejectedEvent() models.Event {
+	return models.Event{
+		ID:         "evt-1",
+		Type:       models.EventSubmissionRejected,
+		OccurredAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		Data: models.SubmissionEvent{
+			ID:     42,
+			Name:   "张三",
+			Email:  "zhangsan@example.com",
+			Status: models.StatusRejected,
+			Reason: "资料不全",
+		},
+	}
+}
+
+// readMessage 解析收到的邮件，返回解码后的主题和正文
+func readMessage(t *testing.T, data string) (*mail.Message, string, string) {
+	t.Helper()
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(msg.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg, subject, string(body)
+}
+
+func TestEmailSinkDeliver(t *testing.T) {
+	sink, server := newTestSink(t, "")
+
+	if !sink.Accepts(models.EventSubmissionRejected) || sink.Accepts(models.EventSubmissionCreated) {
+		t.Error("Accepts should only match events with templates")
+	}
+	if err := sink.Deliver(context.Background(), rejectedEvent()); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("%d messages, want 1", len(messages))
+	}
+	if messages[0].From != "noreply@example.com" || len(messages[0].To) != 1 || messages[0].To[0] != "zhangsan@example.com" {
+		t.Errorf("envelope = %s -> %v", messages[0].From, messages[0].To)
+	}
+	msg, subject, body := readMessage(t, messages[0].Data)
+	if subject != "您的资料未通过审核 / Your submission was not approved" {
+		t.Errorf("subject = %q", subject)
+	}
+	if msg.Header.Get("Message-ID") != "<evt-1@tuna>" {
+		t.Errorf("Message-ID = %q", msg.Header.Get("Message-ID"))
+	}
+	for _, want := range []string{"张三，您好", "编号 42", "原因：资料不全", "Hi 张三,", "Reason: 资料不全"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body does not contain %q:\n%s", want, body)
+		}
+	}
+	if zh, en := strings.Index(body, "原因"), strings.Index(body, "Reason"); zh > en {
+		t.Error("languages are not in the configured order")
+	}
+}
+
+func TestEmailSinkWithoutEmail(t *testing.T) {
+	sink, server := newTestSink(t, "")
+	event := rejectedEvent()
+	event.Data.Email = ""
+	if err := sink.Deliver(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(server.Messages()); n != 0 {
+		t.Errorf("%d messages sent without a recipient", n)
+	}
+}
+
+func TestSMTPErrors(t *testing.T) {
+	sink, server := newTestSink(t, "")
+	ctx := context.Background()
+
+	server.FailNext("451 try again later")
+	err := sink.Deliver(ctx, rejectedEvent())
+	if err == nil || outbox.IsPermanent(err) {
+		t.Errorf("4xx: err = %v, want a temporary error", err)
+	}
+
+	server.FailNext("550 mailbox unavailable")
+	if err := sink.Deliver(ctx, rejectedEvent()); !outbox.IsPermanent(err) {
+		t.Errorf("5xx: err = %v, want a permanent error", err)
+	}
+
+	event := rejectedEvent()
+	event.Data.Email = "not an address"
+	if err := sink.Deliver(ctx, event); !outbox.IsPermanent(err) {
+		t.Errorf("invalid recipient: err = %v, want a permanent error", err)
+	}
+
+	if err := sink.Deliver(ctx, rejectedEvent()); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(server.Messages()); n != 1 {
+		t.Errorf("%d messages, want only the last one", n)
+	}
+}
+
+func TestTemplateOverride(t *testing.T) {
+	dir := t.TempDir()
+	// 只覆盖英文模板，中文使用内置模板
+	custom := `{{define "subject"}}Rejected: {{.Name}}{{end}}{{define "body"}}Custom body for #{{.ID}}{{end}}`
+	if err := os.WriteFile(filepath.Join(dir, "submission.rejected.en.tmpl"), []byte(custom), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sink, server := newTestSink(t, dir)
+
+	// 主题中的换行会被合并，不能注入邮件头
+	event := rejectedEvent()
+	event.Data.Name = "张三\r\nBcc: attacker@example.com"
+	if err := sink.Deliver(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	msg, subject, body := readMessage(t, server.Messages()[0].Data)
+	if subject != "您的资料未通过审核 / Rejected: 张三 Bcc: attacker@example.com" {
+		t.Errorf("subject = %q", subject)
+	}
+	if msg.Header.Get("Bcc") != "" {
+		t.Error("template injected a Bcc header")
+	}
+	if !strings.Contains(body, "原因：资料不全") || !strings.Contains(body, "Custom body for #42") {
+		t.Errorf("body = %s", body)
+	}
+}
+
+func TestTemplateReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "submission.approved.en.tmpl")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`{{define "subject"}}v1{{end}}{{define "body"}}v1{{end}}`)
+	templates, err := LoadTemplates(dir, []string{LangEN})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 不合法的模板不替换原模板
+	write(`{{define "subject"}}v2{{end}}`)
+	if err := templates.Reload(); err == nil {
+		t.Error("Reload accepted a template without body")
+	}
+	if subject, _, _ := templates.Render(models.EventSubmissionApproved, TemplateData{}); subject != "v1" {
+		t.Errorf("subject = %q after a failed reload, want v1", subject)
+	}
+	write(`{{define "subject"}}{{.Unknown}}{{end}}{{define "body"}}v2{{end}}`)
+	if err := templates.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := templates.Render(models.EventSubmissionApproved, TemplateData{}); err == nil {
+		t.Error("Render accepted an unknown field")
+	}
+
+	write(`{{define "subject"}}v3{{end}}{{define "body"}}v3{{end}}`)
+	if err := templates.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	subject, body, err := templates.Render(models.EventSubmissionApproved, TemplateData{})
+	if err != nil || subject != "v3" || body != "v3\n" {
+		t.Errorf("Render = %q, %q, %v", subject, body, err)
+	}
+
+	if _, err := LoadTemplates(t.TempDir(), []string{"fr"}); err == nil {
+		t.Error("LoadTemplates accepted a language without templates")
+	}
+}
+
+func TestMessageBytes(t *testing.T) {
+	long := strings.Repeat("很长的一行", 30)
+	data, err := Message{
+		From:      "Tuna <noreply@example.com>",
+		To:        "zhangsan@example.com",
+		Subject:   "审核结果 / Result",
+		Body:      "第一行 a=b\n" + long,
+		MessageID: "evt-1@tuna",
+		Date:      time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+	}.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw := string(data)
+	header, encoded, _ := strings.Cut(raw, "\r\n\r\n")
+	if !strings.Contains(header, "Subject: =?utf-8?q?") || !strings.Contains(header, "Date: Wed, 01 May 2024 10:00:00 +0000") {
+		t.Errorf("header = %s", header)
+	}
+	for _, line := range strings.Split(encoded, "\r\n") {
+		if len(line) > 76 {
+			t.Errorf("quoted-printable line longer than 76 characters: %q", line)
+		}
+	}
+	if !strings.Contains(encoded, "a=3Db") {
+		t.Errorf("'=' is not encoded: %s", encoded)
+	}
+
+	_, subject, body := readMessage(t, raw)
+	if subject != "审核结果 / Result" || body != "第一行 a=b\r\n"+long {
+		t.Errorf("decoded = %q, %q", subject, body)
+	}
+}
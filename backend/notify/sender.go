@@ -0,0 +1,156 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"time"
+	"tuna/outbox"
+)
+
+// Message 一封纯文本邮件
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Body    string
+	// MessageID 不含尖括号，重试时保持不变，方便收件方去重
+	MessageID string
+	Date      time.Time
+}
+
+// Bytes 按 RFC 5322 编码，主题使用 RFC 2047 编码，正文为 UTF-8 的 quoted-printable
+func (m Message) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", m.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", m.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", m.Date.Format(time.RFC1123Z))
+	if m.MessageID != "" {
+		fmt.Fprintf(&buf, "Message-ID: <%s>\r\n", m.MessageID)
+	}
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	w := quotedprintable.NewWriter(&buf)
+	if _, err := w.Write(bytes.ReplaceAll([]byte(m.Body), []byte("\n"), []byte("\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Sender 发送邮件。返回用 outbox.Permanent 包装的错误表示重试也不会成功，如收件地址被拒绝
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTP 连接的加密方式
+const (
+	TLSStartTLS = "starttls"
+	TLSImplicit = "tls"
+	TLSNone     = "none"
+)
+
+// SMTPOptions SMTP 服务器配置
+type SMTPOptions struct {
+	Host string
+	Port string
+	// Username 为空时不认证
+	Username string
+	Password string
+	// TLS 为 starttls、tls（连接即加密，通常是 465 端口）或 none
+	TLS string
+}
+
+// SMTPSender 每封邮件建立一个连接发送
+type SMTPSender struct {
+	opts SMTPOptions
+}
+
+// NewSMTPSender 返回通过 SMTP 发送的 Sender
+func NewSMTPSender(opts SMTPOptions) *SMTPSender {
+	return &SMTPSender{opts: opts}
+}
+
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return outbox.Permanent(fmt.Errorf("invalid from address: %w", err))
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return outbox.Permanent(fmt.Errorf("invalid recipient address: %w", err))
+	}
+	data, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.opts.Host, s.opts.Port))
+	if err != nil {
+		return err
+	}
+	// net/smtp 不支持 context，用连接的超时代替
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	tlsConfig := &tls.Config{ServerName: s.opts.Host}
+	if s.opts.TLS == TLSImplicit {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	c, err := smtp.NewClient(conn, s.opts.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if s.opts.TLS == TLSStartTLS {
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if s.opts.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.opts.Username, s.opts.Password, s.opts.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return smtpError(err)
+	}
+	if err := c.Rcpt(to.Address); err != nil {
+		return smtpError(err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return smtpError(err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return smtpError(err)
+	}
+	return c.Quit()
+}
+
+// smtpError 5xx 为永久错误，不再重试；4xx 等临时错误照常重试
+func smtpError(err error) error {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) && protoErr.Code >= 500 {
+		return outbox.Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,156 @@
+// Package smtptest 进程内的假 SMTP 服务器，记录收到的邮件，供测试 notify.SMTPSender 和邮件通知流程使用。
+// 只实现发送邮件需要的命令，不支持 STARTTLS 和认证，配合 notify.TLSNone 和空用户名使用
+package smtptest
+
+import (
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// Message 服务器收到的一封邮件
+type Message struct {
+	From string
+	To   []string
+	// Data DATA 命令之后的原文，包括邮件头
+	Data string
+}
+
+// Server 监听 127.0.0.1 上的随机端口
+type Server struct {
+	// Addr 监听地址，host:port
+	Addr string
+
+	ln       net.Listener
+	mu       sync.Mutex
+	messages []Message
+	failures []string
+	wg       sync.WaitGroup
+}
+
+// NewServer 启动服务器，用完后调用 Close
+func NewServer() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{Addr: ln.Addr().String(), ln: ln}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// HostPort 拆分后的监听地址，方便填入 notify.SMTPOptions
+func (s *Server) HostPort() (host, port string) {
+	host, port, _ = net.SplitHostPort(s.Addr)
+	return host, port
+}
+
+// Messages 按收到的顺序返回全部邮件
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// FailNext 之后的 DATA 依次以 replies 中的回复拒绝，如 "451 try again later"、"550 mailbox unavailable"
+func (s *Server) FailNext(replies ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, replies...)
+}
+
+// Close 停止监听并等待已有连接结束
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	reply := func(line string) bool {
+		return tp.PrintfLine("%s", line) == nil
+	}
+	if !reply("220 smtptest ready") {
+		return
+	}
+
+	var msg Message
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(cmd) {
+		case "EHLO", "HELO":
+			reply("250 smtptest")
+		case "MAIL":
+			msg = Message{From: address(arg)}
+			reply("250 OK")
+		case "RCPT":
+			msg.To = append(msg.To, address(arg))
+			reply("250 OK")
+		case "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			msg.Data = string(data)
+			if failure := s.nextFailure(); failure != "" {
+				reply(failure)
+				continue
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, msg)
+			s.mu.Unlock()
+			reply("250 OK")
+		case "RSET":
+			msg = Message{}
+			reply("250 OK")
+		case "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+func (s *Server) nextFailure() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.failures) == 0 {
+		return ""
+	}
+	failure := s.failures[0]
+	s.failures = s.failures[1:]
+	return failure
+}
+
+// address 从 "FROM:<a@example.com>" 中取出地址
+func address(arg string) string {
+	_, addr, _ := strings.Cut(arg, ":")
+	return strings.Trim(strings.TrimSpace(addr), "<>")
+}
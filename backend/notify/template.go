@@ -0,0 +1,130 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+	"tuna/models"
+)
+
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+// 支持的模板语言，与 apierror 的语言标签一致
+const (
+	LangZH = "zh-CN"
+	LangEN = "en"
+)
+
+// Languages 支持的模板语言
+var Languages = []string{LangZH, LangEN}
+
+// notifyEvents 发送邮件的事件，每个事件每种语言一个模板文件 <事件类型>.<语言>.tmpl
+var notifyEvents = []string{models.EventSubmissionApproved, models.EventSubmissionRejected}
+
+// TemplateData 模板中可以使用的字段
+type TemplateData struct {
+	ID     int64
+	Name   string
+	Email  string
+	Status string
+	// Reason 审核时填写的原因，拒绝时必填
+	Reason     string
+	OccurredAt time.Time
+}
+
+// Templates 邮件模板。每个模板文件用 {{define "subject"}} 和 {{define "body"}} 定义主题和正文，
+// 目录中没有的文件使用内置模板
+type Templates struct {
+	dir   string
+	langs []string
+	set   atomic.Pointer[map[string]*template.Template]
+}
+
+// LoadTemplates 加载 dir 中的模板，dir 为空时只使用内置模板。langs 为邮件中依次包含的语言
+func LoadTemplates(dir string, langs []string) (*Templates, error) {
+	t := &Templates{dir: dir, langs: langs}
+	if err := t.Reload(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Reload 重新读取模板目录，任一模板不合法时保留原模板并返回错误
+func (t *Templates) Reload() error {
+	set := make(map[string]*template.Template)
+	for _, event := range notifyEvents {
+		for _, lang := range t.langs {
+			name := event + "." + lang + ".tmpl"
+			tmpl, err := t.parse(name)
+			if err != nil {
+				return fmt.Errorf("email template %s: %w", name, err)
+			}
+			set[name] = tmpl
+		}
+	}
+	t.set.Store(&set)
+	return nil
+}
+
+func (t *Templates) parse(name string) (*template.Template, error) {
+	var data []byte
+	var err error
+	if t.dir != "" {
+		data, err = os.ReadFile(filepath.Join(t.dir, name))
+	}
+	if t.dir == "" || errors.Is(err, fs.ErrNotExist) {
+		data, err = builtinTemplates.ReadFile("templates/" + name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+	for _, block := range []string{"subject", "body"} {
+		if tmpl.Lookup(block) == nil {
+			return nil, fmt.Errorf("missing {{define %q}}", block)
+		}
+	}
+	return tmpl, nil
+}
+
+// Has 是否有该事件的模板
+func (t *Templates) Has(eventType string) bool {
+	return slices.Contains(notifyEvents, eventType)
+}
+
+// Render 按配置的语言顺序渲染主题和正文：主题以 " / " 连接，正文之间以分隔线隔开
+func (t *Templates) Render(eventType string, data TemplateData) (subject, body string, err error) {
+	set := *t.set.Load()
+	var subjects, bodies []string
+	for _, lang := range t.langs {
+		tmpl, ok := set[eventType+"."+lang+".tmpl"]
+		if !ok {
+			return "", "", fmt.Errorf("no email template for %s", eventType)
+		}
+		var s, b bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&s, "subject", data); err != nil {
+			return "", "", err
+		}
+		if err := tmpl.ExecuteTemplate(&b, "body", data); err != nil {
+			return "", "", err
+		}
+		subjects = append(subjects, strings.TrimSpace(s.String()))
+		bodies = append(bodies, strings.TrimSpace(b.String()))
+	}
+	// 主题写入邮件头，去掉换行避免头注入
+	subject = strings.Join(strings.Fields(strings.Join(subjects, " / ")), " ")
+	return subject, strings.Join(bodies, "\n\n----------------------------------------\n\n") + "\n", nil
+}
@@ -0,0 +1,313 @@
+package openapi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"tuna/admin"
+	"tuna/api"
+	"tuna/metrics"
+	"tuna/models"
+	"tuna/openapi"
+	"tuna/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// contract 对一个 router 发请求，并检查每个响应都与文档一致。
+// 测试结束时要求文档中的每个接口都至少被请求过一次，新增路由时需要在这里补上对应的请求
+type contract struct {
+	t       *testing.T
+	doc     *openapi.Document
+	router  *gin.Engine
+	header  http.Header
+	covered map[openapi.Route]bool
+}
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	logrus.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func newContract(t *testing.T, doc *openapi.Document, router *gin.Engine) *contract {
+	t.Helper()
+	documented := map[openapi.Route]bool{}
+	for _, r := range doc.Routes() {
+		documented[r] = true
+	}
+	for _, r := range router.Routes() {
+		route := openapi.Route{Method: r.Method, Path: openapi.PathTemplate(r.Path)}
+		if !documented[route] {
+			t.Errorf("route %s %s is registered but not documented", route.Method, route.Path)
+		}
+		delete(documented, route)
+	}
+	for r := range documented {
+		t.Errorf("route %s %s is documented but not registered", r.Method, r.Path)
+	}
+
+	// 文档本身应当是合法的 JSON，且每个路径参数都有声明
+	if _, err := json.Marshal(doc); err != nil {
+		t.Fatalf("marshal document: %v", err)
+	}
+	for _, r := range doc.Routes() {
+		op, _ := doc.Operation(r.Method, r.Path)
+		for _, segment := range strings.Split(r.Path, "/") {
+			name, ok := strings.CutPrefix(segment, "{")
+			if !ok {
+				continue
+			}
+			name = strings.TrimSuffix(name, "}")
+			if !hasParam(op, name) {
+				t.Errorf("%s %s: path parameter %s is not declared", r.Method, r.Path, name)
+			}
+		}
+	}
+
+	return &contract{t: t, doc: doc, router: router, header: http.Header{}, covered: map[openapi.Route]bool{}}
+}
+
+func hasParam(op *openapi.Operation, name string) bool {
+	for _, p := range op.Parameters {
+		if p.In == "path" && p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// do 发出请求并按文档校验响应，状态码与 want 不同时测试失败
+func (c *contract) do(want int, req *http.Request) *httptest.ResponseRecorder {
+	c.t.Helper()
+	for k, v := range c.header {
+		if req.Header.Get(k) == "" {
+			req.Header[k] = v
+		}
+	}
+	w := httptest.NewRecorder()
+	c.router.ServeHTTP(w, req)
+
+	if w.Code != want {
+		c.t.Errorf("%s %s: status %d, want %d: %s", req.Method, req.URL.Path, w.Code, want, w.Body.String())
+	}
+	if err := c.doc.ValidateResponse(req.Method, req.URL.Path, w.Code, w.Header(), w.Body.Bytes()); err != nil {
+		c.t.Errorf("response does not match the document: %v\nbody: %s", err, w.Body.String())
+	}
+	if _, template := c.doc.Operation(req.Method, req.URL.Path); template != "" {
+		c.covered[openapi.Route{Method: req.Method, Path: template}] = true
+	}
+	return w
+}
+
+func (c *contract) json(want int, method, target string, body any) *httptest.ResponseRecorder {
+	c.t.Helper()
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		r = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, target, r)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.do(want, req)
+}
+
+// docs 请求 Swagger UI 页面和它引用的文件，页面不能从其他来源加载脚本
+func (c *contract) docs() {
+	c.t.Helper()
+	page := c.json(http.StatusOK, http.MethodGet, "/docs", nil)
+	if strings.Contains(page.Body.String(), "://") {
+		c.t.Errorf("docs page references an external resource:\n%s", page.Body.String())
+	}
+	if page.Header().Get("Content-Security-Policy") == "" {
+		c.t.Errorf("docs page has no Content-Security-Policy")
+	}
+	for _, name := range []string{"swagger-ui-bundle.js", "swagger-ui.css", "initializer.js"} {
+		if w := c.json(http.StatusOK, http.MethodGet, "/docs/assets/"+name, nil); w.Body.Len() == 0 {
+			c.t.Errorf("docs asset %s is empty", name)
+		}
+	}
+	c.json(http.StatusNotFound, http.MethodGet, "/docs/assets/index.html", nil)
+}
+
+func (c *contract) checkCoverage() {
+	c.t.Helper()
+	for _, r := range c.doc.Routes() {
+		if !c.covered[r] {
+			c.t.Errorf("%s %s is not exercised by the contract test", r.Method, r.Path)
+		}
+	}
+}
+
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return v
+}
+
+func TestAPIContract(t *testing.T) {
+	opts := api.Options{
+		Users:       models.NewMemoryUserRepository(),
+		Idempotency: models.NewMemoryIdempotencyRepository(),
+		RateLimit: api.NewRateLimiter(api.RateLimitOptions{
+			PerContact:    ratelimit.Limit{Requests: 2, Per: time.Hour},
+			HoneypotField: "website",
+		}),
+		Metrics: metrics.Options{Enabled: true},
+	}
+	c := newContract(t, api.Spec(opts), api.SetupRouter(opts))
+
+	submit := models.CreateUserRequest{Name: "张三", Email: "zhangsan@example.com", Phone: "13800138000", Hobby: "阅读", Age: 25}
+	created := decode[models.SubmitResponse](t, c.json(http.StatusOK, http.MethodPost, "/api/submit", submit))
+	c.json(http.StatusConflict, http.MethodPost, "/api/submit", submit)
+	c.json(http.StatusTooManyRequests, http.MethodPost, "/api/submit", submit)
+	c.json(http.StatusBadRequest, http.MethodPost, "/api/submit", map[string]any{"name": "李四", "age": 200})
+	c.json(http.StatusOK, http.MethodPost, "/api/submit", map[string]any{"name": "bot", "website": "http://spam.example"})
+
+	idempotent := func(want int, body models.CreateUserRequest) *httptest.ResponseRecorder {
+		data, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/api/submit", bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", "contract-test")
+		return c.do(want, req)
+	}
+	other := models.CreateUserRequest{Name: "王五", Email: "wangwu@example.com", Phone: "13900139000", Hobby: "跑步", Age: 30}
+	idempotent(http.StatusOK, other)
+	// 重放不占用按联系方式的限流额度（每小时 2 次）
+	for i := 0; i < 3; i++ {
+		if w := idempotent(http.StatusOK, other); w.Header().Get("Idempotent-Replayed") != "true" {
+			t.Errorf("request with the same Idempotency-Key was not replayed")
+		}
+	}
+	other.Age++
+	idempotent(http.StatusUnprocessableEntity, other)
+	c.json(http.StatusRequestEntityTooLarge, http.MethodPost, "/api/submit", map[string]string{"hobby": strings.Repeat("x", 64<<10)})
+
+	c.json(http.StatusOK, http.MethodGet, "/api/submissions/"+created.TrackingToken, nil)
+	c.json(http.StatusNotFound, http.MethodGet, "/api/submissions/unknown", nil)
+
+	c.json(http.StatusOK, http.MethodGet, "/api/health", nil)
+	c.json(http.StatusOK, http.MethodGet, "/healthz", nil)
+	c.json(http.StatusOK, http.MethodGet, "/readyz", nil)
+	c.json(http.StatusOK, http.MethodGet, "/metrics", nil)
+	c.json(http.StatusOK, http.MethodGet, "/openapi.json", nil)
+	c.docs()
+	c.checkCoverage()
+}
+
+func TestAdminContract(t *testing.T) {
+	ctx := context.Background()
+	users := models.NewMemoryUserRepository()
+	admins := models.NewMemoryAdminRepository()
+	if _, err := admin.BootstrapAccount(ctx, admins, "admin", "contract-password"); err != nil {
+		t.Fatal(err)
+	}
+	var ids []int64
+	for i := 1; i <= 3; i++ {
+		user := &models.UserInfo{Name: "用户", Email: fmt.Sprintf("user%d@example.com", i), Phone: fmt.Sprintf("1380013800%d", i), Hobby: "阅读", Age: 20 + i}
+		if _, err := users.CreateUserInfo(ctx, user, models.DuplicateReject); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, user.ID)
+	}
+
+	opts := admin.Options{Users: users, Admins: admins, Metrics: metrics.Options{Enabled: true}}
+	c := newContract(t, admin.Spec(opts), admin.SetupRouter(opts))
+
+	c.json(http.StatusUnauthorized, http.MethodGet, "/admin/me", nil)
+	c.json(http.StatusBadRequest, http.MethodPost, "/admin/login", map[string]string{"username": "admin"})
+	c.json(http.StatusUnauthorized, http.MethodPost, "/admin/login", models.LoginRequest{Username: "admin", Password: "wrong"})
+	login := decode[models.LoginResponse](t, c.json(http.StatusOK, http.MethodPost, "/admin/login",
+		models.LoginRequest{Username: "admin", Password: "contract-password"}))
+	c.header.Set("Authorization", "Bearer "+login.Token)
+
+	c.json(http.StatusOK, http.MethodGet, "/admin/me", nil)
+	c.json(http.StatusOK, http.MethodGet, "/admin/users?page=1&page_size=2&status=pending&sort_by=age&order=asc", nil)
+	c.json(http.StatusBadRequest, http.MethodGet, "/admin/users?page_size=1000", nil)
+
+	for _, format := range []string{"csv", "xlsx", "jsonl"} {
+		c.json(http.StatusOK, http.MethodGet, "/admin/users/export?format="+format, nil)
+	}
+	c.json(http.StatusBadRequest, http.MethodGet, "/admin/users/export?columns=password", nil)
+
+	importFile := func(want int, content string) {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		mw.WriteField("dry_run", "true")
+		if content != "" {
+			fw, _ := mw.CreateFormFile("file", "users.csv")
+			io.WriteString(fw, content)
+		}
+		mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/admin/users/import", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		c.do(want, req)
+	}
+	importFile(http.StatusOK, "name,email,phone,hobby,age\n赵六,zhaoliu@example.com,13700137000,游泳,28\n错误,bad,1,x,0\n")
+	importFile(http.StatusBadRequest, "")
+
+	status := func(id int64) string { return "/admin/users/" + strconv.FormatInt(id, 10) + "/status" }
+	c.json(http.StatusOK, http.MethodPut, status(ids[0]), models.UpdateStatusRequest{Status: models.StatusApproved})
+	c.json(http.StatusConflict, http.MethodPut, status(ids[0]), models.UpdateStatusRequest{Status: models.StatusRejected, Reason: "重复"})
+	c.json(http.StatusBadRequest, http.MethodPut, status(ids[1]), models.UpdateStatusRequest{Status: models.StatusRejected})
+	c.json(http.StatusNotFound, http.MethodPut, status(9999), models.UpdateStatusRequest{Status: models.StatusApproved})
+	c.json(http.StatusBadRequest, http.MethodPut, "/admin/users/abc/status", models.UpdateStatusRequest{Status: models.StatusApproved})
+
+	c.json(http.StatusConflict, http.MethodPut, "/admin/users/status",
+		models.BulkUpdateStatusRequest{IDs: []int64{ids[0], ids[1]}, Status: models.StatusApproved})
+	c.json(http.StatusOK, http.MethodPut, "/admin/users/status",
+		models.BulkUpdateStatusRequest{IDs: []int64{ids[0], ids[1]}, Status: models.StatusApproved, Mode: models.BulkModeBestEffort})
+	c.json(http.StatusBadRequest, http.MethodPut, "/admin/users/status", models.BulkUpdateStatusRequest{Status: models.StatusApproved})
+
+	reopen := "/admin/users/" + strconv.FormatInt(ids[0], 10) + "/reopen"
+	c.json(http.StatusOK, http.MethodPost, reopen, models.ReopenRequest{Reason: "重新审核"})
+	c.json(http.StatusConflict, http.MethodPost, reopen, nil)
+	c.json(http.StatusNotFound, http.MethodPost, "/admin/users/9999/reopen", nil)
+
+	c.json(http.StatusOK, http.MethodGet, "/admin/users/"+strconv.FormatInt(ids[0], 10)+"/history", nil)
+	c.json(http.StatusNotFound, http.MethodGet, "/admin/users/9999/history", nil)
+
+	c.json(http.StatusOK, http.MethodGet, "/admin/health", nil)
+	c.json(http.StatusOK, http.MethodGet, "/healthz", nil)
+	c.json(http.StatusOK, http.MethodGet, "/readyz", nil)
+	c.json(http.StatusOK, http.MethodGet, "/metrics", nil)
+	c.json(http.StatusOK, http.MethodGet, "/openapi.json", nil)
+	c.docs()
+
+	c.json(http.StatusOK, http.MethodPost, "/admin/logout", nil)
+	c.json(http.StatusUnauthorized, http.MethodGet, "/admin/me", nil)
+	c.checkCoverage()
+}
+
+// TestUndocumentedProperty 响应中出现文档未声明的字段时校验失败
+func TestUndocumentedProperty(t *testing.T) {
+	doc := openapi.New(openapi.Info{Title: "test", Version: "1"})
+	schema := doc.Schema(models.MessageResponse{})
+	if err := doc.ValidateJSON(schema, []byte(`{"message":"ok"}`)); err != nil {
+		t.Fatalf("valid body rejected: %v", err)
+	}
+	for _, body := range []string{`{"message":"ok","extra":1}`, `{}`, `{"message":1}`, `null`} {
+		if err := doc.ValidateJSON(schema, []byte(body)); err == nil {
+			t.Errorf("%s: want error", body)
+		}
+	}
+}
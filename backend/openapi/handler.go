@@ -0,0 +1,110 @@
+package openapi
+
+import (
+	"encoding/json"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"strings"
+	"tuna/apierror"
+
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files/v2"
+)
+
+const (
+	// SpecPath OpenAPI 文档的访问路径
+	SpecPath = "/openapi.json"
+	// DocsPath Swagger UI 页面的访问路径
+	DocsPath = "/docs"
+	// DocsAssetPath 页面使用的脚本和样式，由二进制内嵌的 swagger-ui-dist 提供
+	DocsAssetPath = DocsPath + "/assets/:file"
+
+	// docsCSP 页面只能加载同源的脚本和样式，管理端的页面与登录 token 同源，不从第三方加载任何脚本。
+	// Swagger UI 会设置行内样式，样式需要放开 unsafe-inline
+	docsCSP = "default-src 'none'; script-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; " +
+		"connect-src 'self'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+)
+
+// docsAssets 可以通过 DocsAssetPath 访问的文件，initializer.js 由本包生成，其余来自 swagger-ui-dist
+var docsAssets = map[string]string{
+	"swagger-ui-bundle.js": "text/javascript; charset=utf-8",
+	"swagger-ui.css":       "text/css; charset=utf-8",
+	"initializer.js":       "text/javascript; charset=utf-8",
+}
+
+// initializerJS 页面的初始化脚本，CSP 不允许行内脚本，所以作为单独的文件提供
+var initializerJS = []byte(`window.ui = SwaggerUIBundle({url: "` + SpecPath + `", dom_id: "#swagger-ui", persistAuthorization: true});
+`)
+
+// Handler 输出文档，文档在创建 handler 时序列化一次
+func Handler(doc *Document) gin.HandlerFunc {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		panic("openapi: marshal document: " + err.Error())
+	}
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+	}
+}
+
+var uiTemplate = template.Must(template.New("ui").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="{{.Assets}}/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="{{.Assets}}/swagger-ui-bundle.js"></script>
+<script src="{{.Assets}}/initializer.js"></script>
+</body>
+</html>
+`))
+
+// UI Swagger UI 页面，加载同一服务上的 SpecPath。脚本和样式都由 Asset 从二进制中提供
+func UI(title string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Header("Content-Security-Policy", docsCSP)
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Status(http.StatusOK)
+		err := uiTemplate.Execute(c.Writer, map[string]string{
+			"Title":  title,
+			"Assets": strings.TrimSuffix(DocsAssetPath, "/:file"),
+		})
+		if err != nil {
+			c.Error(err)
+		}
+	}
+}
+
+// Asset 输出 docsAssets 中的文件，其他文件名返回 404
+func Asset(c *gin.Context) {
+	name := c.Param("file")
+	contentType, ok := docsAssets[name]
+	if !ok {
+		apierror.NoRoute(c)
+		return
+	}
+	data := initializerJS
+	if name != "initializer.js" {
+		var err error
+		if data, err = fs.ReadFile(swaggerFiles.FS, name); err != nil {
+			apierror.Abort(c, apierror.Internal(err))
+			return
+		}
+	}
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// Register 在 router 上挂载 SpecPath、DocsPath 和 DocsAssetPath
+func Register(router gin.IRoutes, doc *Document) {
+	router.GET(SpecPath, Handler(doc))
+	router.GET(DocsPath, UI(doc.Info.Title))
+	router.GET(DocsAssetPath, Asset)
+}
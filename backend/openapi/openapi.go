@@ -0,0 +1,324 @@
+// Package openapi 根据 models 中的请求和响应类型生成 api、admin 两个服务的 OpenAPI 3 文档，
+// 提供 /openapi.json 和 Swagger UI 页面，并可以按文档校验实际的响应，用于契约测试。
+//
+// 文档由各服务的 Spec 函数按路由逐个登记，schema 通过反射 json/form 标签和 binding 校验规则生成，
+// 修改请求或响应类型后文档随之更新
+package openapi
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"tuna/apierror"
+	"tuna/health"
+)
+
+// Version 生成的文档遵循的 OpenAPI 版本
+const Version = "3.0.3"
+
+// BearerAuth 管理端登录 token 的安全方案名称
+const BearerAuth = "bearerAuth"
+
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Tags       []Tag                            `json:"tags,omitempty"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+
+	// types 已生成 schema 的类型，用于处理同名类型和递归引用
+	types *typeRegistry
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	Scheme      string `json:"scheme,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// Operation 一个接口。Responses 的 key 为 HTTP 状态码
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[int]*Response     `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter In 为 path、query 或 header
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Description string                `json:"description,omitempty"`
+	Required    bool                  `json:"required,omitempty"`
+	Content     map[string]*MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// Route 文档中的一个接口，Path 为 OpenAPI 格式，如 /admin/users/{id}/status
+type Route struct {
+	Method string
+	Path   string
+}
+
+// New 返回没有任何接口的文档
+func New(info Info) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]map[string]*Operation{},
+		Components: Components{
+			Schemas:         map[string]*Schema{},
+			SecuritySchemes: map[string]*SecurityScheme{},
+		},
+		types: newTypeRegistry(),
+	}
+}
+
+// Add 登记一个接口，path 使用 gin 的路由格式（:id），与 router 注册时的写法相同
+func (d *Document) Add(method, path string, op *Operation) {
+	path = PathTemplate(path)
+	if d.Paths[path] == nil {
+		d.Paths[path] = map[string]*Operation{}
+	}
+	d.Paths[path][strings.ToLower(method)] = op
+}
+
+// Routes 按路径和方法排序返回全部接口
+func (d *Document) Routes() []Route {
+	var routes []Route
+	for path, item := range d.Paths {
+		for method := range item {
+			routes = append(routes, Route{Method: strings.ToUpper(method), Path: path})
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// Operation 查找实际请求路径对应的接口，返回接口和路径模板。没有参数的路径优先于带参数的路径
+func (d *Document) Operation(method, path string) (*Operation, string) {
+	method = strings.ToLower(method)
+	if op := d.Paths[path][method]; op != nil {
+		return op, path
+	}
+	segments := strings.Split(path, "/")
+	best, bestParams := "", -1
+	for template, item := range d.Paths {
+		if item[method] == nil {
+			continue
+		}
+		params, ok := matchTemplate(strings.Split(template, "/"), segments)
+		if ok && (bestParams < 0 || params < bestParams) {
+			best, bestParams = template, params
+		}
+	}
+	if best == "" {
+		return nil, ""
+	}
+	return d.Paths[best][method], best
+}
+
+// matchTemplate 逐段比较，返回匹配到的路径参数个数
+func matchTemplate(template, segments []string) (int, bool) {
+	if len(template) != len(segments) {
+		return 0, false
+	}
+	params := 0
+	for i, t := range template {
+		if strings.HasPrefix(t, "{") && strings.HasSuffix(t, "}") {
+			if segments[i] == "" {
+				return 0, false
+			}
+			params++
+			continue
+		}
+		if t != segments[i] {
+			return 0, false
+		}
+	}
+	return params, true
+}
+
+// PathTemplate 把 gin 的路由格式转换为 OpenAPI 的路径模板，/users/:id 转换为 /users/{id}
+func PathTemplate(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// UseBearerAuth 登记 Authorization: Bearer <token> 认证方案，返回可以直接用作 Operation.Security 的值
+func (d *Document) UseBearerAuth(description string) []map[string][]string {
+	d.Components.SecuritySchemes[BearerAuth] = &SecurityScheme{Type: "http", Scheme: "bearer", Description: description}
+	return []map[string][]string{{BearerAuth: {}}}
+}
+
+// JSONBody 必填的 JSON 请求体
+func JSONBody(schema *Schema) *RequestBody {
+	return &RequestBody{Required: true, Content: map[string]*MediaType{ContentJSON: {Schema: schema}}}
+}
+
+// JSON 响应体为 JSON 的响应
+func JSON(description string, schema *Schema) *Response {
+	return &Response{Description: description, Content: map[string]*MediaType{ContentJSON: {Schema: schema}}}
+}
+
+// Error 错误响应，响应体为 apierror.Response，组件名为 Error，code 列出全部错误码
+func (d *Document) Error(description string) *Response {
+	if _, ok := d.Components.Schemas["Error"]; !ok {
+		d.NamedSchema("Error", apierror.Response{})
+		code := d.Components.Schemas["Error"].Properties["code"]
+		for _, c := range apierror.Codes() {
+			code.Enum = append(code.Enum, string(c))
+		}
+	}
+	return JSON(description, &Schema{Ref: refPrefix + "Error"})
+}
+
+// PathParam 路径参数，总是必填
+func PathParam(name, description string, schema *Schema) *Parameter {
+	return &Parameter{Name: name, In: "path", Description: description, Required: true, Schema: schema}
+}
+
+// HeaderParam 可选的请求头
+func HeaderParam(name, description string, schema *Schema) *Parameter {
+	return &Parameter{Name: name, In: "header", Description: description, Schema: schema}
+}
+
+// AddHealth 登记 health 包提供的存活和就绪检查，legacy 为服务原有的存活检查路径，如 /api/health
+func (d *Document) AddHealth(legacy string) {
+	live := func(id, summary string) *Operation {
+		return &Operation{
+			OperationID: id,
+			Summary:     summary,
+			Tags:        []string{TagHealth},
+			Responses:   map[int]*Response{http.StatusOK: JSON("进程可以处理请求", d.Schema(health.LiveResponse{}))},
+		}
+	}
+	d.Add(http.MethodGet, legacy, live("health", "存活检查，与 /healthz 相同"))
+	d.Add(http.MethodGet, "/healthz", live("healthz", "存活检查，不检查依赖"))
+
+	report := d.Schema(health.Report{})
+	d.Add(http.MethodGet, "/readyz", &Operation{
+		OperationID: "readyz",
+		Summary:     "就绪检查，检查数据库连接、连接池和数据库结构版本",
+		Tags:        []string{TagHealth},
+		Responses: map[int]*Response{
+			http.StatusOK:                 JSON("可以接收流量", report),
+			http.StatusServiceUnavailable: JSON("依赖不可用或正在退出", report),
+		},
+	})
+}
+
+// AddMetrics 登记 /metrics
+func (d *Document) AddMetrics(path string) {
+	d.Add(http.MethodGet, path, &Operation{
+		OperationID: "metrics",
+		Summary:     "Prometheus 指标",
+		Tags:        []string{TagHealth},
+		Responses: map[int]*Response{http.StatusOK: {
+			Description: "Prometheus 文本格式的指标",
+			Content:     map[string]*MediaType{"text/plain": {Schema: &Schema{Type: "string"}}},
+		}},
+	})
+}
+
+// AddDocs 登记文档本身的两个路径
+func (d *Document) AddDocs() {
+	d.Add(http.MethodGet, SpecPath, &Operation{
+		OperationID: "openapi",
+		Summary:     "本服务的 OpenAPI 文档",
+		Tags:        []string{TagDocs},
+		Responses:   map[int]*Response{http.StatusOK: JSON("OpenAPI 3 文档", &Schema{Type: "object"})},
+	})
+	d.Add(http.MethodGet, DocsPath, &Operation{
+		OperationID: "docs",
+		Summary:     "Swagger UI 页面",
+		Tags:        []string{TagDocs},
+		Responses: map[int]*Response{http.StatusOK: {
+			Description: "HTML 页面",
+			Content:     map[string]*MediaType{"text/html": {Schema: &Schema{Type: "string"}}},
+		}},
+	})
+	assets := make([]string, 0, len(docsAssets))
+	for name := range docsAssets {
+		assets = append(assets, name)
+	}
+	sort.Strings(assets)
+	d.Add(http.MethodGet, DocsAssetPath, &Operation{
+		OperationID: "docsAsset",
+		Summary:     "Swagger UI 页面的脚本和样式",
+		Tags:        []string{TagDocs},
+		Parameters:  []*Parameter{PathParam("file", "文件名", &Schema{Type: "string", Enum: assets})},
+		Responses: map[int]*Response{
+			http.StatusOK: {
+				Description: "文件内容",
+				Content: map[string]*MediaType{
+					"text/javascript": {Schema: &Schema{Type: "string"}},
+					"text/css":        {Schema: &Schema{Type: "string"}},
+				},
+			},
+			http.StatusNotFound: d.Error("文件不存在"),
+		},
+	})
+}
+
+// 两个服务共用的标签
+const (
+	TagHealth = "health"
+	TagDocs   = "docs"
+)
+
+// CommonTags 共用标签的说明
+var CommonTags = []Tag{
+	{Name: TagHealth, Description: "健康检查和监控指标"},
+	{Name: TagDocs, Description: "接口文档"},
+}
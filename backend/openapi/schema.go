@@ -0,0 +1,273 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContentJSON JSON 请求体和响应体的媒体类型
+const ContentJSON = "application/json"
+
+// Schema OpenAPI 3.0 schema 的子集，只包含生成和校验需要的字段
+type Schema struct {
+	Ref         string `json:"$ref,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Format      string `json:"format,omitempty"`
+	Description string `json:"description,omitempty"`
+	Nullable    bool   `json:"nullable,omitempty"`
+
+	Enum      []string `json:"enum,omitempty"`
+	Pattern   string   `json:"pattern,omitempty"`
+	MinLength *int     `json:"minLength,omitempty"`
+	MaxLength *int     `json:"maxLength,omitempty"`
+	Minimum   *float64 `json:"minimum,omitempty"`
+	Maximum   *float64 `json:"maximum,omitempty"`
+
+	Items    *Schema `json:"items,omitempty"`
+	MinItems *int    `json:"minItems,omitempty"`
+	MaxItems *int    `json:"maxItems,omitempty"`
+
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	// AdditionalProperties map 的值类型
+	AdditionalProperties *Schema `json:"additionalProperties,omitempty"`
+}
+
+// refPrefix 组件 schema 引用的前缀
+const refPrefix = "#/components/schemas/"
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	rawJSONType = reflect.TypeOf(json.RawMessage{})
+)
+
+// typeRegistry 记录类型对应的组件名，不同包中的同名类型以包名区分
+type typeRegistry struct {
+	names map[reflect.Type]string
+	used  map[string]reflect.Type
+}
+
+func newTypeRegistry() *typeRegistry {
+	return &typeRegistry{names: map[reflect.Type]string{}, used: map[string]reflect.Type{}}
+}
+
+// Schema 返回 v 的类型对应的 schema。具名的结构体登记到 components 中并返回引用，其他类型直接展开。
+//
+// 结构体按 json 标签生成属性，匿名嵌入的结构体展开到外层；binding 规则中的 required、min、max、oneof、email
+// 转换为对应的约束。带 binding 标签的字段（请求参数）只有 required 规则时才必填，
+// 不带 binding 标签的字段（响应）没有 omitempty 时必填
+func (d *Document) Schema(v any) *Schema {
+	return d.schemaOf(reflect.TypeOf(v))
+}
+
+// NamedSchema 与 Schema 相同，但以 name 作为组件名，用于类型名不便直接展示的情况，如 apierror.Response。
+// 需要在该类型第一次生成 schema 之前调用
+func (d *Document) NamedSchema(name string, v any) *Schema {
+	t := reflect.TypeOf(v)
+	if _, ok := d.types.names[t]; !ok {
+		d.types.names[t] = name
+		d.types.used[name] = t
+		d.Components.Schemas[name] = d.structSchema(t)
+	}
+	return &Schema{Ref: refPrefix + d.types.names[t]}
+}
+
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawJSONType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		return &Schema{Ref: refPrefix + d.component(t)}
+	default:
+		// interface 等任意类型
+		return &Schema{}
+	}
+}
+
+// component 登记具名结构体，先记下名称再生成，使递归引用可以终止
+func (d *Document) component(t reflect.Type) string {
+	if name, ok := d.types.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := d.types.used[name]; taken {
+		pkg := t.PkgPath()
+		pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	d.types.names[t] = name
+	d.types.used[name] = t
+	d.Components.Schemas[name] = d.structSchema(t)
+	return name
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	d.addFields(s, t)
+	return s
+}
+
+func (d *Document) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, hasTag := f.Tag.Lookup("json")
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && !hasTag {
+			et := f.Type
+			if et.Kind() == reflect.Pointer {
+				et = et.Elem()
+			}
+			if et.Kind() == reflect.Struct {
+				d.addFields(s, et)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		prop := d.schemaOf(f.Type)
+		binding, hasBinding := f.Tag.Lookup("binding")
+		required := applyBinding(prop, binding)
+		if !hasBinding {
+			required = !strings.Contains(","+opts+",", ",omitempty,")
+		}
+		s.Properties[name] = prop
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// Parameters 按 form 标签把结构体的字段转换为参数，in 为 query 或 header。
+// 参数只有 binding 中有 required 规则时才必填
+func (d *Document) Parameters(in string, v any) []*Parameter {
+	var params []*Parameter
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := f.Tag.Get("form")
+			if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+				walk(f.Type)
+				continue
+			}
+			if name == "" || name == "-" || !f.IsExported() {
+				continue
+			}
+			schema := d.schemaOf(f.Type)
+			required := applyBinding(schema, f.Tag.Get("binding"))
+			params = append(params, &Parameter{Name: name, In: in, Required: required, Schema: schema})
+		}
+	}
+	walk(reflect.TypeOf(v))
+	return params
+}
+
+// FormSchema 按 form 标签生成表单请求体的 schema，用于 multipart/form-data
+func (d *Document) FormSchema(v any) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for _, p := range d.Parameters("formData", v) {
+		s.Properties[p.Name] = p.Schema
+		if p.Required {
+			s.Required = append(s.Required, p.Name)
+		}
+	}
+	return s
+}
+
+// applyBinding 把 binding 校验规则转换为 schema 的约束，返回字段是否必填。
+// dive 之后的规则作用于数组元素
+func applyBinding(s *Schema, binding string) bool {
+	if binding == "" {
+		return false
+	}
+	required := false
+	target := s
+	for _, rule := range strings.Split(binding, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "dive":
+			if target.Items != nil {
+				target = target.Items
+			}
+		case "email":
+			target.Format = "email"
+		case "phone":
+			target.Description = "手机号，需要能解析为 E.164 格式"
+		case "oneof":
+			target.Enum = strings.Fields(param)
+		case "min", "max":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				panic(fmt.Sprintf("openapi: invalid binding rule %q", rule))
+			}
+			setBound(target, name == "min", n)
+		}
+	}
+	return required
+}
+
+func setBound(s *Schema, min bool, n int) {
+	switch s.Type {
+	case "integer", "number":
+		v := float64(n)
+		if min {
+			s.Minimum = &v
+		} else {
+			s.Maximum = &v
+		}
+	case "array":
+		if min {
+			s.MinItems = &n
+		} else {
+			s.MaxItems = &n
+		}
+	case "string":
+		if min {
+			s.MinLength = &n
+		} else {
+			s.MaxLength = &n
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ValidateResponse 检查一次请求的响应是否与文档一致：接口已登记、状态码和 Content-Type 已声明，
+// JSON 响应体符合 schema。path 为实际请求的路径，不含查询参数
+func (d *Document) ValidateResponse(method, path string, status int, header http.Header, body []byte) error {
+	op, template := d.Operation(method, path)
+	if op == nil {
+		return fmt.Errorf("%s %s is not documented", method, path)
+	}
+	resp := op.Responses[status]
+	if resp == nil {
+		return fmt.Errorf("%s %s: status %d is not documented", method, template, status)
+	}
+	if len(resp.Content) == 0 {
+		if len(body) > 0 {
+			return fmt.Errorf("%s %s: status %d is documented without a body", method, template, status)
+		}
+		return nil
+	}
+
+	contentType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return fmt.Errorf("%s %s: invalid Content-Type %q", method, template, header.Get("Content-Type"))
+	}
+	media := resp.Content[contentType]
+	if media == nil {
+		return fmt.Errorf("%s %s: status %d: Content-Type %s is not documented", method, template, status, contentType)
+	}
+	if contentType != ContentJSON || media.Schema == nil {
+		return nil
+	}
+	if err := d.ValidateJSON(media.Schema, body); err != nil {
+		return fmt.Errorf("%s %s: status %d: %w", method, template, status, err)
+	}
+	return nil
+}
+
+// ValidateJSON 按 schema 校验 JSON 文本。为了发现响应中新增但文档未声明的字段，
+// 对象中出现 properties 之外的字段也视为错误（AdditionalProperties 不为空的 map 除外）
+func (d *Document) ValidateJSON(schema *Schema, data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	return d.validate(schema, v, "$")
+}
+
+func (d *Document) resolve(s *Schema) (*Schema, error) {
+	for s.Ref != "" {
+		name, ok := strings.CutPrefix(s.Ref, refPrefix)
+		if !ok || d.Components.Schemas[name] == nil {
+			return nil, fmt.Errorf("unresolved reference %s", s.Ref)
+		}
+		s = d.Components.Schemas[name]
+	}
+	return s, nil
+}
+
+func (d *Document) validate(s *Schema, v any, at string) error {
+	s, err := d.resolve(s)
+	if err != nil {
+		return err
+	}
+	if v == nil {
+		if s.Nullable || s.Type == "" {
+			return nil
+		}
+		return fmt.Errorf("%s: null is not allowed, expected %s", at, s.Type)
+	}
+
+	switch s.Type {
+	case "":
+		return nil
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return typeError(at, s.Type, v)
+		}
+		return validateString(s, str, at)
+	case "integer", "number":
+		n, ok := v.(json.Number)
+		if !ok {
+			return typeError(at, s.Type, v)
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return fmt.Errorf("%s: invalid number %s", at, n)
+		}
+		if s.Type == "integer" && strings.ContainsAny(n.String(), ".eE") {
+			return fmt.Errorf("%s: expected integer, got %s", at, n)
+		}
+		if s.Minimum != nil && f < *s.Minimum || s.Maximum != nil && f > *s.Maximum {
+			return fmt.Errorf("%s: %s is out of range", at, n)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return typeError(at, s.Type, v)
+		}
+	case "array":
+		items, ok := v.([]any)
+		if !ok {
+			return typeError(at, s.Type, v)
+		}
+		if s.MinItems != nil && len(items) < *s.MinItems || s.MaxItems != nil && len(items) > *s.MaxItems {
+			return fmt.Errorf("%s: %d items is out of range", at, len(items))
+		}
+		if s.Items == nil {
+			return nil
+		}
+		for i, item := range items {
+			if err := d.validate(s.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return typeError(at, s.Type, v)
+		}
+		return d.validateObject(s, obj, at)
+	default:
+		return fmt.Errorf("%s: unsupported schema type %s", at, s.Type)
+	}
+	return nil
+}
+
+func validateString(s *Schema, str, at string) error {
+	if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+		return fmt.Errorf("%s: %q is not one of %v", at, str, s.Enum)
+	}
+	length := len([]rune(str))
+	if s.MinLength != nil && length < *s.MinLength || s.MaxLength != nil && length > *s.MaxLength {
+		return fmt.Errorf("%s: length %d is out of range", at, length)
+	}
+	if s.Format == "date-time" {
+		if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+			return fmt.Errorf("%s: %q is not an RFC 3339 date-time", at, str)
+		}
+	}
+	return nil
+}
+
+func (d *Document) validateObject(s *Schema, obj map[string]any, at string) error {
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			return fmt.Errorf("%s: missing required property %q", at, name)
+		}
+	}
+	// 按字段名排序，出错时结果稳定
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prop := s.Properties[name]
+		if prop == nil {
+			prop = s.AdditionalProperties
+		}
+		if prop == nil {
+			if len(s.Properties) == 0 {
+				// 没有声明属性的对象（如 OpenAPI 文档本身）不限制字段
+				continue
+			}
+			return fmt.Errorf("%s: property %q is not documented", at, name)
+		}
+		if err := d.validate(prop, obj[name], at+"."+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func typeError(at, expected string, v any) error {
+	return fmt.Errorf("%s: expected %s, got %s", at, expected, jsonKind(v))
+}
+
+func jsonKind(v any) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return "null"
+	}
+}
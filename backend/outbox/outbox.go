@@ -0,0 +1,232 @@
+// Package outbox 把业务事务中写入 outbox_events 的事件投递给各个接收方（webhook 等）。
+// 事件先落库再投递，进程在写库和投递之间崩溃也不会丢失；失败的投递按指数退避重试，
+// 所以接收方可能收到重复的事件，需要按事件 ID 去重
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+	"tuna/metrics"
+	"tuna/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+// 未配置时的默认值
+const (
+	DefaultPollInterval = time.Second
+	DefaultBatchSize    = 100
+	DefaultMaxAttempts  = 10
+	DefaultBaseBackoff  = 5 * time.Second
+	DefaultMaxBackoff   = time.Hour
+	DefaultTimeout      = 10 * time.Second
+)
+
+// Sink 事件的接收方
+type Sink interface {
+	// Name 接收方的唯一名称，写入投递记录，修改后已有的投递记录不会再投递
+	Name() string
+	// Accepts 是否接收该类型的事件，只在分发时判断
+	Accepts(eventType string) bool
+	// Deliver 投递一个事件，返回错误时按退避重试，用 Permanent 包装的错误不再重试
+	Deliver(ctx context.Context, event models.Event) error
+}
+
+// Options relay 配置，零值字段使用默认值
+type Options struct {
+	Repo  models.OutboxRepository
+	Sinks []Sink
+	// PollInterval 没有待处理的事件时的轮询间隔
+	PollInterval time.Duration
+	// BatchSize 每次分发的事件数和领取的投递数
+	BatchSize int
+	// MaxAttempts 最多尝试次数，超过后投递记录标记为 failed
+	MaxAttempts int
+	// BaseBackoff、MaxBackoff 第一次重试的等待时间和等待时间的上限，每次失败后翻倍
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Timeout 单次投递的超时时间
+	Timeout time.Duration
+}
+
+// Relay 轮询 outbox，把新事件分发给接收方并投递到期的记录。多个实例可以同时运行
+type Relay struct {
+	opts  Options
+	sinks map[string]Sink
+}
+
+// NewRelay 创建 relay，接收方名称重复时返回错误
+func NewRelay(opts Options) (*Relay, error) {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = DefaultBaseBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	sinks := make(map[string]Sink, len(opts.Sinks))
+	for _, sink := range opts.Sinks {
+		if _, ok := sinks[sink.Name()]; ok {
+			return nil, fmt.Errorf("duplicate outbox sink: %s", sink.Name())
+		}
+		sinks[sink.Name()] = sink
+	}
+	return &Relay{opts: opts, sinks: sinks}, nil
+}
+
+// Run 循环处理直到 ctx 取消。处理满一批时立即继续，否则等待 PollInterval
+func (r *Relay) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		n, err := r.RunOnce(ctx)
+		if err != nil && ctx.Err() == nil {
+			logrus.WithError(err).Error("Outbox relay failed")
+		}
+		wait := r.opts.PollInterval
+		if err == nil && n >= r.opts.BatchSize {
+			wait = 0
+		}
+		timer.Reset(wait)
+	}
+}
+
+// RunOnce 分发一批新事件，再领取并投递一批到期的记录，返回投递的记录数
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	if _, err := r.opts.Repo.DispatchEvents(ctx, r.opts.BatchSize, r.sinkNames); err != nil {
+		return 0, fmt.Errorf("dispatch events: %w", err)
+	}
+
+	// 租约覆盖一次投递的超时时间，留出写回结果的余量
+	deliveries, err := r.opts.Repo.ClaimDeliveries(ctx, r.opts.BatchSize, r.opts.Timeout+time.Minute)
+	if err != nil {
+		return 0, fmt.Errorf("claim deliveries: %w", err)
+	}
+
+	// 同一批中的投递并发进行，避免一个慢接收方拖住其他接收方
+	var wg sync.WaitGroup
+	for _, d := range deliveries {
+		wg.Add(1)
+		go func(d models.Delivery) {
+			defer wg.Done()
+			r.deliver(ctx, d)
+		}(d)
+	}
+	wg.Wait()
+	return len(deliveries), nil
+}
+
+// sinkNames 接收该类型事件的接收方
+func (r *Relay) sinkNames(eventType string) []string {
+	var names []string
+	for _, sink := range r.opts.Sinks {
+		if sink.Accepts(eventType) {
+			names = append(names, sink.Name())
+		}
+	}
+	return names
+}
+
+func (r *Relay) deliver(ctx context.Context, d models.Delivery) {
+	entry := logrus.WithFields(logrus.Fields{
+		"delivery_id": d.ID,
+		"sink":        d.Sink,
+		"event_id":    d.Event.ID,
+		"event_type":  d.Event.Type,
+		"attempt":     d.Attempts,
+	})
+
+	var err error
+	sink, ok := r.sinks[d.Sink]
+	if ok {
+		deliverCtx, cancel := context.WithTimeout(ctx, r.opts.Timeout)
+		err = sink.Deliver(deliverCtx, d.Event)
+		cancel()
+	} else {
+		// 配置中删除了该接收方
+		err = Permanent(errors.New("sink is not configured"))
+	}
+
+	// 退出时 ctx 已取消，结果仍然需要写回
+	writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	if err == nil {
+		if err := r.opts.Repo.CompleteDelivery(writeCtx, d); errors.Is(err, models.ErrLeaseExpired) {
+			entry.Warn("Outbox delivery lease expired before the result was recorded, the event may be delivered twice")
+		} else if err != nil {
+			entry.WithError(err).Error("Failed to mark outbox delivery as delivered")
+		}
+		metrics.OutboxDelivery(d.Sink, "delivered")
+		entry.Debug("Outbox event delivered")
+		return
+	}
+
+	final := IsPermanent(err) || d.Attempts >= r.opts.MaxAttempts
+	retryAt := time.Now().Add(Backoff(d.Attempts, r.opts.BaseBackoff, r.opts.MaxBackoff))
+	if err := r.opts.Repo.FailDelivery(writeCtx, d, err.Error(), retryAt, final); errors.Is(err, models.ErrLeaseExpired) {
+		// 另一个实例已经重新领取，以它的结果为准
+		entry.Warn("Outbox delivery lease expired, leaving the failure to the new attempt")
+		return
+	} else if err != nil {
+		entry.WithError(err).Error("Failed to record outbox delivery failure")
+	}
+	if final {
+		metrics.OutboxDelivery(d.Sink, "failed")
+		entry.WithError(err).Error("Outbox delivery failed, giving up")
+		return
+	}
+	metrics.OutboxDelivery(d.Sink, "retry")
+	entry.WithError(err).WithField("retry_at", retryAt).Warn("Outbox delivery failed, will retry")
+}
+
+// Backoff 第 attempt 次失败后的等待时间：base 每次翻倍，不超过 max，并随机减少至多一半，
+// 避免大量失败的投递在同一时刻重试
+func Backoff(attempt int, base, max time.Duration) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 标记不需要重试的错误，如接收方明确拒绝了请求
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+// IsPermanent err 是否由 Permanent 包装
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
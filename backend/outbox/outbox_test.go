@@ -0,0 +1,209 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+	"tuna/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestMain(m *testing.M) {
+	logrus.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func TestBackoff(t *testing.T) {
+	base, max := time.Second, 10*time.Second
+	for _, tc := range []struct {
+		attempt int
+		want    time.Duration // 随机减少前的等待时间
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, max},
+		{100, max},
+	} {
+		for i := 0; i < 100; i++ {
+			if d := Backoff(tc.attempt, base, max); d < tc.want/2 || d > tc.want {
+				t.Fatalf("Backoff(%d) = %s, want between %s and %s", tc.attempt, d, tc.want/2, tc.want)
+			}
+		}
+	}
+}
+
+func TestIsPermanent(t *testing.T) {
+	err := errors.New("rejected")
+	if IsPermanent(err) {
+		t.Error("plain error is permanent")
+	}
+	wrapped := fmt.Errorf("deliver: %w", Permanent(err))
+	if !IsPermanent(wrapped) || !errors.Is(wrapped, err) {
+		t.Errorf("IsPermanent(%v) = false or the cause is lost", wrapped)
+	}
+}
+
+// shortLease 把领取的租约缩短为 lease，模拟投递超时后租约过期
+type shortLease struct {
+	models.OutboxRepository
+	lease time.Duration
+}
+
+func (r shortLease) ClaimDeliveries(ctx context.Context, limit int, _ time.Duration) ([]models.Delivery, error) {
+	return r.OutboxRepository.ClaimDeliveries(ctx, limit, r.lease)
+}
+
+// sinkFunc 以函数实现 Sink，接收全部事件
+type sinkFunc func(ctx context.Context, event models.Event) error
+
+func (f sinkFunc) Name() string                  { return "test" }
+func (f sinkFunc) Accepts(eventType string) bool { return true }
+func (f sinkFunc) Deliver(ctx context.Context, event models.Event) error {
+	return f(ctx, event)
+}
+
+// newOutbox 返回写入了一个事件的内存 outbox
+func newOutbox(t *testing.T) models.OutboxRepository {
+	t.Helper()
+	users := models.NewMemoryUserRepository()
+	user := models.UserInfo{Name: "张三", Age: 25, Email: "zhangsan@example.com", Phone: "13800138000"}
+	if _, err := users.CreateUserInfo(context.Background(), &user, models.DuplicateReject); err != nil {
+		t.Fatal(err)
+	}
+	return models.NewMemoryOutboxRepository(users)
+}
+
+func TestRelayDeliver(t *testing.T) {
+	repo := newOutbox(t)
+	var mu sync.Mutex
+	var delivered []models.Event
+	relay, err := NewRelay(Options{Repo: repo, Sinks: []Sink{sinkFunc(func(ctx context.Context, event models.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, event)
+		return nil
+	})}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if n, err := relay.RunOnce(ctx); err != nil || n != 1 {
+		t.Fatalf("RunOnce = %d, %v, want 1 delivery", n, err)
+	}
+	if len(delivered) != 1 || delivered[0].Type != models.EventSubmissionCreated {
+		t.Fatalf("delivered %+v, want one %s event", delivered, models.EventSubmissionCreated)
+	}
+	if n, err := relay.RunOnce(ctx); err != nil || n != 0 {
+		t.Errorf("second RunOnce = %d, %v, want nothing left", n, err)
+	}
+}
+
+func TestRelayFailures(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		err   error
+		retry bool
+	}{
+		{"temporary", errors.New("connection refused"), true},
+		{"permanent", Permanent(errors.New("rejected")), false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := newOutbox(t)
+			relay, err := NewRelay(Options{
+				Repo:        repo,
+				Sinks:       []Sink{sinkFunc(func(context.Context, models.Event) error { return tc.err })},
+				BaseBackoff: time.Millisecond,
+				MaxBackoff:  time.Millisecond,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := relay.RunOnce(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(5 * time.Millisecond)
+			deliveries, err := repo.ClaimDeliveries(context.Background(), 10, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(deliveries) == 1; got != tc.retry {
+				t.Errorf("retried = %v, want %v", got, tc.retry)
+			}
+		})
+	}
+}
+
+func TestRelayMaxAttempts(t *testing.T) {
+	repo := newOutbox(t)
+	attempts := 0
+	relay, err := NewRelay(Options{
+		Repo: repo,
+		Sinks: []Sink{sinkFunc(func(context.Context, models.Event) error {
+			attempts++
+			return errors.New("connection refused")
+		})},
+		MaxAttempts: 3,
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := relay.RunOnce(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if attempts != 3 {
+		t.Errorf("%d attempts, want 3", attempts)
+	}
+}
+
+func TestRelayStaleClaim(t *testing.T) {
+	repo := shortLease{newOutbox(t), 10 * time.Millisecond}
+	ctx := context.Background()
+
+	// 投递超过了租约，期间另一个实例重新领取了同一条记录
+	var reclaimed []models.Delivery
+	relay, err := NewRelay(Options{Repo: repo, Sinks: []Sink{sinkFunc(func(context.Context, models.Event) error {
+		time.Sleep(20 * time.Millisecond)
+		var err error
+		reclaimed, err = repo.OutboxRepository.ClaimDeliveries(ctx, 10, time.Minute)
+		if err != nil {
+			t.Error(err)
+		}
+		return errors.New("timeout")
+	})}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := relay.RunOnce(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(reclaimed) != 1 {
+		t.Fatalf("reclaimed %d deliveries, want 1", len(reclaimed))
+	}
+
+	// 旧的领取写回的失败被忽略，记录仍属于新的领取
+	if err := repo.CompleteDelivery(ctx, reclaimed[0]); err != nil {
+		t.Errorf("CompleteDelivery with the new claim: %v", err)
+	}
+	stale := reclaimed[0]
+	stale.ClaimToken = "stale"
+	if err := repo.FailDelivery(ctx, stale, "late", time.Now(), false); !errors.Is(err, models.ErrLeaseExpired) {
+		t.Errorf("FailDelivery with a stale claim = %v, want ErrLeaseExpired", err)
+	}
+	if deliveries, err := repo.ClaimDeliveries(ctx, 10, time.Minute); err != nil || len(deliveries) != 0 {
+		t.Errorf("ClaimDeliveries after completion = %d, %v, want none", len(deliveries), err)
+	}
+}
@@ -0,0 +1,60 @@
+// Package ratelimit 提供令牌桶限流，桶的状态保存在可替换的 Store 中
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit 每个 Per 时间内最多 Requests 次，也是桶的容量，零值表示不限流
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// Enabled 判断是否需要限流
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Per > 0
+}
+
+// rate 每秒补充的令牌数
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+func (l Limit) String() string {
+	if !l.Enabled() {
+		return ""
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Per)
+}
+
+// ParseLimit 解析 "10/1m" 形式的配置，空字符串表示不限流
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Limit{}, nil
+	}
+	count, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, expected <requests>/<duration>", s)
+	}
+	requests, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || requests <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: requests must be a positive integer", s)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(per))
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: invalid duration", s)
+	}
+	return Limit{Requests: requests, Per: d}, nil
+}
+
+// Store 保存每个 key 的令牌桶。Allow 取走一个令牌，没有令牌时返回 false
+// 以及需要等待的时间。多个实例共享限流状态时可以用 Redis 等实现该接口
+type Store interface {
+	Allow(ctx context.Context, key string, limit Limit) (ok bool, retryAfter time.Duration, err error)
+}
@@ -0,0 +1,157 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newTestStore 返回时间由 advance 控制的 MemoryStore
+func newTestStore() (s *MemoryStore, advance func(time.Duration)) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s = NewMemoryStore()
+	s.now = func() time.Time { return now }
+	return s, func(d time.Duration) { now = now.Add(d) }
+}
+
+// allow 调用 Allow，出错时终止测试
+func allow(t *testing.T, s *MemoryStore, key string, limit Limit) (bool, time.Duration) {
+	t.Helper()
+	ok, wait, err := s.Allow(context.Background(), key, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ok, wait
+}
+
+func TestParseLimit(t *testing.T) {
+	for _, tc := range []struct {
+		in      string
+		want    Limit
+		wantErr bool
+	}{
+		{"10/1m", Limit{Requests: 10, Per: time.Minute}, false},
+		{" 5 / 1h ", Limit{Requests: 5, Per: time.Hour}, false},
+		{"", Limit{}, false},
+		{"10", Limit{}, true},
+		{"0/1m", Limit{}, true},
+		{"-1/1m", Limit{}, true},
+		{"ten/1m", Limit{}, true},
+		{"10/forever", Limit{}, true},
+		{"10/0s", Limit{}, true},
+	} {
+		got, err := ParseLimit(tc.in)
+		if (err != nil) != tc.wantErr || got != tc.want {
+			t.Errorf("ParseLimit(%q) = %+v, %v, want %+v (error %v)", tc.in, got, err, tc.want, tc.wantErr)
+		}
+	}
+	if s := (Limit{Requests: 10, Per: time.Minute}).String(); s != "10/1m0s" {
+		t.Errorf("String = %q", s)
+	}
+}
+
+func TestMemoryStoreBurst(t *testing.T) {
+	s, _ := newTestStore()
+	limit := Limit{Requests: 3, Per: time.Minute}
+
+	// 桶一开始是满的，可以连续用完全部容量
+	for i := 0; i < 3; i++ {
+		if ok, _ := allow(t, s, "ip:1", limit); !ok {
+			t.Fatalf("request %d rejected within the burst", i+1)
+		}
+	}
+	ok, wait := allow(t, s, "ip:1", limit)
+	if ok {
+		t.Fatal("request beyond the burst allowed")
+	}
+	// 每 20s 补充一个令牌
+	if wait != 20*time.Second {
+		t.Errorf("retry after %s, want 20s", wait)
+	}
+}
+
+func TestMemoryStoreRefill(t *testing.T) {
+	s, advance := newTestStore()
+	limit := Limit{Requests: 3, Per: time.Minute}
+	for i := 0; i < 3; i++ {
+		allow(t, s, "ip:1", limit)
+	}
+
+	advance(5 * time.Second)
+	if ok, wait := allow(t, s, "ip:1", limit); ok || wait != 15*time.Second {
+		t.Errorf("after 5s: ok %v, retry after %s, want rejected with 15s", ok, wait)
+	}
+	advance(15 * time.Second)
+	if ok, _ := allow(t, s, "ip:1", limit); !ok {
+		t.Error("rejected after a token was refilled")
+	}
+	if ok, _ := allow(t, s, "ip:1", limit); ok {
+		t.Error("refill added more than one token")
+	}
+
+	// 空闲再久也不超过容量
+	advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		if ok, _ := allow(t, s, "ip:1", limit); !ok {
+			t.Fatalf("request %d rejected after a long idle period", i+1)
+		}
+	}
+	if ok, _ := allow(t, s, "ip:1", limit); ok {
+		t.Error("bucket refilled beyond its capacity")
+	}
+}
+
+func TestMemoryStoreKeys(t *testing.T) {
+	s, _ := newTestStore()
+	limit := Limit{Requests: 1, Per: time.Minute}
+
+	if ok, _ := allow(t, s, "ip:1", limit); !ok {
+		t.Fatal("first request of ip:1 rejected")
+	}
+	if ok, _ := allow(t, s, "ip:1", limit); ok {
+		t.Fatal("second request of ip:1 allowed")
+	}
+	// 每个 key 有自己的桶
+	if ok, _ := allow(t, s, "ip:2", limit); !ok {
+		t.Error("ip:2 limited by requests of ip:1")
+	}
+	if ok, _ := allow(t, s, "contact:1", limit); !ok {
+		t.Error("contact:1 limited by requests of ip:1")
+	}
+}
+
+func TestMemoryStoreDisabled(t *testing.T) {
+	s, _ := newTestStore()
+	for i := 0; i < 100; i++ {
+		if ok, _ := allow(t, s, "ip:1", Limit{}); !ok {
+			t.Fatal("zero limit rejected a request")
+		}
+	}
+	if len(s.buckets) != 0 {
+		t.Errorf("%d buckets kept for a disabled limit", len(s.buckets))
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	s, advance := newTestStore()
+	allow(t, s, "ip:1", Limit{Requests: 2, Per: time.Minute})
+	slow := Limit{Requests: 2, Per: 2 * time.Minute}
+	allow(t, s, "ip:2", slow)
+	allow(t, s, "ip:2", slow)
+
+	// 一分钟后 ip:1 已补满，ip:2 还差一个令牌
+	advance(sweepInterval)
+	allow(t, s, "ip:3", Limit{Requests: 100, Per: time.Hour})
+	if _, ok := s.buckets["ip:1"]; ok {
+		t.Error("full bucket ip:1 was not swept")
+	}
+	if _, ok := s.buckets["ip:2"]; !ok {
+		t.Error("bucket ip:2 was swept before it was full")
+	}
+	if ok, wait := allow(t, s, "ip:2", slow); !ok || wait != 0 {
+		t.Errorf("ip:2 after a minute: ok %v, retry after %s", ok, wait)
+	}
+	if ok, _ := allow(t, s, "ip:2", slow); ok {
+		t.Error("ip:2 state lost by the sweep")
+	}
+}
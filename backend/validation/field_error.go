@@ -0,0 +1,18 @@
+package validation
+
+import "fmt"
+
+// FieldError 结构体标签之外的参数校验错误，Rule 的取值与校验规则名一致，
+// 如 oneof、ltfield，由 apierror 转换为带本地化提示的字段错误
+type FieldError struct {
+	Field string
+	Rule  string
+	Param string
+}
+
+func (e *FieldError) Error() string {
+	if e.Param == "" {
+		return fmt.Sprintf("invalid %s: failed on the '%s' rule", e.Field, e.Rule)
+	}
+	return fmt.Sprintf("invalid %s: failed on the '%s=%s' rule", e.Field, e.Rule, e.Param)
+}
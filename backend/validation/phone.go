@@ -0,0 +1,45 @@
+package validation
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/nyaruka/phonenumbers"
+)
+
+// ErrInvalidPhone 无法解析为 E.164 格式的号码
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+// SupportedRegion 是否支持按该地区解析国内号码，region 为大写的地区代码，如 CN、US
+func SupportedRegion(region string) bool {
+	return phonenumbers.GetSupportedRegions()[region]
+}
+
+// ParsePhone 把号码解析为 E.164 格式（+<区号><号码>）。以 + 或 00 开头的按国际号码解析，
+// 否则按 region 的国内号码解析。允许空格、横线、点和括号作为分隔符，号码规则来自 libphonenumber 的元数据
+func ParsePhone(raw, region string) (string, error) {
+	s := strings.TrimSpace(raw)
+	// 英国等地常见的 +44 (0)20... 写法，(0) 是国内拨号时才需要的前缀
+	s = strings.ReplaceAll(s, "(0)", "")
+
+	// libphonenumber 允许字母等更多写法，这里只接受数字和常见分隔符
+	for i, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		case r == '+' && i == 0:
+		default:
+			return "", ErrInvalidPhone
+		}
+	}
+	// 00 只是部分地区的国际冠字，统一按国际号码处理，不依赖 region
+	if digits := strings.TrimLeft(s, " (-."); strings.HasPrefix(digits, "00") {
+		s = "+" + digits[2:]
+	}
+
+	number, err := phonenumbers.Parse(s, strings.ToUpper(region))
+	if err != nil || !phonenumbers.IsValidNumber(number) {
+		return "", ErrInvalidPhone
+	}
+	return phonenumbers.Format(number, phonenumbers.E164), nil
+}
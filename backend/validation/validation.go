@@ -0,0 +1,66 @@
+// Package validation 注册到 gin 参数校验中的自定义规则，以及联系方式的规范化
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// DefaultRegion 未调用 SetDefaultRegion 时解析国内号码使用的地区
+const DefaultRegion = "CN"
+
+var defaultRegion atomic.Value
+
+func init() {
+	defaultRegion.Store(DefaultRegion)
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		// 校验错误中的字段名使用 json/form 标签，与请求参数一致
+		v.RegisterTagNameFunc(fieldName)
+		// phone: 可以按默认地区解析为 E.164 的号码
+		v.RegisterValidation("phone", func(fl validator.FieldLevel) bool {
+			_, err := NormalizePhone(fl.Field().String())
+			return err == nil
+		})
+	}
+}
+
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		name, _, _ := strings.Cut(field.Tag.Get(tag), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// SetDefaultRegion 设置不带国际区号的号码所属的地区，如 CN、US
+func SetDefaultRegion(region string) error {
+	region = strings.ToUpper(strings.TrimSpace(region))
+	if region == "" {
+		region = DefaultRegion
+	}
+	if !SupportedRegion(region) {
+		return fmt.Errorf("unsupported phone region %q", region)
+	}
+	defaultRegion.Store(region)
+	return nil
+}
+
+// NormalizePhone 按默认地区把号码转换为 E.164 格式
+func NormalizePhone(phone string) (string, error) {
+	return ParsePhone(phone, defaultRegion.Load().(string))
+}
+
+// NormalizeEmail 去掉首尾空白并转为小写
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,90 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePhone(t *testing.T) {
+	for _, tc := range []struct {
+		raw, region string
+		want        string // 为空表示无效号码
+	}{
+		// 国内号码按 region 解析
+		{"13800138000", "CN", "+8613800138000"},
+		{"138 0013 8000", "CN", "+8613800138000"},
+		{"138-0013-8000", "cn", "+8613800138000"},
+		{" (201) 555-0123 ", "US", "+12015550123"},
+		{"201.555.0123", "US", "+12015550123"},
+		// 带国际区号的号码不受 region 影响
+		{"+86 138 0013 8000", "CN", "+8613800138000"},
+		{"+86 138 0013 8000", "US", "+8613800138000"},
+		{"+8613800138000", "GB", "+8613800138000"},
+		{"0086 138 0013 8000", "US", "+8613800138000"},
+		{"+1 201 555 0123", "CN", "+12015550123"},
+		{"+44 (0)20 7946 0958", "CN", "+442079460958"},
+		// 按错误的地区解析
+		{"13800138000", "US", ""},
+		// 格式错误
+		{"", "CN", ""},
+		{"1380013800", "CN", ""},
+		{"555-0123", "US", ""},
+		{"138001380001", "CN", ""},
+		{"1-800-FLOWERS", "US", ""},
+		{"138 0013 8000 ext. 12", "CN", ""},
+		{"86+13800138000", "CN", ""},
+		{"+999 1234 5678", "CN", ""},
+	} {
+		got, err := ParsePhone(tc.raw, tc.region)
+		if tc.want == "" {
+			if !errors.Is(err, ErrInvalidPhone) {
+				t.Errorf("ParsePhone(%q, %q) = %q, %v, want ErrInvalidPhone", tc.raw, tc.region, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("ParsePhone(%q, %q) = %q, %v, want %q", tc.raw, tc.region, got, err, tc.want)
+		}
+	}
+}
+
+func TestNormalizePhoneDefaultRegion(t *testing.T) {
+	t.Cleanup(func() { SetDefaultRegion(DefaultRegion) })
+
+	if got, err := NormalizePhone("13800138000"); err != nil || got != "+8613800138000" {
+		t.Errorf("default region: NormalizePhone = %q, %v", got, err)
+	}
+	if err := SetDefaultRegion(" us "); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := NormalizePhone("(201) 555-0123"); err != nil || got != "+12015550123" {
+		t.Errorf("US: NormalizePhone = %q, %v", got, err)
+	}
+	if _, err := NormalizePhone("13800138000"); err == nil {
+		t.Error("US: Chinese mobile number without +86 accepted")
+	}
+
+	if err := SetDefaultRegion("XX"); err == nil {
+		t.Error("SetDefaultRegion accepted an unknown region")
+	}
+	// 空字符串恢复默认地区
+	if err := SetDefaultRegion(""); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := NormalizePhone("13800138000"); err != nil || got != "+8613800138000" {
+		t.Errorf("after reset: NormalizePhone = %q, %v", got, err)
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"zhangsan@example.com", "zhangsan@example.com"},
+		{"ZhangSan@Example.COM", "zhangsan@example.com"},
+		{"  zhangsan@example.com\t", "zhangsan@example.com"},
+		{"", ""},
+	} {
+		if got := NormalizeEmail(tc.in); got != tc.want {
+			t.Errorf("NormalizeEmail(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Package webhook 把 outbox 中的事件以带签名的 HTTP POST 推送给订阅方。
+//
+// 请求体为事件的 JSON，请求头：
+//   - X-Tuna-Event：事件类型
+//   - X-Tuna-Delivery：事件 ID，重试时不变，用于去重
+//   - X-Tuna-Timestamp：发送时的 Unix 秒数
+//   - X-Tuna-Signature：sha256=<hex>，为以订阅的 secret 为密钥对 "<timestamp>.<body>" 计算的 HMAC-SHA256
+//
+// 订阅方返回 2xx 视为投递成功，其他情况按 outbox 的退避策略重试
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"tuna/models"
+)
+
+// 请求头
+const (
+	HeaderEvent     = "X-Tuna-Event"
+	HeaderDelivery  = "X-Tuna-Delivery"
+	HeaderTimestamp = "X-Tuna-Timestamp"
+	HeaderSignature = "X-Tuna-Signature"
+)
+
+// SinkPrefix 投递记录中 webhook 接收方名称的前缀
+const SinkPrefix = "webhook:"
+
+// Subscription 一个 webhook 订阅
+type Subscription struct {
+	// Name 订阅的唯一名称，修改后尚未投递的事件不会再投递
+	Name string
+	URL  string
+	// Secret 签名密钥，需要与订阅方共享
+	Secret string
+	// Events 订阅的事件类型
+	Events []string
+}
+
+// Sink 实现 outbox.Sink
+type Sink struct {
+	sub    Subscription
+	client *http.Client
+}
+
+// NewSink 返回订阅对应的接收方，client 为 nil 时使用 http.DefaultClient，超时由 outbox 控制
+func NewSink(sub Subscription, client *http.Client) *Sink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Sink{sub: sub, client: client}
+}
+
+func (s *Sink) Name() string {
+	return SinkPrefix + s.sub.Name
+}
+
+func (s *Sink) Accepts(eventType string) bool {
+	return slices.Contains(s.sub.Events, eventType)
+}
+
+func (s *Sink) Deliver(ctx context.Context, event models.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tuna-webhook/1")
+	req.Header.Set(HeaderEvent, event.Type)
+	req.Header.Set(HeaderDelivery, event.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(s.sub.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// 只保留响应的开头用于排查，读完剩余部分以便复用连接
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+	return nil
+}
+
+// Sign 计算签名头的值
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// 验证签名的错误
+var (
+	ErrInvalidSignature = errors.New("webhook: invalid signature")
+	ErrExpiredTimestamp = errors.New("webhook: timestamp outside tolerance")
+)
+
+// Verify 供订阅方使用的 Go 实现：校验签名，并在 tolerance 大于 0 时拒绝时间戳与当前时间相差超过 tolerance 的请求，防止重放
+func Verify(secret, signature, timestamp string, body []byte, tolerance time.Duration) error {
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body))) {
+		return ErrInvalidSignature
+	}
+	if tolerance > 0 {
+		sec, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return ErrExpiredTimestamp
+		}
+		if d := time.Since(time.Unix(sec, 0)); d > tolerance || d < -tolerance {
+			return ErrExpiredTimestamp
+		}
+	}
+	return nil
+}
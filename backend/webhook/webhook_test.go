@@ -0,0 +1,84 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+	"tuna/models"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"id":"evt_1","type":"submission.created"}`)
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := Sign("s3cret", now, body)
+
+	if err := Verify("s3cret", signature, now, body, 5*time.Minute); err != nil {
+		t.Fatalf("Verify = %v, want nil", err)
+	}
+
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	for _, tc := range []struct {
+		name                         string
+		secret, signature, timestamp string
+		body                         []byte
+		tolerance                    time.Duration
+		want                         error
+	}{
+		{"wrong secret", "other", signature, now, body, 0, ErrInvalidSignature},
+		{"tampered body", "s3cret", signature, now, []byte(`{"id":"evt_2","type":"submission.created"}`), 0, ErrInvalidSignature},
+		{"tampered timestamp", "s3cret", signature, old, body, 0, ErrInvalidSignature},
+		{"missing prefix", "s3cret", signature[len("sha256="):], now, body, 0, ErrInvalidSignature},
+		{"empty signature", "s3cret", "", now, body, 0, ErrInvalidSignature},
+		{"expired", "s3cret", Sign("s3cret", old, body), old, body, 5 * time.Minute, ErrExpiredTimestamp},
+		{"invalid timestamp", "s3cret", Sign("s3cret", "soon", body), "soon", body, 5 * time.Minute, ErrExpiredTimestamp},
+		{"no tolerance", "s3cret", Sign("s3cret", old, body), old, body, 0, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := Verify(tc.secret, tc.signature, tc.timestamp, tc.body, tc.tolerance); !errors.Is(err, tc.want) {
+				t.Errorf("Verify = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestSinkDeliver(t *testing.T) {
+	status := http.StatusNoContent
+	var verifyErr error
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		header = r.Header
+		verifyErr = Verify("s3cret", r.Header.Get(HeaderSignature), r.Header.Get(HeaderTimestamp), body, time.Minute)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	sink := NewSink(Subscription{Name: "crm", URL: srv.URL, Secret: "s3cret", Events: []string{models.EventSubmissionCreated}}, nil)
+	if sink.Name() != SinkPrefix+"crm" {
+		t.Errorf("Name = %q", sink.Name())
+	}
+	if !sink.Accepts(models.EventSubmissionCreated) || sink.Accepts(models.EventSubmissionApproved) {
+		t.Error("Accepts does not follow the subscribed events")
+	}
+
+	event := models.Event{ID: "evt_1", Type: models.EventSubmissionCreated, OccurredAt: time.Now()}
+	if err := sink.Deliver(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if verifyErr != nil {
+		t.Errorf("receiver could not verify the request: %v", verifyErr)
+	}
+	if header.Get(HeaderEvent) != event.Type || header.Get(HeaderDelivery) != event.ID {
+		t.Errorf("headers %s=%q, %s=%q", HeaderEvent, header.Get(HeaderEvent), HeaderDelivery, header.Get(HeaderDelivery))
+	}
+
+	status = http.StatusInternalServerError
+	if err := sink.Deliver(context.Background(), event); err == nil {
+		t.Error("Deliver succeeded on a 500 response")
+	}
+}